@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runs the job every interval until the context is done, the errors are
+// logged and the job keeps running
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) (int64, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := job(ctx)
+
+			if err != nil {
+				log.Printf("job %s: %v", name, err)
+				continue
+			}
+
+			if n > 0 {
+				log.Printf("job %s: %d rows affected", name, n)
+			}
+		}
+	}
+}
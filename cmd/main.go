@@ -1 +1,242 @@
-package cmd
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/marlonmp/books-app/config"
+	"github.com/marlonmp/books-app/handlers"
+	"github.com/marlonmp/books-app/mails"
+	"github.com/marlonmp/books-app/oidc"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/services"
+	"github.com/marlonmp/books-app/storage"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+func main() {
+	cfg, err := config.Load()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	connConfig, err := pgx.ParseConnectionString(cfg.DatabaseURL)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pool, err := pgx.NewConnPool(pgx.ConnPoolConfig{
+		ConnConfig:     connConfig,
+		MaxConnections: cfg.DatabaseMaxConns,
+	})
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer pool.Close()
+
+	fileStorage, err := newStorage(cfg.Storage)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	valobjs.UseStorage(fileStorage)
+	valobjs.UsePasswordPolicy(newPasswordPolicy(cfg.Password))
+	valobjs.UsePasswordHashing(newPasswordHashing(cfg.Password))
+
+	userRepo := repos.PSQLUserRepo(pool)
+	bookRepo := repos.PSQLBookRepo(pool)
+	sessionRepo := repos.PSQLSessionRepo(pool)
+	verificationRepo := repos.PSQLVerificationRepo(pool)
+	passwordResetRepo := repos.PSQLPasswordResetRepo(pool)
+	attemptRepo := repos.PSQLSignInAttemptRepo(pool)
+	lockoutRepo := repos.PSQLLockoutRepo(pool)
+	apiTokenRepo := repos.PSQLAPITokenRepo(pool)
+	identityRepo := repos.PSQLExternalIdentityRepo(pool)
+	oidcStateRepo := repos.PSQLOIDCStateRepo(pool)
+	twoFactorTicketRepo := repos.PSQLTwoFactorTicketRepo(pool)
+	moderationRepo := repos.PSQLModerationRepo(pool)
+	transactor := repos.PSQLTransactor(pool)
+
+	transport, err := newMailer(cfg.Mail)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mailer := mails.Async(transport, cfg.Mail.QueueSize, 2)
+
+	sessionService := services.NewSessionService(sessionRepo, userRepo, cfg.SessionTTL)
+	lockoutService := services.NewLockoutService(attemptRepo, lockoutRepo, services.LockoutOptions{
+		MaxFailures:   cfg.Lockout.MaxFailures,
+		BaseLockout:   cfg.Lockout.BaseLockout,
+		MaxLockout:    cfg.Lockout.MaxLockout,
+		FailureWindow: cfg.Lockout.FailureWindow,
+	})
+	userService := services.NewUserService(userRepo, bookRepo, sessionService, verificationRepo, passwordResetRepo, apiTokenRepo, lockoutService, mailer, services.UserOptions{
+		AppURL:           cfg.AppURL,
+		VerificationTTL:  cfg.VerificationTTL,
+		UnverifiedTTL:    cfg.UnverifiedTTL,
+		PasswordResetTTL: cfg.PasswordResetTTL,
+		TOTPIssuer:       cfg.TOTPIssuer,
+	})
+	bookService := services.NewBookService(bookRepo, services.BookOptions{
+		DeleteGracePeriod: cfg.DeleteGracePeriod,
+	})
+	apiTokenService := services.NewAPITokenService(apiTokenRepo, userRepo)
+	oidcService := services.NewOIDCService(newOIDCProviders(cfg.OIDC), userRepo, identityRepo, oidcStateRepo, twoFactorTicketRepo, userService, services.OIDCOptions{
+		StateTTL:     cfg.OIDC.StateTTL,
+		TwoFactorTTL: cfg.OIDC.TwoFactorTTL,
+	})
+	moderationService := services.NewModerationService(userRepo, bookRepo, moderationRepo, transactor, services.ModerationOptions{
+		DeleteGracePeriod: cfg.DeleteGracePeriod,
+	})
+
+	server := &http.Server{
+		Addr:    cfg.Addr,
+		Handler: handlers.New(userService, bookService, sessionService, apiTokenService, oidcService, lockoutService, moderationService),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go runPeriodically(ctx, "purge expired sessions", time.Hour, sessionService.PurgeExpired)
+	go runPeriodically(ctx, "purge unverified users", time.Hour, userService.PurgeUnverified)
+	go runPeriodically(ctx, "purge stale sign in attempts", time.Hour, lockoutService.PurgeStale)
+	go runPeriodically(ctx, "purge expired oidc states and tickets", time.Hour, oidcService.PurgeExpired)
+	go runPeriodically(ctx, "lift expired bans", time.Minute, moderationService.LiftExpiredBans)
+	go runPeriodically(ctx, "purge deleted books", time.Hour, bookService.PurgeDeleted)
+	go runPeriodically(ctx, "purge deleted users", time.Hour, moderationService.PurgeDeleted)
+
+	go func() {
+		log.Printf("listening on %s", cfg.Addr)
+
+		err := server.ListenAndServe()
+
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	log.Print("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+
+	if err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+
+	// the queued mails are sent before exiting, with their own timeout since
+	// the server may have used the whole shutdown one
+	mailerCtx, cancelMailer := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelMailer()
+
+	err = mailer.Close(mailerCtx)
+
+	if err != nil {
+		log.Printf("shutdown mailer: %v", err)
+	}
+}
+
+func newStorage(cfg config.StorageConfig) (valobjs.Storage, error) {
+	switch cfg.Driver {
+	case config.StorageDriverMemory:
+		return storage.Memory(), nil
+	case config.StorageDriverS3:
+		return storage.S3(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		return storage.Local(cfg.LocalPath), nil
+	}
+}
+
+func newMailer(cfg config.MailConfig) (mails.Mailer, error) {
+	var mailer mails.Mailer
+
+	switch cfg.Driver {
+	case config.MailDriverSMTP:
+		var err error
+
+		mailer, err = mails.SMTP(mails.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	case config.MailDriverFile:
+		mailer = mails.File(cfg.FilePath, cfg.From)
+	default:
+		return mails.Log(), nil
+	}
+
+	return mails.Retrying(mailer, cfg.Retries, cfg.RetryBackoff), nil
+}
+
+func newPasswordPolicy(cfg config.PasswordConfig) valobjs.PasswordPolicy {
+	policy := valobjs.DefaultPasswordPolicy
+
+	policy.MinLength = cfg.MinLength
+	policy.MaxBytes = cfg.MaxBytes
+	policy.RequireMixed = cfg.RequireMixed
+	policy.RejectIdentity = cfg.RejectIdentity
+
+	if cfg.BreachedPath != "" {
+		policy.Breached = valobjs.BreachedPasswordsDir(cfg.BreachedPath)
+	}
+
+	return policy
+}
+
+func newPasswordHashing(cfg config.PasswordConfig) valobjs.PasswordHashing {
+	hashing := valobjs.DefaultPasswordHashing
+
+	hashing.Algorithm = valobjs.HashAlgorithm(cfg.Hash)
+	hashing.BcryptCost = cfg.BcryptCost
+
+	hashing.Argon2.Memory = uint32(cfg.Argon2Memory)
+	hashing.Argon2.Time = uint32(cfg.Argon2Time)
+	hashing.Argon2.Threads = uint8(cfg.Argon2Threads)
+
+	return hashing
+}
+
+func newOIDCProviders(cfg config.OIDCConfig) []*oidc.Provider {
+	providers := make([]*oidc.Provider, len(cfg.Providers))
+
+	for i, p := range cfg.Providers {
+		providers[i] = oidc.New(p.Name, oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		})
+	}
+
+	return providers
+}
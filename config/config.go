@@ -0,0 +1,403 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingDatabaseURL  = errors.New("missing config: DATABASE_URL must be set")
+	ErrUnknownStorage      = errors.New("invalid config: STORAGE_DRIVER must be local, memory or s3")
+	ErrMissingStoragePath  = errors.New("missing config: FILE_SOTRAGE_PATH must be set for the local storage")
+	ErrUnknownMailer       = errors.New("invalid config: MAIL_DRIVER must be log, file or smtp")
+	ErrUnknownPasswordHash = errors.New("invalid config: PASSWORD_HASH must be argon2id or bcrypt")
+)
+
+type StorageDriver string
+
+const (
+	StorageDriverLocal  StorageDriver = "local"
+	StorageDriverMemory StorageDriver = "memory"
+	StorageDriverS3     StorageDriver = "s3"
+)
+
+type MailDriver string
+
+const (
+	MailDriverLog  MailDriver = "log"
+	MailDriverFile MailDriver = "file"
+	MailDriverSMTP MailDriver = "smtp"
+)
+
+type PasswordHash string
+
+const (
+	PasswordHashArgon2id PasswordHash = "argon2id"
+	PasswordHashBcrypt   PasswordHash = "bcrypt"
+)
+
+type MailConfig struct {
+	Driver MailDriver
+
+	// address used as the sender of all the mails
+	From string
+
+	// directory where the file driver stores the mails
+	FilePath string
+
+	SMTPHost string
+	SMTPPort int
+	SMTPUsername,
+	SMTPPassword string
+
+	// times that a mail is tried before giving up, and the wait before the
+	// first retry, it gets doubled on every retry
+	Retries      int
+	RetryBackoff time.Duration
+
+	// size of the queue of mails waiting to be sent
+	QueueSize int
+}
+
+type PasswordConfig struct {
+	MinLength,
+	MaxBytes int
+
+	// requires letters and numbers or symbols
+	RequireMixed bool
+
+	// rejects the passwords that contain the username or the email
+	RejectIdentity bool
+
+	// directory with the breached passwords list, the check is skipped if
+	// it's empty. See [valobjs.BreachedPasswordsDir]
+	BreachedPath string
+
+	// algorithm of the new hashes, the old ones are rehashed on sign in
+	Hash PasswordHash
+
+	BcryptCost int
+
+	// argon2id memory in KiB, iterations and parallelism
+	Argon2Memory,
+	Argon2Time,
+	Argon2Threads int
+}
+
+type OIDCProviderConfig struct {
+	// name of the provider in the urls, like /oidc/{name}/start
+	Name string
+
+	Issuer,
+	ClientID,
+	ClientSecret string
+
+	// url of the frontend where the provider sends the users back
+	RedirectURL string
+
+	Scopes []string
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+
+	// time given to the users to sign in with the provider
+	StateTTL time.Duration
+
+	// time given to the users with two factor authentication to send their
+	// code after signing in with the provider
+	TwoFactorTTL time.Duration
+}
+
+type LockoutConfig struct {
+	// failed sign ins allowed before locking the user or the ip
+	MaxFailures int
+
+	// duration of the first lock, every extra failure doubles it until the
+	// max lockout
+	BaseLockout,
+	MaxLockout time.Duration
+
+	// time after the failed sign ins are forgotten
+	FailureWindow time.Duration
+}
+
+type StorageConfig struct {
+	Driver StorageDriver
+
+	// root directory of the local driver
+	LocalPath string
+
+	S3Endpoint,
+	S3Region,
+	S3Bucket,
+	S3AccessKey,
+	S3SecretKey string
+}
+
+type Config struct {
+	// address where the http server listens, e.g. ":8080"
+	Addr string
+
+	// postgres connection string, it can be an url or a dsn
+	DatabaseURL string
+
+	// max number of open connections in the database pool
+	DatabaseMaxConns int
+
+	// time given to the in-flight requests to finish after a shutdown signal,
+	// and then to the queued mails to be sent
+	ShutdownTimeout time.Duration
+
+	// base url of the frontend, used to build the links sent by email
+	AppURL string
+
+	// name shown by the authenticator apps next to the totp codes
+	TOTPIssuer string
+
+	// time that a session lasts since the sign in
+	SessionTTL time.Duration
+
+	// time that an email verification link lasts
+	VerificationTTL time.Duration
+
+	// time given to the new users to verify their email before being deleted
+	UnverifiedTTL time.Duration
+
+	// time that a password reset link lasts
+	PasswordResetTTL time.Duration
+
+	// time that the deleted users and books can be restored before being
+	// purged
+	DeleteGracePeriod time.Duration
+
+	Storage StorageConfig
+
+	Mail MailConfig
+
+	Lockout LockoutConfig
+
+	Password PasswordConfig
+
+	OIDC OIDCConfig
+}
+
+// Load reads the config from the environment variables, the missing optional
+// values get their default value
+func Load() (Config, error) {
+	c := Config{
+		Addr:              getEnv("HTTP_ADDR", ":8080"),
+		DatabaseURL:       os.Getenv("DATABASE_URL"),
+		DatabaseMaxConns:  10,
+		ShutdownTimeout:   10 * time.Second,
+		AppURL:            getEnv("APP_URL", "http://localhost:8080"),
+		TOTPIssuer:        getEnv("TOTP_ISSUER", "Books App"),
+		SessionTTL:        30 * 24 * time.Hour,
+		VerificationTTL:   24 * time.Hour,
+		UnverifiedTTL:     7 * 24 * time.Hour,
+		PasswordResetTTL:  time.Hour,
+		DeleteGracePeriod: 30 * 24 * time.Hour,
+		Storage: StorageConfig{
+			Driver:      StorageDriver(getEnv("STORAGE_DRIVER", string(StorageDriverLocal))),
+			LocalPath:   os.Getenv("FILE_SOTRAGE_PATH"),
+			S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+			S3Region:    getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:    os.Getenv("S3_BUCKET"),
+			S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		},
+		Mail: MailConfig{
+			Driver:       MailDriver(getEnv("MAIL_DRIVER", string(MailDriverLog))),
+			From:         getEnv("MAIL_FROM", "Books App <no-reply@localhost>"),
+			FilePath:     getEnv("MAIL_FILE_PATH", "mails"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     1025,
+			SMTPUsername: os.Getenv("SMTP_USERNAME"),
+			SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+			Retries:      3,
+			RetryBackoff: time.Second,
+			QueueSize:    100,
+		},
+		Lockout: LockoutConfig{
+			MaxFailures:   5,
+			BaseLockout:   time.Minute,
+			MaxLockout:    time.Hour,
+			FailureWindow: 24 * time.Hour,
+		},
+		Password: PasswordConfig{
+			MinLength:      8,
+			MaxBytes:       72,
+			RequireMixed:   true,
+			RejectIdentity: true,
+			BreachedPath:   os.Getenv("BREACHED_PASSWORDS_PATH"),
+			Hash:           PasswordHash(getEnv("PASSWORD_HASH", string(PasswordHashArgon2id))),
+			BcryptCost:     10,
+
+			Argon2Memory:  64 * 1024,
+			Argon2Time:    3,
+			Argon2Threads: 2,
+		},
+		OIDC: OIDCConfig{
+			StateTTL:     10 * time.Minute,
+			TwoFactorTTL: 5 * time.Minute,
+		},
+	}
+
+	if c.DatabaseURL == "" {
+		return Config{}, ErrMissingDatabaseURL
+	}
+
+	switch c.Storage.Driver {
+	case StorageDriverLocal, StorageDriverMemory, StorageDriverS3:
+	default:
+		return Config{}, ErrUnknownStorage
+	}
+
+	// an empty path would write the files under the file system root
+	if c.Storage.Driver == StorageDriverLocal && c.Storage.LocalPath == "" {
+		return Config{}, ErrMissingStoragePath
+	}
+
+	switch c.Mail.Driver {
+	case MailDriverLog, MailDriverFile, MailDriverSMTP:
+	default:
+		return Config{}, ErrUnknownMailer
+	}
+
+	switch c.Password.Hash {
+	case PasswordHashArgon2id, PasswordHashBcrypt:
+	default:
+		return Config{}, ErrUnknownPasswordHash
+	}
+
+	var err error
+
+	ints := map[string]*int{
+		"DATABASE_MAX_CONNS":   &c.DatabaseMaxConns,
+		"SMTP_PORT":            &c.Mail.SMTPPort,
+		"MAIL_RETRIES":         &c.Mail.Retries,
+		"MAIL_QUEUE_SIZE":      &c.Mail.QueueSize,
+		"SIGN_IN_MAX_FAILURES": &c.Lockout.MaxFailures,
+		"PASSWORD_MIN_LENGTH":  &c.Password.MinLength,
+		"PASSWORD_MAX_BYTES":   &c.Password.MaxBytes,
+		"BCRYPT_COST":          &c.Password.BcryptCost,
+		"ARGON2_MEMORY":        &c.Password.Argon2Memory,
+		"ARGON2_TIME":          &c.Password.Argon2Time,
+		"ARGON2_THREADS":       &c.Password.Argon2Threads,
+	}
+
+	for key, n := range ints {
+		v := os.Getenv(key)
+
+		if v == "" {
+			continue
+		}
+
+		*n, err = strconv.Atoi(v)
+
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid config: %s: %w", key, err)
+		}
+	}
+
+	bools := map[string]*bool{
+		"PASSWORD_REQUIRE_MIXED":   &c.Password.RequireMixed,
+		"PASSWORD_REJECT_IDENTITY": &c.Password.RejectIdentity,
+	}
+
+	for key, b := range bools {
+		v := os.Getenv(key)
+
+		if v == "" {
+			continue
+		}
+
+		*b, err = strconv.ParseBool(v)
+
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid config: %s: %w", key, err)
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"SHUTDOWN_TIMEOUT":       &c.ShutdownTimeout,
+		"SESSION_TTL":            &c.SessionTTL,
+		"VERIFICATION_TTL":       &c.VerificationTTL,
+		"UNVERIFIED_TTL":         &c.UnverifiedTTL,
+		"PASSWORD_RESET_TTL":     &c.PasswordResetTTL,
+		"DELETE_GRACE_PERIOD":    &c.DeleteGracePeriod,
+		"MAIL_RETRY_BACKOFF":     &c.Mail.RetryBackoff,
+		"SIGN_IN_BASE_LOCKOUT":   &c.Lockout.BaseLockout,
+		"SIGN_IN_MAX_LOCKOUT":    &c.Lockout.MaxLockout,
+		"SIGN_IN_FAILURE_WINDOW": &c.Lockout.FailureWindow,
+		"OIDC_STATE_TTL":         &c.OIDC.StateTTL,
+		"OIDC_TWO_FACTOR_TTL":    &c.OIDC.TwoFactorTTL,
+	}
+
+	for key, d := range durations {
+		v := os.Getenv(key)
+
+		if v == "" {
+			continue
+		}
+
+		*d, err = time.ParseDuration(v)
+
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid config: %s: %w", key, err)
+		}
+	}
+
+	c.OIDC.Providers, err = loadOIDCProviders(c.AppURL)
+
+	if err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// reads the providers listed in OIDC_PROVIDERS, every provider is configured
+// with the OIDC_<NAME>_* variables
+func loadOIDCProviders(appURL string) ([]OIDCProviderConfig, error) {
+	providers := make([]OIDCProviderConfig, 0)
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		p := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", strings.TrimSuffix(appURL, "/")+"/oidc/"+name+"/callback"),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "email profile")),
+		}
+
+		if p.Issuer == "" || p.ClientID == "" {
+			return nil, fmt.Errorf("missing config: %sISSUER and %sCLIENT_ID must be set", prefix, prefix)
+		}
+
+		providers = append(providers, p)
+	}
+
+	return providers, nil
+}
+
+func getEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+
+	return fallback
+}
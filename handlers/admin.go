@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/marlonmp/books-app/payloads"
+)
+
+func (h handler) listLockouts(w http.ResponseWriter, r *http.Request) {
+	var search, orderBy string
+	var limit, offset int
+
+	err := parseListFilters(r, &search, &orderBy, &limit, &offset)
+
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	lockouts, err := h.lockouts.ListLockouts(r.Context(), currentActor(r), limit, offset)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lockouts)
+}
+
+func (h handler) assignRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	var payload payloads.RoleAssignment
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	user, err := h.users.AssignRole(r.Context(), currentActor(r), id, payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h handler) banUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	var payload payloads.UserBan
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	user, err := h.moderation.BanUser(r.Context(), currentActor(r), id, payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h handler) unbanUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	var payload payloads.ModerationReason
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	user, err := h.moderation.UnbanUser(r.Context(), currentActor(r), id, payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	var payload payloads.ModerationReason
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	err := h.moderation.DeleteUser(r.Context(), currentActor(r), id, payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h handler) restoreUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	var payload payloads.ModerationReason
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	user, err := h.moderation.RestoreUser(r.Context(), currentActor(r), id, payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h handler) listModerationEvents(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	events, err := h.moderation.ListModerationEvents(r.Context(), currentActor(r), id)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
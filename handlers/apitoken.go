@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/marlonmp/books-app/payloads"
+)
+
+func (h handler) listAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.apiTokens.ListTokens(r.Context(), currentUserID(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+func (h handler) createAPIToken(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.APITokenCreate
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	token, err := h.apiTokens.CreateToken(r.Context(), currentUserID(r), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, token)
+}
+
+func (h handler) revokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	err := h.apiTokens.RevokeToken(r.Context(), currentUserID(r), id)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/policy"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/services"
+)
+
+type ctxKey uint8
+
+const identityKey ctxKey = iota
+
+const bearerPrefix = "Bearer "
+
+// noScope marks the routes that only accept sessions, the api tokens can't be
+// used to manage the account
+const noScope = ""
+
+// authenticated only lets pass the requests with a valid session token, the
+// identity of the authenticated user is stored in the request context
+func (h handler) authenticated(next http.HandlerFunc) http.Handler {
+	return h.authorized(noScope, next)
+}
+
+// authorized works as authenticated, but also lets pass the api tokens that
+// have the given scope
+func (h handler) authorized(scope string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, err := h.authenticate(r, scope)
+
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="books-app"`)
+			writeError(w, err)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// identified works as authorized, but lets pass the anonymous requests
+func (h handler) identified(scope string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bearerToken(r) == "" {
+			next(w, r)
+			return
+		}
+
+		h.authorized(scope, next).ServeHTTP(w, r)
+	})
+}
+
+// checks the bearer token and returns the request with the identity in its
+// context, the api tokens must have the given scope
+func (h handler) authenticate(r *http.Request, scope string) (*http.Request, error) {
+	token := bearerToken(r)
+
+	var identity payloads.Identity
+	var err error
+
+	if services.IsAPIToken(token) {
+		identity, err = h.apiTokens.Authenticate(r.Context(), token)
+
+		if err == nil && (scope == noScope || !identity.HasScope(scope)) {
+			err = repos.PermissionDeniedError{}
+		}
+	} else {
+		identity, err = h.sessions.Authenticate(r.Context(), token)
+	}
+
+	if err != nil {
+		return r, err
+	}
+
+	ctx := context.WithValue(r.Context(), identityKey, identity)
+
+	return r.WithContext(ctx), nil
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+
+	if len(auth) < len(bearerPrefix) || !strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(auth[len(bearerPrefix):])
+}
+
+// returns the identity of the authenticated user, or an empty identity for
+// the anonymous requests
+func currentIdentity(r *http.Request) payloads.Identity {
+	identity, _ := r.Context().Value(identityKey).(payloads.Identity)
+	return identity
+}
+
+// returns the id of the authenticated user, or [uuid.Nil] for the anonymous
+// requests
+func currentUserID(r *http.Request) uuid.UUID {
+	return currentIdentity(r).UserID
+}
+
+// returns the authenticated user as the actor of the policies, or an anonymous
+// actor for the anonymous requests
+func currentActor(r *http.Request) policy.Actor {
+	identity := currentIdentity(r)
+
+	return policy.Actor{UserID: identity.UserID, Role: identity.Role}
+}
+
+func clientInfo(r *http.Request) payloads.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return payloads.ClientInfo{UserAgent: r.UserAgent(), IP: ip}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/services"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+// the fakes embed the repos and services, so the methods not used by the
+// authentication panic
+
+type authUsers struct {
+	repos.UserRepo
+
+	users map[uuid.UUID]models.User
+}
+
+func (au authUsers) GetByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (models.User, error) {
+	u, ok := au.users[id]
+
+	if !ok || u.Status != status {
+		return models.User{}, repos.NotFoundError{}
+	}
+
+	return u, nil
+}
+
+// authTokens finds the tokens as the sql of the repo, the expired tokens and
+// the tokens of the users with other status are not found
+type authTokens struct {
+	repos.APITokenRepo
+
+	users  authUsers
+	tokens []models.APIToken
+}
+
+func (at authTokens) UseByTokenHash(ctx context.Context, tokenHash string, userStatus models.UserStatus) (models.APIToken, error) {
+	for _, t := range at.tokens {
+		if t.TokenHash != tokenHash || (t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now())) {
+			continue
+		}
+
+		if u, ok := at.users.users[t.UserID]; ok && u.Status == userStatus {
+			return t, nil
+		}
+	}
+
+	return models.APIToken{}, repos.NotFoundError{}
+}
+
+const testSessionToken = "session token"
+
+type authSessions struct {
+	services.SessionService
+
+	userID uuid.UUID
+}
+
+func (as authSessions) Authenticate(ctx context.Context, token string) (payloads.Identity, error) {
+	if token != testSessionToken {
+		return payloads.Identity{}, repos.InvalidCredentialsError{}
+	}
+
+	return payloads.Identity{UserID: as.userID, SessionID: uuid.New(), Role: models.RoleAuthor}, nil
+}
+
+func TestAuthorized(t *testing.T) {
+	jane := models.User{ID: uuid.New(), Status: models.UserStatusActive, Role: models.RoleAuthor}
+	banned := models.User{ID: uuid.New(), Status: models.UserStatusBanned, Role: models.RoleAuthor}
+
+	users := authUsers{users: map[uuid.UUID]models.User{jane.ID: jane, banned.ID: banned}}
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	// returns a plain api token and adds its hash to the tokens
+	var tokens []models.APIToken
+
+	newToken := func(userID uuid.UUID, expiresAt *time.Time, scopes ...string) string {
+		plain := services.APITokenPrefix + uuid.NewString()
+		hash := valobjs.TokenFromString(plain).Hash()
+
+		tokens = append(tokens, models.APIToken{ID: uuid.New(), UserID: userID, TokenHash: hash, Scopes: scopes, ExpiresAt: expiresAt})
+
+		return plain
+	}
+
+	readToken := newToken(jane.ID, nil, models.ScopeBooksRead)
+	writeToken := newToken(jane.ID, &future, models.ScopeBooksRead, models.ScopeBooksWrite)
+	expiredToken := newToken(jane.ID, &past, models.ScopeBooksRead, models.ScopeBooksWrite)
+	bannedToken := newToken(banned.ID, nil, models.ScopeBooksRead, models.ScopeBooksWrite)
+
+	h := handler{
+		sessions:  authSessions{userID: jane.ID},
+		apiTokens: services.NewAPITokenService(authTokens{users: users, tokens: tokens}, users),
+	}
+
+	tests := []struct {
+		name       string
+		scope      string
+		token      string
+		wantStatus int
+	}{
+		{"session on a scoped route", models.ScopeBooksWrite, testSessionToken, http.StatusOK},
+		{"session on a session route", noScope, testSessionToken, http.StatusOK},
+		{"invalid session", noScope, "other token", http.StatusUnauthorized},
+		{"no token", models.ScopeBooksRead, "", http.StatusUnauthorized},
+		{"token with the scope", models.ScopeBooksRead, readToken, http.StatusOK},
+		{"token with many scopes", models.ScopeBooksWrite, writeToken, http.StatusOK},
+		{"token without the scope", models.ScopeBooksWrite, readToken, http.StatusForbidden},
+		{"token on a session route", noScope, writeToken, http.StatusForbidden},
+		{"expired token", models.ScopeBooksRead, expiredToken, http.StatusUnauthorized},
+		{"token of a banned user", models.ScopeBooksRead, bannedToken, http.StatusUnauthorized},
+		{"unknown token", models.ScopeBooksRead, services.APITokenPrefix + "unknown", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identity payloads.Identity
+
+			next := func(w http.ResponseWriter, r *http.Request) {
+				identity = currentIdentity(r)
+				w.WriteHeader(http.StatusOK)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+
+			h.authorized(tt.scope, next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				if rec.Header().Get("WWW-Authenticate") == "" {
+					t.Error("the rejected request has no WWW-Authenticate header")
+				}
+
+				return
+			}
+
+			if identity.UserID != jane.ID || identity.Role != jane.Role {
+				t.Errorf("identity = %+v, want the one of the user", identity)
+			}
+		})
+	}
+}
+
+func TestIdentified(t *testing.T) {
+	h := handler{sessions: authSessions{userID: uuid.New()}}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"anonymous", "", http.StatusOK},
+		{"session", testSessionToken, http.StatusOK},
+
+		// a wrong token is not taken as anonymous
+		{"invalid token", "other token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tt.token != "" {
+				req.Header.Set("Authorization", "bearer "+tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+
+			h.identified(models.ScopeBooksRead, next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
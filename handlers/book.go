@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/policy"
+	"github.com/marlonmp/books-app/repos"
+)
+
+func (h handler) listBooks(w http.ResponseWriter, r *http.Request) {
+	bf := &repos.BookFilters{}
+
+	var pf repos.PageFilters
+
+	err := parsePageFilters(r, &bf.Search, &bf.OrderBy, &pf)
+
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+
+	if v := q.Get("author_id"); v != "" {
+		bf.AuthorID, err = uuid.Parse(v)
+
+		if err != nil {
+			writeBadRequest(w, "author_id: "+err.Error())
+			return
+		}
+	}
+
+	if v := q.Get("ids"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			id, err := uuid.Parse(strings.TrimSpace(s))
+
+			if err != nil {
+				writeBadRequest(w, "ids: "+err.Error())
+				return
+			}
+
+			bf.BookIDs = append(bf.BookIDs, id)
+		}
+	}
+
+	bf.AuthorUsername = q.Get("author")
+
+	err = parseBookRanges(r, bf)
+
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	// the catalog only shows the public books, with their authors
+	bf.Status = models.BookStatusPublic
+	bf.WithAuthor = true
+
+	page, err := h.books.ListBooks(r.Context(), bf, pf)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parses the date ranges of the book list and whether the books have a file or
+// a cover
+func parseBookRanges(r *http.Request, bf *repos.BookFilters) error {
+	times := []struct {
+		name string
+		t    *time.Time
+	}{
+		{"created_after", &bf.CreatedAfter},
+		{"created_before", &bf.CreatedBefore},
+		{"updated_after", &bf.UpdatedAfter},
+		{"updated_before", &bf.UpdatedBefore},
+	}
+
+	for _, param := range times {
+		err := parseTimeParam(r, param.name, param.t)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err := parseBoolParam(r, "has_file", &bf.HasFile)
+
+	if err != nil {
+		return err
+	}
+
+	return parseBoolParam(r, "has_cover", &bf.HasCover)
+}
+
+func (h handler) listMyBooks(w http.ResponseWriter, r *http.Request) {
+	var statuses []models.BookStatus
+
+	// the status can be repeated to list the books with any of them
+	for _, v := range r.URL.Query()["status"] {
+		n, err := strconv.ParseUint(v, 10, 8)
+
+		if err != nil {
+			writeBadRequest(w, "status: "+err.Error())
+			return
+		}
+
+		statuses = append(statuses, models.BookStatus(n))
+	}
+
+	books, err := h.books.ListAuthorBooks(r.Context(), currentUserID(r), statuses)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, books)
+}
+
+func (h handler) getBook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	book, err := h.books.GetBook(r.Context(), currentActor(r), id)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, book)
+}
+
+func (h handler) createBook(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.BookCreate
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	book, err := h.books.CreateDraft(r.Context(), currentActor(r), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, book)
+}
+
+func (h handler) updateBook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	var payload payloads.BookUpdate
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	book, err := h.books.UpdateBook(r.Context(), currentActor(r), id, payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, book)
+}
+
+func (h handler) publishBook(w http.ResponseWriter, r *http.Request) {
+	h.changeBookStatus(w, r, h.books.PublishBook)
+}
+
+func (h handler) makeBookPrivate(w http.ResponseWriter, r *http.Request) {
+	h.changeBookStatus(w, r, h.books.MakeBookPrivate)
+}
+
+func (h handler) deleteBook(w http.ResponseWriter, r *http.Request) {
+	h.changeBookStatus(w, r, h.books.DeleteBook)
+}
+
+func (h handler) restoreBook(w http.ResponseWriter, r *http.Request) {
+	h.changeBookStatus(w, r, h.books.RestoreBook)
+}
+
+type bookStatusChanger func(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error)
+
+func (h handler) changeBookStatus(w http.ResponseWriter, r *http.Request, change bookStatusChanger) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	book, err := change(r.Context(), currentActor(r), id)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, book)
+}
+
+func (h handler) uploadBookFile(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	upload, ok := readUpload(w, r, maxBookFileSize)
+
+	if !ok {
+		return
+	}
+
+	book, err := h.books.UploadBookFile(r.Context(), currentActor(r), id, upload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, book)
+}
+
+func (h handler) uploadCoverFile(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	upload, ok := readUpload(w, r, maxCoverFileSize)
+
+	if !ok {
+		return
+	}
+
+	book, err := h.books.UploadCoverFile(r.Context(), currentActor(r), id, upload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, book)
+}
+
+func (h handler) downloadBookFile(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	download, err := h.books.OpenBookFile(r.Context(), currentActor(r), id)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	serveDownload(w, r, download)
+}
+
+func (h handler) downloadCoverFile(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	download, err := h.books.OpenCoverFile(r.Context(), currentActor(r), id)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	serveDownload(w, r, download)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/marlonmp/books-app/payloads"
+)
+
+const (
+	maxBookFileSize  = 100 << 20
+	maxCoverFileSize = 5 << 20
+
+	// name of the multipart field that contains the file
+	uploadFormField = "file"
+)
+
+// returns the uploaded file from a multipart form or from the raw body, the
+// content is streamed from the request body so it's only valid while the
+// request is being handled. If the upload is not valid writes a bad request
+func readUpload(w http.ResponseWriter, r *http.Request, maxSize int64) (payloads.FileUpload, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType != "multipart/form-data" {
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Disposition"))
+
+		return payloads.FileUpload{Filename: params["filename"], Content: r.Body}, true
+	}
+
+	upload, err := multipartUpload(r)
+
+	if err != nil {
+		writeBadRequest(w, "file: "+err.Error())
+		return payloads.FileUpload{}, false
+	}
+
+	return upload, true
+}
+
+// returns the first part with the upload field name, the previous parts are
+// skipped
+func multipartUpload(r *http.Request) (payloads.FileUpload, error) {
+	mr, err := r.MultipartReader()
+
+	if err != nil {
+		return payloads.FileUpload{}, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+
+		if errors.Is(err, io.EOF) {
+			return payloads.FileUpload{}, errors.New("missing form field " + uploadFormField)
+		}
+
+		if err != nil {
+			return payloads.FileUpload{}, err
+		}
+
+		if part.FormName() == uploadFormField {
+			return payloads.FileUpload{Filename: part.FileName(), Content: part}, nil
+		}
+
+		part.Close()
+	}
+}
+
+// streams the file, the range and conditional requests are handled by
+// [http.ServeContent]
+func serveDownload(w http.ResponseWriter, r *http.Request, download payloads.FileDownload) {
+	defer download.Content.Close()
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": download.Filename}))
+
+	http.ServeContent(w, r, download.Filename, download.ModTime, download.Content)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/services"
+)
+
+type handler struct {
+	users      services.UserService
+	books      services.BookService
+	sessions   services.SessionService
+	apiTokens  services.APITokenService
+	oidc       services.OIDCService
+	lockouts   services.LockoutService
+	moderation services.ModerationService
+}
+
+// New returns the http handler with all the api routes
+func New(users services.UserService, books services.BookService, sessions services.SessionService, apiTokens services.APITokenService, oidc services.OIDCService, lockouts services.LockoutService, moderation services.ModerationService) http.Handler {
+	h := handler{users, books, sessions, apiTokens, oidc, lockouts, moderation}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /sign-up", h.signUp)
+	mux.HandleFunc("POST /verify-email", h.verifyEmail)
+	mux.HandleFunc("POST /verify-email/resend", h.resendVerification)
+	mux.HandleFunc("POST /sign-in", h.signIn)
+	mux.HandleFunc("POST /forgot-password", h.forgotPassword)
+	mux.HandleFunc("POST /reset-password", h.resetPassword)
+	mux.HandleFunc("GET /oidc/providers", h.listOIDCProviders)
+	mux.HandleFunc("POST /oidc/{provider}/start", h.startOIDCSignIn)
+	mux.HandleFunc("POST /oidc/{provider}/callback", h.oidcSignIn)
+	mux.HandleFunc("POST /oidc/two-factor", h.oidcTwoFactor)
+	mux.Handle("POST /sign-out", h.authenticated(h.signOut))
+	mux.Handle("POST /sign-out/all", h.authenticated(h.signOutAll))
+
+	mux.Handle("GET /me/sessions", h.authenticated(h.listSessions))
+	mux.Handle("DELETE /me/sessions/{id}", h.authenticated(h.revokeSession))
+
+	mux.Handle("GET /me/tokens", h.authenticated(h.listAPITokens))
+	mux.Handle("POST /me/tokens", h.authenticated(h.createAPIToken))
+	mux.Handle("DELETE /me/tokens/{id}", h.authenticated(h.revokeAPIToken))
+
+	mux.Handle("GET /me/identities", h.authenticated(h.listIdentities))
+	mux.Handle("POST /me/identities/{provider}/start", h.authenticated(h.startOIDCLink))
+	mux.Handle("POST /me/identities/{provider}/callback", h.authenticated(h.oidcLink))
+	mux.Handle("DELETE /me/identities/{provider}", h.authenticated(h.oidcUnlink))
+
+	mux.Handle("POST /me/two-factor", h.authenticated(h.enrollTwoFactor))
+	mux.Handle("POST /me/two-factor/enable", h.authenticated(h.enableTwoFactor))
+	mux.Handle("POST /me/two-factor/disable", h.authenticated(h.disableTwoFactor))
+	mux.Handle("POST /me/two-factor/recovery-codes", h.authenticated(h.regenerateRecoveryCodes))
+
+	mux.HandleFunc("GET /users", h.listUsers)
+	mux.HandleFunc("GET /users/{username}", h.userProfile)
+
+	mux.HandleFunc("GET /books", h.listBooks)
+	mux.Handle("GET /books/{id}", h.identified(models.ScopeBooksRead, h.getBook))
+	mux.Handle("POST /books", h.authorized(models.ScopeBooksWrite, h.createBook))
+	mux.Handle("PATCH /books/{id}", h.authorized(models.ScopeBooksWrite, h.updateBook))
+	mux.Handle("POST /books/{id}/publish", h.authorized(models.ScopeBooksWrite, h.publishBook))
+	mux.Handle("POST /books/{id}/private", h.authorized(models.ScopeBooksWrite, h.makeBookPrivate))
+	mux.Handle("DELETE /books/{id}", h.authorized(models.ScopeBooksWrite, h.deleteBook))
+	mux.Handle("POST /books/{id}/restore", h.authorized(models.ScopeBooksWrite, h.restoreBook))
+	mux.Handle("PUT /books/{id}/file", h.authorized(models.ScopeBooksWrite, h.uploadBookFile))
+	mux.Handle("PUT /books/{id}/cover", h.authorized(models.ScopeBooksWrite, h.uploadCoverFile))
+	mux.Handle("GET /books/{id}/file", h.identified(models.ScopeBooksRead, h.downloadBookFile))
+	mux.Handle("GET /books/{id}/cover", h.identified(models.ScopeBooksRead, h.downloadCoverFile))
+
+	mux.Handle("PATCH /me", h.authorized(models.ScopeProfileWrite, h.updateProfile))
+	mux.Handle("GET /me/books", h.authorized(models.ScopeBooksRead, h.listMyBooks))
+
+	mux.Handle("GET /admin/lockouts", h.authenticated(h.listLockouts))
+	mux.Handle("PUT /admin/users/{id}/role", h.authenticated(h.assignRole))
+	mux.Handle("POST /admin/users/{id}/ban", h.authenticated(h.banUser))
+	mux.Handle("POST /admin/users/{id}/unban", h.authenticated(h.unbanUser))
+	mux.Handle("DELETE /admin/users/{id}", h.authenticated(h.deleteUser))
+	mux.Handle("POST /admin/users/{id}/restore", h.authenticated(h.restoreUser))
+	mux.Handle("GET /admin/users/{id}/moderation", h.authenticated(h.listModerationEvents))
+
+	return mux
+}
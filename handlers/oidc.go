@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/marlonmp/books-app/payloads"
+)
+
+func (h handler) listOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.oidc.Providers())
+}
+
+func (h handler) startOIDCSignIn(w http.ResponseWriter, r *http.Request) {
+	redirect, err := h.oidc.StartSignIn(r.Context(), r.PathValue("provider"))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, redirect)
+}
+
+func (h handler) oidcSignIn(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.OIDCCallback
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	session, err := h.oidc.SignIn(r.Context(), r.PathValue("provider"), payload, clientInfo(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, session)
+}
+
+func (h handler) oidcTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.OIDCTwoFactor
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	session, err := h.oidc.SignInTwoFactor(r.Context(), payload, clientInfo(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, session)
+}
+
+func (h handler) listIdentities(w http.ResponseWriter, r *http.Request) {
+	identities, err := h.oidc.ListIdentities(r.Context(), currentUserID(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, identities)
+}
+
+func (h handler) startOIDCLink(w http.ResponseWriter, r *http.Request) {
+	redirect, err := h.oidc.StartLink(r.Context(), currentUserID(r), r.PathValue("provider"))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, redirect)
+}
+
+func (h handler) oidcLink(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.OIDCCallback
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	identity, err := h.oidc.Link(r.Context(), currentUserID(r), r.PathValue("provider"), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, identity)
+}
+
+func (h handler) oidcUnlink(w http.ResponseWriter, r *http.Request) {
+	err := h.oidc.Unlink(r.Context(), currentUserID(r), r.PathValue("provider"))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/repos"
+)
+
+// parses the common list query params: search, order_by, limit and offset
+func parseListFilters(r *http.Request, search, orderBy *string, limit, offset *int) error {
+	q := r.URL.Query()
+
+	*search = q.Get("search")
+	*orderBy = q.Get("order_by")
+
+	var err error
+
+	if v := q.Get("limit"); v != "" {
+		*limit, err = strconv.Atoi(v)
+
+		if err != nil || *limit < 0 {
+			return errors.New("limit: must be a positive integer")
+		}
+	}
+
+	if v := q.Get("offset"); v != "" {
+		*offset, err = strconv.Atoi(v)
+
+		if err != nil || *offset < 0 {
+			return errors.New("offset: must be a positive integer")
+		}
+	}
+
+	return nil
+}
+
+// parses the query params of a paged list: search, order_by, cursor, limit
+// and total, the limits over the max are lowered to it
+func parsePageFilters(r *http.Request, search, orderBy *string, pf *repos.PageFilters) error {
+	q := r.URL.Query()
+
+	*search = q.Get("search")
+	*orderBy = q.Get("order_by")
+
+	pf.Cursor = q.Get("cursor")
+
+	var err error
+
+	if v := q.Get("limit"); v != "" {
+		pf.Limit, err = strconv.Atoi(v)
+
+		if err != nil || pf.Limit < 0 {
+			return errors.New("limit: must be a positive integer")
+		}
+	}
+
+	if v := q.Get("total"); v != "" {
+		pf.WithTotal, err = strconv.ParseBool(v)
+
+		if err != nil {
+			return errors.New("total: must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+// parses the query param with the given name as a RFC 3339 time, if it's
+// given
+func parseTimeParam(r *http.Request, name string, t *time.Time) error {
+	v := r.URL.Query().Get(name)
+
+	if v == "" {
+		return nil
+	}
+
+	var err error
+
+	*t, err = time.Parse(time.RFC3339, v)
+
+	if err != nil {
+		return errors.New(name + ": must be a RFC 3339 time")
+	}
+
+	return nil
+}
+
+// parses the query param with the given name as a boolean, b is nil if the
+// param is not given
+func parseBoolParam(r *http.Request, name string, b **bool) error {
+	v := r.URL.Query().Get(name)
+
+	if v == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(v)
+
+	if err != nil {
+		return errors.New(name + ": must be a boolean")
+	}
+
+	*b = &parsed
+
+	return nil
+}
+
+// parses the {id} path value, if it's not valid writes a bad request
+func pathID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+
+	if err != nil {
+		writeBadRequest(w, "id: "+err.Error())
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
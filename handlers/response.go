@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/marlonmp/books-app/repos"
+)
+
+// max size of the json bodies
+const maxBodySize = 1 << 20
+
+const (
+	invalidPayloadCode  = "invalid_payload"
+	payloadTooLargeCode = "payload_too_large"
+	internalErrorCode   = "internal_error"
+)
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	Fields []repos.FieldError `json:"fields,omitempty"`
+
+	// see [repos.TwoFactorRequiredError]
+	Ticket string `json:"ticket,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+
+	if err != nil {
+		log.Printf("handlers: writing response: %v", err)
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+
+	err := dec.Decode(v)
+
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Code: invalidPayloadCode, Message: err.Error()})
+		return false
+	}
+
+	return true
+}
+
+// writes the error with the status that matches with its code, the unknown
+// errors are logged and never sent to the client
+func writeError(w http.ResponseWriter, err error) {
+	var mbe *http.MaxBytesError
+
+	if errors.As(err, &mbe) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorBody{Code: payloadTooLargeCode, Message: mbe.Error()})
+		return
+	}
+
+	var ce repos.CodedError
+
+	if !errors.As(err, &ce) {
+		log.Printf("handlers: %v", err)
+		writeJSON(w, http.StatusInternalServerError, errorBody{Code: internalErrorCode, Message: "internal server error"})
+		return
+	}
+
+	status := http.StatusInternalServerError
+
+	switch ce.Code() {
+	case repos.NotFoundErrorCode, repos.DoesNotExistErrorCode:
+		status = http.StatusNotFound
+	case repos.ConflictErrorCode, repos.InvalidStatusTransitionErrorCode:
+		status = http.StatusConflict
+	case repos.InvalidCredentialsErrorCode, repos.MissingCredentialsErrorCode, repos.TwoFactorRequiredErrorCode:
+		status = http.StatusUnauthorized
+	case repos.PermissionDeniedErrorCode:
+		status = http.StatusForbidden
+	case repos.UnsupportedFileErrorCode:
+		status = http.StatusUnsupportedMediaType
+	case repos.TooManyAttemptsErrorCode:
+		status = http.StatusTooManyRequests
+	case repos.ValidationErrorCode:
+		status = http.StatusUnprocessableEntity
+	case repos.UnknownSortFieldErrorCode, repos.InvalidCursorErrorCode:
+		status = http.StatusBadRequest
+	}
+
+	var tmae repos.TooManyAttemptsError
+
+	if errors.As(err, &tmae) {
+		seconds := int(math.Ceil(tmae.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	body := errorBody{Code: ce.Code().String(), Message: ce.Error()}
+
+	var ve repos.ValidationError
+
+	if errors.As(err, &ve) {
+		body.Fields = ve.Fields
+	}
+
+	var tfre repos.TwoFactorRequiredError
+
+	if errors.As(err, &tfre) {
+		body.Ticket = tfre.Ticket
+	}
+
+	writeJSON(w, status, body)
+}
+
+func writeBadRequest(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusBadRequest, errorBody{Code: invalidPayloadCode, Message: message})
+}
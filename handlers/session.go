@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func (h handler) signOut(w http.ResponseWriter, r *http.Request) {
+	identity := currentIdentity(r)
+
+	err := h.sessions.SignOut(r.Context(), identity.UserID, identity.SessionID)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h handler) signOutAll(w http.ResponseWriter, r *http.Request) {
+	err := h.sessions.SignOutAll(r.Context(), currentUserID(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h handler) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.sessions.ListSessions(r.Context(), currentIdentity(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (h handler) revokeSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+
+	if !ok {
+		return
+	}
+
+	err := h.sessions.SignOut(r.Context(), currentUserID(r), id)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/marlonmp/books-app/payloads"
+)
+
+func (h handler) enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := h.users.EnrollTwoFactor(r.Context(), currentUserID(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, enrollment)
+}
+
+func (h handler) enableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.TwoFactorCode
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	codes, err := h.users.EnableTwoFactor(r.Context(), currentUserID(r), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, codes)
+}
+
+func (h handler) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.TwoFactorReauth
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	err := h.users.DisableTwoFactor(r.Context(), currentUserID(r), payload, clientInfo(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h handler) regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.TwoFactorReauth
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	codes, err := h.users.RegenerateRecoveryCodes(r.Context(), currentUserID(r), payload, clientInfo(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, codes)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+)
+
+func (h handler) signUp(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.UserCreate
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	user, err := h.users.SignUp(r.Context(), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (h handler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.EmailVerification
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	user, err := h.users.VerifyEmail(r.Context(), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h handler) resendVerification(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.EmailRequest
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	err := h.users.ResendVerification(r.Context(), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// the same response is sent if the email does not exist
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h handler) signIn(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.UserCredentials
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	session, err := h.users.SignIn(r.Context(), payload, clientInfo(r))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, session)
+}
+
+func (h handler) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.EmailRequest
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	err := h.users.ForgotPassword(r.Context(), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// the same response is sent if the email does not exist
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h handler) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.PasswordReset
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	err := h.users.ResetPassword(r.Context(), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	uf := &repos.UserFilters{}
+
+	var pf repos.PageFilters
+
+	err := parsePageFilters(r, &uf.Search, &uf.OrderBy, &pf)
+
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	// only the active users are listed
+	uf.Status = models.UserStatusActive
+
+	page, err := h.users.ListUsers(r.Context(), uf, pf)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (h handler) updateProfile(w http.ResponseWriter, r *http.Request) {
+	var payload payloads.UserUpdate
+
+	if !readJSON(w, r, &payload) {
+		return
+	}
+
+	user, err := h.users.UpdateProfile(r.Context(), currentUserID(r), payload)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h handler) userProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.users.UserProfile(r.Context(), r.PathValue("username"))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profile)
+}
@@ -0,0 +1,139 @@
+package mails
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull   = errors.New("mails: the queue is full")
+	ErrQueueClosed = errors.New("mails: the queue is closed")
+)
+
+type retryMailer struct {
+	mailer   Mailer
+	attempts int
+	backoff  time.Duration
+}
+
+// Retrying returns a mailer that retries the failed sends, waiting backoff
+// before the first retry and doubling the wait on every retry
+func Retrying(m Mailer, attempts int, backoff time.Duration) Mailer {
+	return retryMailer{m, max(attempts, 1), backoff}
+}
+
+func (rm retryMailer) Send(ctx context.Context, m Mail) error {
+	wait := rm.backoff
+
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		err = rm.mailer.Send(ctx, m)
+
+		if err == nil || attempt == rm.attempts {
+			return err
+		}
+
+		select {
+		case <-time.After(wait):
+			wait *= 2
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// AsyncMailer sends the mails in background, so the sends never block the
+// requests. The mails that fail are logged
+type AsyncMailer struct {
+	mailer Mailer
+	queue  chan Mail
+
+	mu     sync.RWMutex
+	closed bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Async returns a mailer that queues the mails and sends them with the given
+// number of workers, it must be closed to send the queued mails
+func Async(m Mailer, queueSize, workers int) *AsyncMailer {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	am := &AsyncMailer{
+		mailer: m,
+		queue:  make(chan Mail, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for range max(workers, 1) {
+		am.wg.Add(1)
+		go am.work()
+	}
+
+	return am
+}
+
+// Send queues the mail, if the queue is full returns [ErrQueueFull] instead of
+// waiting
+func (am *AsyncMailer) Send(ctx context.Context, m Mail) error {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	if am.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case am.queue <- m:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (am *AsyncMailer) work() {
+	defer am.wg.Done()
+
+	for m := range am.queue {
+		err := am.mailer.Send(am.ctx, m)
+
+		if err != nil {
+			log.Printf("mails: sending %q to %s: %v", m.Subject, m.To, err)
+		}
+	}
+}
+
+// Close stops accepting mails and waits until the queued mails are sent, if
+// the context is done first the pending sends are canceled
+func (am *AsyncMailer) Close(ctx context.Context) error {
+	am.mu.Lock()
+
+	if !am.closed {
+		am.closed = true
+		close(am.queue)
+	}
+
+	am.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		am.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		am.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,142 @@
+package mails
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fails the first sends and then sends to the memory mailer
+type flakyMailer struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	sent     *MemoryMailer
+}
+
+func (fm *flakyMailer) Send(ctx context.Context, m Mail) error {
+	fm.mu.Lock()
+	fm.calls++
+	fail := fm.calls <= fm.failures
+	fm.mu.Unlock()
+
+	if fail {
+		return errors.New("temporary failure")
+	}
+
+	return fm.sent.Send(ctx, m)
+}
+
+func TestRetrying(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		attempts  int
+		wantErr   bool
+		wantCalls int
+	}{
+		{"no failures", 0, 3, false, 1},
+		{"recovers", 2, 3, false, 3},
+		{"gives up", 3, 3, true, 3},
+		{"at least one attempt", 0, 0, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm := &flakyMailer{failures: tt.failures, sent: Memory()}
+
+			err := Retrying(fm, tt.attempts, time.Millisecond).Send(context.Background(), testMail)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+
+			if fm.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", fm.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryingStopsWithContext(t *testing.T) {
+	fm := &flakyMailer{failures: 10, sent: Memory()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Retrying(fm, 5, time.Hour).Send(ctx, testMail)
+
+	if !errors.Is(err, context.Canceled) || fm.calls != 1 {
+		t.Errorf("err, calls = %v, %d, want canceled after one call", err, fm.calls)
+	}
+}
+
+func TestAsync(t *testing.T) {
+	mm := Memory()
+	am := Async(mm, 10, 2)
+
+	for range 5 {
+		err := am.Send(context.Background(), testMail)
+
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	err := am.Close(context.Background())
+
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if n := len(mm.Sent()); n != 5 {
+		t.Errorf("sent = %d, want 5", n)
+	}
+
+	err = am.Send(context.Background(), testMail)
+
+	if !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("send after close = %v, want ErrQueueClosed", err)
+	}
+}
+
+// blocks every send until it's released
+type blockingMailer struct {
+	release chan struct{}
+}
+
+func (bm blockingMailer) Send(ctx context.Context, m Mail) error {
+	select {
+	case <-bm.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestAsyncQueueFull(t *testing.T) {
+	bm := blockingMailer{make(chan struct{})}
+	am := Async(bm, 1, 1)
+
+	defer func() {
+		close(bm.release)
+		am.Close(context.Background())
+	}()
+
+	var err error
+
+	// one mail is taken by the worker and one waits in the queue
+	for range 3 {
+		err = am.Send(context.Background(), testMail)
+
+		if err != nil {
+			break
+		}
+	}
+
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("send to a full queue = %v, want ErrQueueFull", err)
+	}
+}
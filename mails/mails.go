@@ -0,0 +1,97 @@
+package mails
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"time"
+)
+
+// Mail is an email ready to be sent, the html body is the one shown by the
+// mail clients and the text body is the fallback
+type Mail struct {
+	To,
+	Subject,
+	HTML,
+	Text string
+}
+
+type Mailer interface {
+	// Sends the mail, it returns when the mail is accepted by the transport
+	Send(ctx context.Context, m Mail) error
+}
+
+type logMailer struct{}
+
+// Log returns a mailer that only logs the mails, it's meant for development
+func Log() Mailer {
+	return logMailer{}
+}
+
+func (logMailer) Send(ctx context.Context, m Mail) error {
+	log.Printf("mails: to %s: %s\n%s", m.To, m.Subject, m.Text)
+
+	return nil
+}
+
+// encodes the mail as a multipart/alternative message with the text and html
+// bodies, ready to be sent by smtp or stored as an .eml file
+func (m Mail) message(from string, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	}
+
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		qw := quotedprintable.NewWriter(pw)
+
+		if _, err = qw.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+
+		if err = qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+
+	headers := [][2]string{
+		{"From", from},
+		{"To", m.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	}
+
+	for _, h := range headers {
+		msg.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,153 @@
+package mails
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testMail = Mail{
+	To:      "ada@example.com",
+	Subject: "Verify your email ✓",
+	HTML:    "<p>Hi Ada</p>",
+	Text:    "Hi Ada",
+}
+
+// parses a message and returns its headers and its text and html bodies
+func parseMessage(t *testing.T, msg []byte) (mail.Header, map[string]string) {
+	t.Helper()
+
+	m, err := mail.ReadMessage(strings.NewReader(string(msg)))
+
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v", mediaType, err)
+	}
+
+	bodies := make(map[string]string)
+	mr := multipart.NewReader(m.Body, params["boundary"])
+
+	for {
+		p, err := mr.NextRawPart()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+
+		b, err := io.ReadAll(quotedprintable.NewReader(p))
+
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+
+		contentType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		bodies[contentType] = string(b)
+	}
+
+	return m.Header, bodies
+}
+
+func TestMessage(t *testing.T) {
+	date := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	msg, err := testMail.message("Books App <no-reply@example.com>", date)
+
+	if err != nil {
+		t.Fatalf("message: %v", err)
+	}
+
+	header, bodies := parseMessage(t, msg)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"from", header.Get("From"), "Books App <no-reply@example.com>"},
+		{"to", header.Get("To"), testMail.To},
+		{"subject", subject, testMail.Subject},
+		{"date", header.Get("Date"), date.Format(time.RFC1123Z)},
+		{"text", bodies["text/plain"], testMail.Text},
+		{"html", bodies["text/html"], testMail.HTML},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mails")
+
+	err := File(dir, "no-reply@example.com").Send(context.Background(), testMail)
+
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+
+	if err != nil || len(files) != 1 {
+		t.Fatalf("eml files = %v, %v, want one", files, err)
+	}
+
+	msg, err := os.ReadFile(files[0])
+
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	header, bodies := parseMessage(t, msg)
+
+	if header.Get("To") != testMail.To || bodies["text/plain"] != testMail.Text {
+		t.Errorf("stored mail = %v %q, want the sent mail", header, bodies["text/plain"])
+	}
+}
+
+func TestMemory(t *testing.T) {
+	mm := Memory()
+
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		err := mm.Send(context.Background(), Mail{To: to})
+
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	sent := mm.Sent()
+
+	if len(sent) != 2 || sent[0].To != "a@example.com" || sent[1].To != "b@example.com" {
+		t.Errorf("sent = %v, want both mails in order", sent)
+	}
+
+	// the returned mails are a copy
+	sent[0].To = "changed"
+
+	if mm.Sent()[0].To != "a@example.com" {
+		t.Error("Sent returned the internal slice")
+	}
+}
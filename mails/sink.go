@@ -0,0 +1,69 @@
+package mails
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// File returns a mailer that stores the mails as .eml files in the given
+// directory, so they can be opened with any mail client during development
+func File(dir, from string) Mailer {
+	return fileMailer{dir, from}
+}
+
+func (fm fileMailer) Send(ctx context.Context, m Mail) error {
+	now := time.Now()
+
+	msg, err := m.message(fm.from, now)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(fm.dir, 0o755)
+
+	if err != nil {
+		return err
+	}
+
+	name := now.UTC().Format("20060102T150405") + "-" + uuid.NewString() + ".eml"
+
+	return os.WriteFile(filepath.Join(fm.dir, name), msg, 0o644)
+}
+
+// MemoryMailer keeps the sent mails in memory, it's meant for tests
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Mail
+}
+
+func Memory() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (mm *MemoryMailer) Send(ctx context.Context, m Mail) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.sent = append(mm.sent, m)
+
+	return nil
+}
+
+// Sent returns a copy of the sent mails, the oldest first
+func (mm *MemoryMailer) Sent() []Mail {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	return append([]Mail(nil), mm.sent...)
+}
@@ -0,0 +1,75 @@
+package mails
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host string
+	Port int
+
+	// the auth is skipped when the username is empty, e.g. for a local sink
+	// like mailpit
+	Username,
+	Password string
+
+	// value of the From header, like "Books App <no-reply@example.com>", its
+	// address is the envelope sender
+	From string
+}
+
+type smtpMailer struct {
+	config SMTPConfig
+
+	// address of the From header, without the display name
+	sender string
+}
+
+// SMTP returns a mailer that sends the mails through a smtp server, if the
+// from is not a valid address returns an error
+func SMTP(config SMTPConfig) (Mailer, error) {
+	from, err := mail.ParseAddress(config.From)
+
+	if err != nil {
+		return nil, fmt.Errorf("smtp mailer: invalid from %q: %w", config.From, err)
+	}
+
+	return smtpMailer{config, from.Address}, nil
+}
+
+func (sm smtpMailer) Send(ctx context.Context, m Mail) error {
+	msg, err := m.message(sm.config.From, time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+
+	if sm.config.Username != "" {
+		auth = smtp.PlainAuth("", sm.config.Username, sm.config.Password, sm.config.Host)
+	}
+
+	addr := net.JoinHostPort(sm.config.Host, strconv.Itoa(sm.config.Port))
+
+	// smtp.SendMail does not accept a context, so the send is abandoned when
+	// the context is done
+	done := make(chan error, 1)
+
+	go func() {
+		done <- smtp.SendMail(addr, auth, sm.sender, []string{m.To}, msg)
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,137 @@
+package mails
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// a smtp server that accepts one mail and records its commands
+type fakeSMTPServer struct {
+	ln       net.Listener
+	commands chan string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeSMTPServer{ln, make(chan string, 100)}
+
+	go s.serve()
+
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.ln.Accept()
+
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+	defer close(s.commands)
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ready")
+
+	for {
+		line, err := r.ReadString('\n')
+
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		s.commands <- line
+
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "DATA":
+			reply("354 go ahead")
+
+			for {
+				data, err := r.ReadString('\n')
+
+				if err != nil || data == ".\r\n" {
+					break
+				}
+			}
+
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) port(t *testing.T) int {
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	n, err := strconv.Atoi(port)
+
+	if err != nil {
+		t.Fatalf("port: %v", err)
+	}
+
+	return n
+}
+
+func TestSMTPEnvelopeSender(t *testing.T) {
+	s := newFakeSMTPServer(t)
+
+	mailer, err := SMTP(SMTPConfig{Host: "127.0.0.1", Port: s.port(t), From: "Books App <no-reply@example.com>"})
+
+	if err != nil {
+		t.Fatalf("smtp: %v", err)
+	}
+
+	err = mailer.Send(context.Background(), testMail)
+
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	var mailFrom, rcptTo string
+
+	for cmd := range s.commands {
+		switch {
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			mailFrom = cmd
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcptTo = cmd
+		}
+	}
+
+	if !strings.HasPrefix(mailFrom, "MAIL FROM:<no-reply@example.com>") {
+		t.Errorf("mail from = %q, want the address without the display name", mailFrom)
+	}
+
+	if rcptTo != "RCPT TO:<"+testMail.To+">" {
+		t.Errorf("rcpt to = %q", rcptTo)
+	}
+}
+
+func TestSMTPInvalidFrom(t *testing.T) {
+	_, err := SMTP(SMTPConfig{Host: "127.0.0.1", Port: 25, From: "Books App"})
+
+	if err == nil {
+		t.Error("smtp accepted a from without address")
+	}
+}
@@ -0,0 +1,80 @@
+package mails
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+type Template string
+
+const (
+	VerificationTemplate  Template = "verification"
+	PasswordResetTemplate Template = "password_reset"
+	NewSessionTemplate    Template = "new_session"
+)
+
+var subjects = map[Template]string{
+	VerificationTemplate:  "Verify your email",
+	PasswordResetTemplate: "Reset your password",
+	NewSessionTemplate:    "New sign in to your account",
+}
+
+type VerificationData struct {
+	Username,
+	Link string
+}
+
+type PasswordResetData struct {
+	Username,
+	Link,
+	ExpiresIn string
+}
+
+type NewSessionData struct {
+	Username,
+	UserAgent,
+	IP string
+
+	Time time.Time
+}
+
+var (
+	htmlTemplates = make(map[Template]*htmltemplate.Template)
+	textTemplates = make(map[Template]*texttemplate.Template)
+)
+
+// the templates are parsed on start, so a broken template panics at once
+func init() {
+	for t := range subjects {
+		name := string(t)
+
+		htmlTemplates[t] = htmltemplate.Must(htmltemplate.ParseFS(templatesFS, "templates/layout.html", "templates/"+name+".html"))
+		textTemplates[t] = texttemplate.Must(texttemplate.ParseFS(templatesFS, "templates/"+name+".txt"))
+	}
+}
+
+// Render returns the mail of the template with the given data, the data must
+// be the struct of the template, e.g. [VerificationData]
+func Render(t Template, to string, data any) (Mail, error) {
+	var html, text bytes.Buffer
+
+	err := htmlTemplates[t].ExecuteTemplate(&html, "layout", data)
+
+	if err != nil {
+		return Mail{}, err
+	}
+
+	err = textTemplates[t].Execute(&text, data)
+
+	if err != nil {
+		return Mail{}, err
+	}
+
+	return Mail{To: to, Subject: subjects[t], HTML: html.String(), Text: text.String()}, nil
+}
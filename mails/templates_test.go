@@ -0,0 +1,67 @@
+package mails
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		template Template
+		data     any
+		want     []string
+	}{
+		{
+			VerificationTemplate,
+			VerificationData{Username: "ada", Link: "https://books.test/verify?token=abc"},
+			[]string{"ada", "https://books.test/verify?token=abc"},
+		},
+		{
+			PasswordResetTemplate,
+			PasswordResetData{Username: "ada", Link: "https://books.test/reset?token=abc", ExpiresIn: "1 hour"},
+			[]string{"ada", "https://books.test/reset?token=abc", "1 hour"},
+		},
+		{
+			NewSessionTemplate,
+			NewSessionData{Username: "ada", UserAgent: "curl/8.0", IP: "10.0.0.1", Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+			[]string{"ada", "curl/8.0", "10.0.0.1", "2024-05-01 10:00 UTC"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.template), func(t *testing.T) {
+			m, err := Render(tt.template, "ada@example.com", tt.data)
+
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+
+			if m.To != "ada@example.com" || m.Subject != subjects[tt.template] {
+				t.Errorf("to, subject = %q, %q", m.To, m.Subject)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(m.Text, want) {
+					t.Errorf("text does not contain %q:\n%s", want, m.Text)
+				}
+			}
+
+			if !strings.Contains(m.HTML, "ada") {
+				t.Errorf("html does not contain the username:\n%s", m.HTML)
+			}
+		})
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	m, err := Render(VerificationTemplate, "ada@example.com", VerificationData{Username: "<script>", Link: "https://books.test"})
+
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	if strings.Contains(m.HTML, "<script>") || !strings.Contains(m.HTML, "&lt;script&gt;") {
+		t.Errorf("the username is not escaped:\n%s", m.HTML)
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// scopes of the api tokens, every scope allows a group of actions
+const (
+	ScopeBooksRead    = "books:read"
+	ScopeBooksWrite   = "books:write"
+	ScopeProfileWrite = "profile:write"
+)
+
+var tokenScopes = map[string]bool{
+	ScopeBooksRead:    true,
+	ScopeBooksWrite:   true,
+	ScopeProfileWrite: true,
+}
+
+// IsTokenScope reports whether the scope is one of the known scopes
+func IsTokenScope(scope string) bool {
+	return tokenScopes[scope]
+}
+
+// APIToken is a named long lived token of an user for scripted access, it
+// only allows the actions of its scopes
+type APIToken struct {
+	ID uuid.UUID
+
+	UserID uuid.UUID
+
+	Name string
+
+	// sha256 of the token sent to the user, see [valobjs.Token]
+	TokenHash string
+
+	Scopes []string
+
+	// nil if the token never expires
+	ExpiresAt *time.Time
+
+	// nil if the token was never used
+	LastUsedAt *time.Time
+
+	CreatedAt time.Time
+}
+
+func NewAPIToken(userID uuid.UUID, name, tokenHash string, scopes []string, expiresAt *time.Time) APIToken {
+	return APIToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: tokenHash,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AttemptScope uint8
+
+const (
+	AttemptScopeUnknown AttemptScope = iota
+	AttemptScopeUser
+	AttemptScopeIP
+)
+
+func (as AttemptScope) String() string {
+	switch as {
+	case AttemptScopeUser:
+		return "User"
+	case AttemptScopeIP:
+		return "IP"
+	default:
+		return "Unknown"
+	}
+}
+
+// SignInAttempt counts the consecutive failed sign ins of an user or an ip,
+// the subject is the user id or the ip
+type SignInAttempt struct {
+	Scope   AttemptScope
+	Subject string
+
+	Failures int
+
+	LockedUntil,
+	UpdatedAt time.Time
+}
+
+func (sia SignInAttempt) IsLocked() bool {
+	return sia.LockedUntil.After(time.Now())
+}
+
+// LockoutEvent is a record of an user or an ip being locked
+type LockoutEvent struct {
+	ID uuid.UUID
+
+	Scope   AttemptScope
+	Subject string
+
+	Failures int
+
+	LockedUntil,
+	CreatedAt time.Time
+}
@@ -15,8 +15,51 @@ const (
 	BookStatusPublic
 	BookStatusPrivate
 	BookStatusDeleted
+
+	// the public books of a banned author, they are public again when the ban
+	// is lifted
+	BookStatusHidden
 )
 
+func (bs BookStatus) String() string {
+	switch bs {
+	case BookStatusDraft:
+		return "Draft"
+	case BookStatusPublic:
+		return "Public"
+	case BookStatusPrivate:
+		return "Private"
+	case BookStatusDeleted:
+		return "Deleted"
+	case BookStatusHidden:
+		return "Hidden"
+	default:
+		return "Unknown"
+	}
+}
+
+// allowed status changes, a book never goes back to draft and a deleted book
+// can only be restored during the grace period. Only the moderation hides and
+// shows again the books, so the hidden books can only be deleted
+var bookStatusTransitions = map[BookStatus][]BookStatus{
+	BookStatusDraft:   {BookStatusPublic, BookStatusPrivate, BookStatusDeleted},
+	BookStatusPublic:  {BookStatusPrivate, BookStatusDeleted},
+	BookStatusPrivate: {BookStatusPublic, BookStatusDeleted},
+	BookStatusHidden:  {BookStatusDeleted},
+}
+
+// CanTransitionTo reports whether a book with this status can change to the
+// next status
+func (bs BookStatus) CanTransitionTo(next BookStatus) bool {
+	for _, s := range bookStatusTransitions[bs] {
+		if s == next {
+			return true
+		}
+	}
+
+	return false
+}
+
 type Book struct {
 	ID uuid.UUID
 
@@ -36,6 +79,19 @@ type Book struct {
 
 	CreatedAt,
 	UpdatedAt time.Time
+
+	// time when the book was deleted, nil if it's not deleted
+	DeletedAt *time.Time
+
+	// matches of a search, nil if the book was not searched
+	Highlight *BookHighlight
+}
+
+// BookHighlight is the title and description of a searched book with the
+// matched terms between <mark> tags
+type BookHighlight struct {
+	Title,
+	Description string
 }
 
 func NewBook(title, description string, authorID uuid.UUID) Book {
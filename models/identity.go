@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalIdentity links an user to its account in an openid connect provider
+type ExternalIdentity struct {
+	ID uuid.UUID
+
+	UserID uuid.UUID
+
+	// name of the provider in the config
+	Provider string
+
+	// id of the user in the provider, it never changes
+	Subject string
+
+	// email given by the provider when the identity was linked
+	Email string
+
+	CreatedAt time.Time
+}
+
+func NewExternalIdentity(userID uuid.UUID, provider, subject, email string) ExternalIdentity {
+	return ExternalIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	}
+}
+
+// OIDCState is a pending sign in with an openid connect provider, it's used
+// once when the user comes back from the provider
+type OIDCState struct {
+	ID uuid.UUID
+
+	// sha256 of the state sent to the provider, see [valobjs.Token]
+	StateHash string
+
+	Provider string
+
+	Nonce,
+	CodeVerifier string
+
+	// the user that links the provider, nil for the sign ins
+	LinkUserID uuid.UUID
+
+	CreatedAt,
+	ExpiresAt time.Time
+}
+
+func NewOIDCState(stateHash, provider, nonce, verifier string, linkUserID uuid.UUID, ttl time.Duration) OIDCState {
+	return OIDCState{
+		StateHash:    stateHash,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(ttl),
+	}
+}
+
+// TwoFactorTicket is a sign in with an openid connect provider of an user with
+// two factor authentication, the provider already verified the user and the
+// ticket is exchanged for a session with the totp or recovery code
+type TwoFactorTicket struct {
+	ID uuid.UUID
+
+	// sha256 of the ticket given to the user, see [valobjs.Token]
+	TicketHash string
+
+	UserID uuid.UUID
+
+	CreatedAt,
+	ExpiresAt time.Time
+}
+
+func NewTwoFactorTicket(ticketHash string, userID uuid.UUID, ttl time.Duration) TwoFactorTicket {
+	return TwoFactorTicket{
+		TicketHash: ticketHash,
+		UserID:     userID,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ModerationAction is an action of a moderator on an user
+type ModerationAction uint8
+
+const (
+	ModerationActionUnknown ModerationAction = iota
+	ModerationActionBan
+	ModerationActionUnban
+	ModerationActionDelete
+	ModerationActionRestore
+)
+
+func (ma ModerationAction) String() string {
+	switch ma {
+	case ModerationActionBan:
+		return "ban"
+	case ModerationActionUnban:
+		return "unban"
+	case ModerationActionDelete:
+		return "delete"
+	case ModerationActionRestore:
+		return "restore"
+	default:
+		return "unknown"
+	}
+}
+
+func (ma ModerationAction) MarshalText() ([]byte, error) {
+	return []byte(ma.String()), nil
+}
+
+// ModerationEvent is a record of a moderator acting on an user, the events
+// without moderator were done by the app, like lifting an expired ban
+type ModerationEvent struct {
+	ID uuid.UUID
+
+	UserID,
+	ModeratorID uuid.UUID
+
+	Action ModerationAction
+	Reason string
+
+	// end of the ban, nil if the ban is permanent or the action is not a ban
+	ExpiresAt *time.Time
+
+	CreatedAt time.Time
+}
+
+func NewModerationEvent(userID, moderatorID uuid.UUID, action ModerationAction, reason string, expiresAt *time.Time) ModerationEvent {
+	return ModerationEvent{
+		UserID:      userID,
+		ModeratorID: moderatorID,
+		Action:      action,
+		Reason:      reason,
+		ExpiresAt:   expiresAt,
+	}
+}
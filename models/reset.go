@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordReset is a single use token that lets an user choose a new password
+type PasswordReset struct {
+	ID uuid.UUID
+
+	UserID uuid.UUID
+
+	// sha256 of the token sent to the user, see [valobjs.Token]
+	TokenHash string
+
+	CreatedAt,
+	ExpiresAt time.Time
+}
+
+func NewPasswordReset(userID uuid.UUID, tokenHash string, ttl time.Duration) PasswordReset {
+	return PasswordReset{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+}
@@ -0,0 +1,101 @@
+package models
+
+import "errors"
+
+var ErrUnknownRole = errors.New("invalid role: it must be reader, author, moderator or admin")
+
+// Role is the group of permissions of an user, every role has the
+// permissions of the previous ones
+type Role uint8
+
+const (
+	RoleUnknown Role = iota
+	RoleReader
+	RoleAuthor
+	RoleModerator
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleReader:
+		return "reader"
+	case RoleAuthor:
+		return "author"
+	case RoleModerator:
+		return "moderator"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
+
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Role) UnmarshalText(text []byte) error {
+	for role := RoleReader; role <= RoleAdmin; role++ {
+		if role.String() == string(text) {
+			*r = role
+			return nil
+		}
+	}
+
+	return ErrUnknownRole
+}
+
+// Permission is an action that an user can perform, see [Role.Can]
+type Permission string
+
+const (
+	// create books and manage the own ones
+	PermissionWriteOwnBooks Permission = "write_own_books"
+
+	// see the drafts and private books of other users
+	PermissionReadAnyBook Permission = "read_any_book"
+
+	// update and publish the books of other users
+	PermissionWriteAnyBook Permission = "write_any_book"
+
+	// delete the books of other users
+	PermissionDeleteAnyBook Permission = "delete_any_book"
+
+	PermissionBanUsers     Permission = "ban_users"
+	PermissionDeleteUsers  Permission = "delete_users"
+	PermissionManageRoles  Permission = "manage_roles"
+	PermissionViewLockouts Permission = "view_lockouts"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleReader: {},
+	RoleAuthor: {
+		PermissionWriteOwnBooks,
+	},
+	RoleModerator: {
+		PermissionReadAnyBook,
+		PermissionDeleteAnyBook,
+		PermissionBanUsers,
+	},
+	RoleAdmin: {
+		PermissionWriteAnyBook,
+		PermissionDeleteUsers,
+		PermissionManageRoles,
+		PermissionViewLockouts,
+	},
+}
+
+// Can reports whether the role or any of the previous roles has the
+// permission
+func (r Role) Can(p Permission) bool {
+	for role := RoleReader; role <= r && role <= RoleAdmin; role++ {
+		for _, rp := range rolePermissions[role] {
+			if rp == p {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	ID uuid.UUID
+
+	UserID uuid.UUID
+
+	// sha256 of the token sent to the client, see [valobjs.Token]
+	TokenHash string
+
+	UserAgent,
+	IP string
+
+	CreatedAt,
+	ExpiresAt time.Time
+}
+
+func NewSession(userID uuid.UUID, tokenHash, userAgent, ip string, ttl time.Duration) Session {
+	return Session{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		UserAgent: userAgent,
+		IP:        ip,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+import "github.com/marlonmp/books-app/valobjs"
+
+// number of recovery codes given when the two factor authentication is enabled
+const RecoveryCodesCount = 10
+
+// TwoFactor is the totp second factor of an user
+type TwoFactor struct {
+	// empty until the user starts the enrollment
+	Secret valobjs.TOTPSecret
+
+	// the secret is only used to sign in once the user verifies it
+	Enabled bool
+
+	// last used totp step, the codes of this step or older are rejected
+	LastStep int64
+
+	// hashes of the unused recovery codes
+	RecoveryCodes []string
+}
@@ -44,10 +44,30 @@ type User struct {
 
 	Password valobjs.Password
 
+	TwoFactor TwoFactor
+
+	Role Role
+
 	Status UserStatus
 
+	// end of the ban of a banned user, nil if the ban is permanent
+	BannedUntil *time.Time
+
 	CreatedAt,
 	UpdatedAt time.Time
+
+	// time when the user was deleted, nil if it's not deleted
+	DeletedAt *time.Time
+
+	// matches of a search, nil if the user was not searched
+	Highlight *UserHighlight
+}
+
+// UserHighlight is the username and nickname of a searched user with the
+// matched terms between <mark> tags
+type UserHighlight struct {
+	Username,
+	Nickname string
 }
 
 func NewUser(username, nickname, email, bio string, pwd valobjs.Password) User {
@@ -57,6 +77,8 @@ func NewUser(username, nickname, email, bio string, pwd valobjs.Password) User {
 		Email:    email,
 		Bio:      bio,
 		Password: pwd,
+		// the new users can write books, the admins can demote them to readers
+		Role: RoleAuthor,
 	}
 
 	return user
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserVerification is a pending email verification of an user
+type UserVerification struct {
+	ID uuid.UUID
+
+	UserID uuid.UUID
+
+	// sha256 of the token sent to the user, see [valobjs.Token]
+	TokenHash string
+
+	CreatedAt,
+	ExpiresAt time.Time
+}
+
+func NewUserVerification(userID uuid.UUID, tokenHash string, ttl time.Duration) UserVerification {
+	return UserVerification{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+}
@@ -0,0 +1,262 @@
+// Package oidctest runs a local openid connect provider for the tests
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	ClientID     = "books-app"
+	ClientSecret = "secret"
+	RedirectURL  = "http://localhost/callback"
+)
+
+// Server is a local provider with the authorization code flow with pkce. The
+// codes are issued by [Server.Authorize] and checked against their challenge
+// on the exchange, the id tokens are signed with RS256
+type Server struct {
+	// Subject of the id tokens
+	Subject string
+
+	t      *testing.T
+	server *httptest.Server
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	kid    string
+	claims map[string]any
+	forge  bool
+
+	// key id of the id tokens, if it's empty, the published one is used
+	tokenKid string
+
+	codes map[string]code
+}
+
+// a code issued to an authorization request
+type code struct {
+	challenge,
+	nonce string
+}
+
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	s := &Server{
+		Subject: "subject-1",
+		t:       t,
+		claims:  map[string]any{},
+		codes:   map[string]code{},
+	}
+
+	s.RotateKey()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("POST /token", s.token)
+
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// Issuer is the url of the provider
+func (s *Server) Issuer() string {
+	return s.server.URL
+}
+
+// SetClaim adds a claim to the next id tokens, it replaces the default one
+func (s *Server) SetClaim(name string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.claims[name] = value
+}
+
+// Forge signs the next id tokens with other key than the published one
+func (s *Server) Forge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.forge = true
+}
+
+// SetTokenKeyID sets the key id of the next id tokens, without publishing it
+func (s *Server) SetTokenKeyID(kid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokenKid = kid
+}
+
+// RotateKey replaces the signing key with a new one with other id
+func (s *Server) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		s.t.Fatalf("oidctest: generating key: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key = key
+	s.kid = "key-" + randomString(s.t)
+}
+
+// Authorize does what the provider does when the user signs in, it reads the
+// auth url and returns the code sent back to the app
+func (s *Server) Authorize(authURL string) string {
+	u, err := url.Parse(authURL)
+
+	if err != nil {
+		s.t.Fatalf("oidctest: parsing auth url: %v", err)
+	}
+
+	c := randomString(s.t)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.codes[c] = code{
+		challenge: u.Query().Get("code_challenge"),
+		nonce:     u.Query().Get("nonce"),
+	}
+
+	return c
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 s.server.URL,
+		"authorization_endpoint": s.server.URL + "/authorize",
+		"token_endpoint":         s.server.URL + "/token",
+		"jwks_uri":               s.server.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	pub, kid := s.key.PublicKey, s.kid
+	s.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+
+	if id != ClientID || secret != ClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	c, ok := s.codes[r.PostFormValue("code")]
+
+	// the codes can only be used once
+	delete(s.codes, r.PostFormValue("code"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+
+	if !ok || c.challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": s.idToken(c.nonce)})
+}
+
+func (s *Server) idToken(nonce string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	claims := map[string]any{
+		"iss":   s.server.URL,
+		"sub":   s.Subject,
+		"aud":   ClientID,
+		"exp":   now.Add(time.Minute).Unix(),
+		"iat":   now.Unix(),
+		"nonce": nonce,
+	}
+
+	for name, value := range s.claims {
+		claims[name] = value
+	}
+
+	key, kid := s.key, s.kid
+
+	if s.forge {
+		forged, err := rsa.GenerateKey(rand.Reader, 2048)
+
+		if err != nil {
+			s.t.Fatalf("oidctest: generating key: %v", err)
+		}
+
+		key = forged
+	}
+
+	if s.tokenKid != "" {
+		kid = s.tokenKid
+	}
+
+	signed := s.segment(map[string]string{"alg": "RS256", "kid": kid}) + "." + s.segment(claims)
+
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+
+	if err != nil {
+		s.t.Fatalf("oidctest: signing id token: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (s *Server) segment(v any) string {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		s.t.Fatalf("oidctest: encoding segment: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func randomString(t *testing.T) string {
+	b := make([]byte, 8)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		t.Fatalf("oidctest: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
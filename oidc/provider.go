@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidGrant = errors.New("oidc: the authorization code was rejected")
+	ErrInvalidToken = errors.New("oidc: invalid id token")
+)
+
+// max size of the responses of the providers
+const maxResponseSize = 1 << 20
+
+// Config is the registration of the app in an openid connect provider
+type Config struct {
+	// issuer url, the metadata is read from its well known path
+	Issuer string
+
+	ClientID,
+	ClientSecret string
+
+	// url where the provider sends the users back with the code
+	RedirectURL string
+
+	// the openid scope is always requested
+	Scopes []string
+}
+
+// Provider signs in the users with the authorization code flow with pkce of
+// an openid connect provider, its metadata and keys are fetched on first use
+type Provider struct {
+	name   string
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex
+	meta *metadata
+	keys keySet
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func New(name string, cfg Config) *Provider {
+	return &Provider{
+		name:   name,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// AuthURL returns the url of the provider where the users must be sent to
+// sign in, the state and the nonce must be checked when they come back
+func (p *Provider) AuthURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	scopes := append([]string{"openid"}, p.cfg.Scopes...)
+
+	query := url.Values{}
+
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", p.cfg.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	sep := "?"
+
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return meta.AuthorizationEndpoint + sep + query.Encode(), nil
+}
+
+// Exchange trades the authorization code for the id token and returns its
+// verified claims. If the provider rejects the code, returns [ErrInvalidGrant]
+// and if the id token is not valid, returns [ErrInvalidToken]
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	meta, err := p.metadata(ctx)
+
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return Claims{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+
+	status, err := p.do(req, &body)
+
+	if err != nil {
+		return Claims{}, err
+	}
+
+	// the rejected codes are reported with a 400 and an error
+	if status == http.StatusBadRequest || body.Error != "" {
+		return Claims{}, fmt.Errorf("%w: %s", ErrInvalidGrant, body.Error)
+	}
+
+	if status != http.StatusOK {
+		return Claims{}, fmt.Errorf("oidc: token endpoint: unexpected status %d", status)
+	}
+
+	claims, err := p.verify(ctx, body.IDToken)
+
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if claims.Nonce != nonce {
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// returns the metadata of the provider, it's only fetched once
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var meta metadata
+
+	status, err := p.do(req, &meta)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery: unexpected status %d", status)
+	}
+
+	// the issuer must match exactly, else the id tokens could come from other
+	// issuer
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q does not match %q", meta.Issuer, p.cfg.Issuer)
+	}
+
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery: missing endpoints")
+	}
+
+	p.meta = &meta
+
+	return p.meta, nil
+}
+
+// sends the request and decodes the json response, returns the status code
+func (p *Provider) do(req *http.Request, v any) (int, error) {
+	res, err := p.client.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close()
+
+	err = json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(v)
+
+	// the error responses could have no json body
+	if err != nil && res.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("oidc: decoding response: %w", err)
+	}
+
+	return res.StatusCode, nil
+}
+
+// the S256 pkce challenge of the verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
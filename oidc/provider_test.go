@@ -0,0 +1,272 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marlonmp/books-app/oidc/oidctest"
+)
+
+func newTestProvider(s *oidctest.Server) *Provider {
+	return New("mock", Config{
+		Issuer:       s.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  oidctest.RedirectURL,
+	})
+}
+
+// signs in with the provider, returns the code sent back by the provider
+func authorize(t *testing.T, s *oidctest.Server, p *Provider, nonce, verifier string) string {
+	t.Helper()
+
+	authURL, err := p.AuthURL(context.Background(), "state", nonce, verifier)
+
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+
+	return s.Authorize(authURL)
+}
+
+func TestAuthURL(t *testing.T) {
+	s := oidctest.NewServer(t)
+
+	authURL, err := newTestProvider(s).AuthURL(context.Background(), "state", "nonce", "verifier")
+
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+
+	if err != nil {
+		t.Fatalf("parsing auth url: %v", err)
+	}
+
+	if got, want := u.Path, "/authorize"; got != want {
+		t.Errorf("path = %q, want %q", got, want)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             oidctest.ClientID,
+		"redirect_uri":          oidctest.RedirectURL,
+		"scope":                 "openid",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        codeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+
+	for name, value := range want {
+		if got := u.Query().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	// the challenge must never reveal the verifier
+	if strings.Contains(authURL, "verifier") {
+		t.Errorf("auth url %q contains the verifier", authURL)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// changes the provider before the exchange
+		setup func(s *oidctest.Server)
+
+		// the verifier and nonce sent on the exchange, if they are empty, the
+		// ones of the auth url are sent
+		verifier,
+		nonce string
+
+		wantErr error
+		check   func(t *testing.T, claims Claims)
+	}{
+		{
+			name: "valid",
+			setup: func(s *oidctest.Server) {
+				s.SetClaim("email", "jane@example.com")
+				s.SetClaim("email_verified", true)
+			},
+			check: func(t *testing.T, claims Claims) {
+				if claims.Subject != "subject-1" || claims.Email != "jane@example.com" || !bool(claims.EmailVerified) {
+					t.Errorf("claims = %+v", claims)
+				}
+			},
+		},
+		{
+			name: "email verified as a string",
+			setup: func(s *oidctest.Server) {
+				s.SetClaim("email_verified", "true")
+			},
+			check: func(t *testing.T, claims Claims) {
+				if !bool(claims.EmailVerified) {
+					t.Error("email_verified = false, want true")
+				}
+			},
+		},
+		{
+			name: "email not verified",
+			setup: func(s *oidctest.Server) {
+				s.SetClaim("email_verified", "false")
+			},
+			check: func(t *testing.T, claims Claims) {
+				if bool(claims.EmailVerified) {
+					t.Error("email_verified = true, want false")
+				}
+			},
+		},
+		{
+			name: "audience list",
+			setup: func(s *oidctest.Server) {
+				s.SetClaim("aud", []string{"other", oidctest.ClientID})
+			},
+		},
+		{
+			name:     "wrong verifier",
+			verifier: "other verifier",
+			wantErr:  ErrInvalidGrant,
+		},
+		{
+			name:    "nonce mismatch",
+			nonce:   "other nonce",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "forged signature",
+			setup:   (*oidctest.Server).Forge,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "unknown key",
+			setup: func(s *oidctest.Server) {
+				s.SetTokenKeyID("unknown")
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "wrong audience",
+			setup: func(s *oidctest.Server) {
+				s.SetClaim("aud", "other")
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "wrong issuer",
+			setup: func(s *oidctest.Server) {
+				s.SetClaim("iss", "https://evil.example.com")
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expired",
+			setup: func(s *oidctest.Server) {
+				s.SetClaim("exp", time.Now().Add(-time.Hour).Unix())
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := oidctest.NewServer(t)
+			p := newTestProvider(s)
+
+			code := authorize(t, s, p, "nonce", "verifier")
+
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+
+			verifier, nonce := "verifier", "nonce"
+
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			claims, err := p.Exchange(context.Background(), code, verifier, nonce)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("exchange: %v", err)
+			}
+
+			if tt.check != nil {
+				tt.check(t, claims)
+			}
+		})
+	}
+}
+
+func TestExchangeRotatedKey(t *testing.T) {
+	s := oidctest.NewServer(t)
+	p := newTestProvider(s)
+
+	_, err := p.Exchange(context.Background(), authorize(t, s, p, "nonce", "verifier"), "verifier", "nonce")
+
+	if err != nil {
+		t.Fatalf("first exchange: %v", err)
+	}
+
+	// the new key id is unknown, so the keys are fetched again
+	s.RotateKey()
+
+	_, err = p.Exchange(context.Background(), authorize(t, s, p, "nonce", "verifier"), "verifier", "nonce")
+
+	if err != nil {
+		t.Fatalf("exchange after the rotation: %v", err)
+	}
+}
+
+func TestExchangeCodeUsedTwice(t *testing.T) {
+	s := oidctest.NewServer(t)
+	p := newTestProvider(s)
+
+	code := authorize(t, s, p, "nonce", "verifier")
+
+	_, err := p.Exchange(context.Background(), code, "verifier", "nonce")
+
+	if err != nil {
+		t.Fatalf("first exchange: %v", err)
+	}
+
+	_, err = p.Exchange(context.Background(), code, "verifier", "nonce")
+
+	if !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidGrant)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	s := oidctest.NewServer(t)
+
+	p := New("mock", Config{
+		Issuer:   s.Issuer() + "/",
+		ClientID: oidctest.ClientID,
+	})
+
+	_, err := p.AuthURL(context.Background(), "state", "nonce", "verifier")
+
+	if err == nil {
+		t.Fatal("auth url with a wrong issuer returned no error")
+	}
+}
@@ -0,0 +1,355 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// time accepted between the clocks of the provider and the app
+const clockSkew = time.Minute
+
+// Claims are the claims of an id token used to identify the users
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Nonce     string   `json:"nonce"`
+
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// the aud claim can be a string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+
+	if json.Unmarshal(data, &one) == nil {
+		*a = audience{one}
+		return nil
+	}
+
+	var many []string
+
+	err := json.Unmarshal(data, &many)
+
+	*a = many
+
+	return err
+}
+
+func (a audience) contains(v string) bool {
+	for _, aud := range a {
+		if aud == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// some providers send the booleans as strings
+type flexBool bool
+
+func (fb *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*fb = true
+	default:
+		*fb = false
+	}
+
+	return nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// checks the signature and the claims of the id token
+func (p *Provider) verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+
+	err := decodeSegment(parts[0], &header)
+
+	if err != nil {
+		return Claims{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+
+	key, err := p.key(ctx, header.Kid)
+
+	if err != nil {
+		return Claims{}, err
+	}
+
+	err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature)
+
+	if err != nil {
+		return Claims{}, err
+	}
+
+	var claims Claims
+
+	err = decodeSegment(parts[1], &claims)
+
+	if err != nil {
+		return Claims{}, err
+	}
+
+	now := time.Now()
+
+	switch {
+	case claims.Issuer != p.cfg.Issuer:
+		return Claims{}, fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	case !claims.Audience.contains(p.cfg.ClientID):
+		return Claims{}, fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return Claims{}, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return Claims{}, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+
+	err = json.Unmarshal(data, v)
+
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// only the asymmetric algorithms are accepted, so a client secret can't be
+// used to forge tokens
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	sum := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], signature) != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+
+		if !ok || len(signature) != 64 {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+
+	return nil
+}
+
+// keySet are the public keys of the provider by key id
+type keySet map[string]crypto.PublicKey
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// rsa keys
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// ec keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// returns the key with the given id, the keys are fetched again if the id is
+// unknown, so the rotated keys are found
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys.find(kid)
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	key, ok = keys.find(kid)
+
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+
+	return key, nil
+}
+
+// the tokens without key id are accepted if the provider has only one key
+func (ks keySet) find(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(ks) == 1 {
+		for _, key := range ks {
+			return key, true
+		}
+	}
+
+	key, ok := ks[kid]
+
+	return key, ok
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (keySet, error) {
+	meta, err := p.metadata(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	status, err := p.do(req, &body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: jwks: unexpected status %d", status)
+	}
+
+	keys := make(keySet, len(body.Keys))
+
+	for _, k := range body.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+
+		// the keys of unsupported types are ignored
+		if err != nil {
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("oidc: unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+		if !pub.Curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("oidc: invalid ec key")
+		}
+
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("oidc: unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("oidc: invalid key")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
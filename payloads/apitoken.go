@@ -0,0 +1,84 @@
+package payloads
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+const apiTokenNameMaxLen = 100
+
+type APITokenCreate struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+
+	// the token never expires if it's empty
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (atc APITokenCreate) Validate() error {
+	var v validator
+
+	if v.required("name", atc.Name) {
+		v.maxLen("name", atc.Name, apiTokenNameMaxLen)
+	}
+
+	if len(atc.Scopes) == 0 {
+		v.add("scopes", RequiredCode, "at least one scope is required")
+	}
+
+	for _, scope := range atc.Scopes {
+		if !models.IsTokenScope(scope) {
+			v.add("scopes", InvalidChoiceCode, "unknown scope "+scope)
+		}
+	}
+
+	if atc.ExpiresAt != nil && !atc.ExpiresAt.After(time.Now()) {
+		v.add("expires_at", PastDateCode, "must be a future date")
+	}
+
+	return v.err()
+}
+
+func (atc APITokenCreate) ToModel(userID uuid.UUID, tokenHash string) models.APIToken {
+	return models.NewAPIToken(userID, atc.Name, tokenHash, atc.Scopes, atc.ExpiresAt)
+}
+
+type APITokenList struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func APITokenListFromModel(t models.APIToken) APITokenList {
+	return APITokenList{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scopes:     t.Scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func APITokenListFromModels(tokens []models.APIToken) []APITokenList {
+	payloads := make([]APITokenList, len(tokens))
+
+	for i, t := range tokens {
+		payloads[i] = APITokenListFromModel(t)
+	}
+
+	return payloads
+}
+
+// APITokenCreated includes the token, it's only available when the token is
+// created because just its hash is stored
+type APITokenCreated struct {
+	APITokenList
+
+	Token string `json:"token"`
+}
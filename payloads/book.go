@@ -8,23 +8,58 @@ import (
 )
 
 type BookList struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	BookPath    string    `json:"book_path"`
-	CoverPath   string    `json:"cover_path"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uuid.UUID      `json:"id"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	BookPath    string         `json:"book_path"`
+	CoverPath   string         `json:"cover_path"`
+	CreatedAt   time.Time      `json:"created_at"`
+	DeletedAt   *time.Time     `json:"deleted_at,omitempty"`
+	Author      *BookAuthor    `json:"author,omitempty"`
+	Highlight   *BookHighlight `json:"highlight,omitempty"`
+}
+
+// BookAuthor is the author of a listed book, only if it was requested
+type BookAuthor struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Nickname string    `json:"nickname"`
+}
+
+// BookHighlight has the matched terms of a search between <mark> tags, the
+// rest of the text is html escaped
+type BookHighlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
 }
 
 func BookListFromModel(b models.Book) BookList {
-	return BookList{
+	book := BookList{
 		ID:          b.ID,
 		Title:       b.Title,
 		Description: b.Description,
 		BookPath:    b.BookPath,
 		CoverPath:   b.CoverPath,
 		CreatedAt:   b.CreatedAt,
+		DeletedAt:   b.DeletedAt,
+	}
+
+	if b.Author != nil {
+		book.Author = &BookAuthor{
+			ID:       b.Author.ID,
+			Username: b.Author.Username,
+			Nickname: b.Author.Nickname,
+		}
+	}
+
+	if b.Highlight != nil {
+		book.Highlight = &BookHighlight{
+			Title:       b.Highlight.Title,
+			Description: b.Highlight.Description,
+		}
 	}
+
+	return book
 }
 
 func BookListFromModels(books []models.Book) []BookList {
@@ -36,3 +71,74 @@ func BookListFromModels(books []models.Book) []BookList {
 
 	return payloads
 }
+
+type BookDetail struct {
+	ID          uuid.UUID         `json:"id"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	AuthorID    uuid.UUID         `json:"author_id"`
+	BookPath    string            `json:"book_path"`
+	CoverPath   string            `json:"cover_path"`
+	Status      models.BookStatus `json:"status"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	DeletedAt   *time.Time        `json:"deleted_at,omitempty"`
+}
+
+func BookDetailFromModel(b models.Book) BookDetail {
+	return BookDetail{
+		ID:          b.ID,
+		Title:       b.Title,
+		Description: b.Description,
+		AuthorID:    b.AuthorID,
+		BookPath:    b.BookPath,
+		CoverPath:   b.CoverPath,
+		Status:      b.Status,
+		CreatedAt:   b.CreatedAt,
+		UpdatedAt:   b.UpdatedAt,
+		DeletedAt:   b.DeletedAt,
+	}
+}
+
+type BookCreate struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func (bc BookCreate) Validate() error {
+	var v validator
+
+	if v.required("title", bc.Title) {
+		v.maxLen("title", bc.Title, bookTitleMaxLen)
+	}
+
+	v.maxLen("description", bc.Description, bookDescriptionMaxLen)
+
+	return v.err()
+}
+
+func (bc BookCreate) ToModel(authorID uuid.UUID) models.Book {
+	return models.NewBook(bc.Title, bc.Description, authorID)
+}
+
+type BookUpdate struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// the empty fields are not updated, so they are not required
+func (bu BookUpdate) Validate() error {
+	var v validator
+
+	v.maxLen("title", bu.Title, bookTitleMaxLen)
+	v.maxLen("description", bu.Description, bookDescriptionMaxLen)
+
+	return v.err()
+}
+
+func (bu BookUpdate) ToModel() models.Book {
+	return models.Book{
+		Title:       bu.Title,
+		Description: bu.Description,
+	}
+}
@@ -0,0 +1,21 @@
+package payloads
+
+import (
+	"io"
+	"time"
+)
+
+// FileUpload is an uploaded file, the filename is the one sent by the client
+// and it's never used to store the file
+type FileUpload struct {
+	Filename string
+	Content  io.Reader
+}
+
+// FileDownload is a stored file ready to be streamed, the content must be
+// closed by the caller
+type FileDownload struct {
+	Filename string
+	ModTime  time.Time
+	Content  io.ReadSeekCloser
+}
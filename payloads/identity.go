@@ -0,0 +1,70 @@
+package payloads
+
+import (
+	"time"
+
+	"github.com/marlonmp/books-app/models"
+)
+
+// OIDCRedirect is the url of the provider where the user must be sent
+type OIDCRedirect struct {
+	URL string `json:"url"`
+}
+
+// OIDCCallback has the query parameters given by the provider when the user
+// comes back
+type OIDCCallback struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+func (oc OIDCCallback) Validate() error {
+	var v validator
+
+	v.required("code", oc.Code)
+	v.required("state", oc.State)
+
+	return v.err()
+}
+
+// OIDCTwoFactor finishes a sign in with a provider of an user with two factor
+// authentication, the ticket is given when the provider sends the user back
+type OIDCTwoFactor struct {
+	Ticket string `json:"ticket"`
+
+	// totp or recovery code
+	Code string `json:"code"`
+}
+
+func (otf OIDCTwoFactor) Validate() error {
+	var v validator
+
+	v.required("ticket", otf.Ticket)
+	v.required("code", otf.Code)
+
+	return v.err()
+}
+
+type ExternalIdentityList struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ExternalIdentityListFromModel(ei models.ExternalIdentity) ExternalIdentityList {
+	return ExternalIdentityList{
+		Provider:  ei.Provider,
+		Email:     ei.Email,
+		CreatedAt: ei.CreatedAt,
+	}
+}
+
+func ExternalIdentityListFromModels(identities []models.ExternalIdentity) []ExternalIdentityList {
+	payloads := make([]ExternalIdentityList, len(identities))
+
+	for i, ei := range identities {
+		payloads[i] = ExternalIdentityListFromModel(ei)
+	}
+
+	return payloads
+}
@@ -0,0 +1,38 @@
+package payloads
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+type LockoutList struct {
+	ID          uuid.UUID           `json:"id"`
+	Scope       models.AttemptScope `json:"scope"`
+	Subject     string              `json:"subject"`
+	Failures    int                 `json:"failures"`
+	LockedUntil time.Time           `json:"locked_until"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+func LockoutListFromModel(e models.LockoutEvent) LockoutList {
+	return LockoutList{
+		ID:          e.ID,
+		Scope:       e.Scope,
+		Subject:     e.Subject,
+		Failures:    e.Failures,
+		LockedUntil: e.LockedUntil,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+func LockoutListFromModels(events []models.LockoutEvent) []LockoutList {
+	payloads := make([]LockoutList, len(events))
+
+	for i, e := range events {
+		payloads[i] = LockoutListFromModel(e)
+	}
+
+	return payloads
+}
@@ -0,0 +1,76 @@
+package payloads
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+const moderationReasonMaxLen = 1000
+
+type UserBan struct {
+	Reason string `json:"reason"`
+
+	// the ban is permanent if it's empty
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (ub UserBan) Validate() error {
+	var v validator
+
+	if v.required("reason", ub.Reason) {
+		v.maxLen("reason", ub.Reason, moderationReasonMaxLen)
+	}
+
+	if ub.ExpiresAt != nil && !ub.ExpiresAt.After(time.Now()) {
+		v.add("expires_at", PastDateCode, "must be a future date")
+	}
+
+	return v.err()
+}
+
+// ModerationReason is the reason of a moderator to unban or delete an user
+type ModerationReason struct {
+	Reason string `json:"reason"`
+}
+
+func (mr ModerationReason) Validate() error {
+	var v validator
+
+	if v.required("reason", mr.Reason) {
+		v.maxLen("reason", mr.Reason, moderationReasonMaxLen)
+	}
+
+	return v.err()
+}
+
+type ModerationEventList struct {
+	ID          uuid.UUID               `json:"id"`
+	ModeratorID uuid.UUID               `json:"moderator_id,omitempty"`
+	Action      models.ModerationAction `json:"action"`
+	Reason      string                  `json:"reason"`
+	ExpiresAt   *time.Time              `json:"expires_at,omitempty"`
+	CreatedAt   time.Time               `json:"created_at"`
+}
+
+func ModerationEventListFromModel(e models.ModerationEvent) ModerationEventList {
+	return ModerationEventList{
+		ID:          e.ID,
+		ModeratorID: e.ModeratorID,
+		Action:      e.Action,
+		Reason:      e.Reason,
+		ExpiresAt:   e.ExpiresAt,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+func ModerationEventListFromModels(events []models.ModerationEvent) []ModerationEventList {
+	payloads := make([]ModerationEventList, len(events))
+
+	for i, e := range events {
+		payloads[i] = ModerationEventListFromModel(e)
+	}
+
+	return payloads
+}
@@ -0,0 +1,24 @@
+package payloads
+
+import "github.com/marlonmp/books-app/repos"
+
+// Page is one page of a list, next and prev are the cursors to request the
+// pages around it
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+
+	// number of items of the whole list, only if it was requested
+	Total *int64 `json:"total,omitempty"`
+}
+
+// PageFromRepo converts the items of a repo page with the given function
+func PageFromRepo[M, P any](page repos.Page[M], fromModels func([]M) []P) Page[P] {
+	return Page[P]{
+		Items: fromModels(page.Items),
+		Next:  page.Next,
+		Prev:  page.Prev,
+		Total: page.Total,
+	}
+}
@@ -0,0 +1,83 @@
+package payloads
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+// ClientInfo describes the device that makes a request
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// Identity is the authenticated user of a request, authenticated with a
+// session or with an api token
+type Identity struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+
+	Role models.Role
+
+	TokenID uuid.UUID
+	Scopes  []string
+}
+
+// HasScope reports whether the identity allows the actions of the scope, the
+// sessions allow all of them
+func (i Identity) HasScope(scope string) bool {
+	if i.TokenID == uuid.Nil {
+		return true
+	}
+
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type SessionToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type UserSession struct {
+	SessionToken
+
+	User UserList `json:"user"`
+}
+
+type SessionList struct {
+	ID        uuid.UUID `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func SessionListFromModel(s models.Session, currentID uuid.UUID) SessionList {
+	return SessionList{
+		ID:        s.ID,
+		UserAgent: s.UserAgent,
+		IP:        s.IP,
+		Current:   s.ID == currentID,
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+	}
+}
+
+func SessionListFromModels(sessions []models.Session, currentID uuid.UUID) []SessionList {
+	payloads := make([]SessionList, len(sessions))
+
+	for i, s := range sessions {
+		payloads[i] = SessionListFromModel(s, currentID)
+	}
+
+	return payloads
+}
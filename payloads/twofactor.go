@@ -0,0 +1,43 @@
+package payloads
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+
+	// otpauth uri, the payload of the qr code scanned by the authenticator apps
+	URI string `json:"uri"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code"`
+}
+
+func (tfc TwoFactorCode) Validate() error {
+	var v validator
+
+	v.required("code", tfc.Code)
+
+	return v.err()
+}
+
+// TwoFactorReauth re-authenticates the user before a sensitive change of its
+// second factor
+type TwoFactorReauth struct {
+	Password string `json:"password"`
+
+	// totp or recovery code
+	Code string `json:"code"`
+}
+
+func (tfr TwoFactorReauth) Validate() error {
+	var v validator
+
+	v.required("password", tfr.Password)
+	v.required("code", tfr.Code)
+
+	return v.err()
+}
+
+// RecoveryCodes are only shown once, when they are created
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
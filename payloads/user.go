@@ -1,6 +1,7 @@
 package payloads
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,25 +10,48 @@ import (
 )
 
 type UserList struct {
-	ID        uuid.UUID         `json:"id"`
-	Username  string            `json:"username"`
-	Nickname  string            `json:"nickname"`
-	Bio       string            `json:"bio"`
-	Status    models.UserStatus `json:"status,omitempty"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at,omitempty"`
+	ID          uuid.UUID         `json:"id"`
+	Username    string            `json:"username"`
+	Nickname    string            `json:"nickname"`
+	Bio         string            `json:"bio"`
+	Status      models.UserStatus `json:"status,omitempty"`
+	Role        models.Role       `json:"role,omitempty"`
+	BannedUntil *time.Time        `json:"banned_until,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at,omitempty"`
+	DeletedAt   *time.Time        `json:"deleted_at,omitempty"`
+	Highlight   *UserHighlight    `json:"highlight,omitempty"`
+}
+
+// UserHighlight has the matched terms of a search between <mark> tags, the
+// rest of the text is html escaped
+type UserHighlight struct {
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
 }
 
 func UserListFromModel(u models.User) UserList {
-	return UserList{
-		ID:        u.ID,
-		Username:  u.Username,
-		Nickname:  u.Nickname,
-		Bio:       u.Bio,
-		Status:    u.Status,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+	user := UserList{
+		ID:          u.ID,
+		Username:    u.Username,
+		Nickname:    u.Nickname,
+		Bio:         u.Bio,
+		Status:      u.Status,
+		Role:        u.Role,
+		BannedUntil: u.BannedUntil,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		DeletedAt:   u.DeletedAt,
 	}
+
+	if u.Highlight != nil {
+		user.Highlight = &UserHighlight{
+			Username: u.Highlight.Username,
+			Nickname: u.Highlight.Nickname,
+		}
+	}
+
+	return user
 }
 
 func UserListFromModels(users []models.User) []UserList {
@@ -48,8 +72,22 @@ type UserCreate struct {
 	Password string `json:"password"`
 }
 
+func (uc UserCreate) Validate() error {
+	var v validator
+
+	v.username("username", uc.Username)
+	v.maxLen("nickname", uc.Nickname, nicknameMaxLen)
+	v.email("email", uc.Email)
+	v.maxLen("bio", uc.Bio, bioMaxLen)
+	v.password("password", uc.Password, uc.Username, uc.Email)
+
+	return v.err()
+}
+
+// ToModel hashes the password, the payload must be validated before because
+// the password policy is not checked again
 func (uc UserCreate) ToModel() (models.User, error) {
-	password, err := valobjs.NewPassword(uc.Password)
+	password, err := valobjs.HashPassword(uc.Password)
 
 	if err != nil {
 		return models.User{}, err
@@ -60,9 +98,130 @@ func (uc UserCreate) ToModel() (models.User, error) {
 	return user, nil
 }
 
+type UserUpdate struct {
+	Nickname string `json:"nickname"`
+	Bio      string `json:"bio"`
+}
+
+// the empty fields are not updated, so they are not required
+func (uu UserUpdate) Validate() error {
+	var v validator
+
+	v.maxLen("nickname", uu.Nickname, nicknameMaxLen)
+	v.maxLen("bio", uu.Bio, bioMaxLen)
+
+	return v.err()
+}
+
+func (uu UserUpdate) ToModel() models.User {
+	return models.User{
+		Nickname: uu.Nickname,
+		Bio:      uu.Bio,
+	}
+}
+
+type RoleAssignment struct {
+	Role models.Role `json:"role"`
+}
+
+func (ra RoleAssignment) Validate() error {
+	var v validator
+
+	if ra.Role == models.RoleUnknown {
+		v.add("role", RequiredCode, "this field is required")
+	}
+
+	return v.err()
+}
+
 type UserCredentials struct {
-	Username string `json:"username"`
+	// username or email of the user
+	Login    string `json:"login"`
 	Password string `json:"password"`
+
+	// totp or recovery code, only required if the user has two factor
+	// authentication
+	Code string `json:"code,omitempty"`
+}
+
+func (uc UserCredentials) Validate() error {
+	var v validator
+
+	// the password is not checked against the rules, the old passwords could
+	// not follow them
+	v.required("login", uc.Login)
+	v.required("password", uc.Password)
+
+	return v.err()
+}
+
+// Email returns the login normalized as an email and true if the login is an
+// email, the usernames can't contain an @
+func (uc UserCredentials) Email() (string, bool) {
+	login := strings.TrimSpace(uc.Login)
+
+	if !strings.Contains(login, "@") {
+		return "", false
+	}
+
+	return strings.ToLower(login), true
+}
+
+// Username returns the login normalized as an username
+func (uc UserCredentials) Username() string {
+	return strings.TrimSpace(uc.Login)
+}
+
+type EmailVerification struct {
+	Token string `json:"token"`
+}
+
+func (ev EmailVerification) Validate() error {
+	var v validator
+
+	v.required("token", ev.Token)
+
+	return v.err()
+}
+
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+func (er EmailRequest) Validate() error {
+	var v validator
+
+	v.email("email", er.Email)
+
+	return v.err()
+}
+
+type PasswordReset struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// the password is only required here, it's checked against the policy by
+// ValidateFor
+func (pr PasswordReset) Validate() error {
+	var v validator
+
+	v.required("token", pr.Token)
+	v.required("password", pr.Password)
+
+	return v.err()
+}
+
+// ValidateFor checks the password against the policy, including that it does
+// not contain the identities of the user, they are only known after the token
+// is checked
+func (pr PasswordReset) ValidateFor(username, email string) error {
+	var v validator
+
+	v.required("token", pr.Token)
+	v.password("password", pr.Password, username, email)
+
+	return v.err()
 }
 
 type UserProfile struct {
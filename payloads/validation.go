@@ -0,0 +1,159 @@
+package payloads
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+// codes of the field errors, they are sent to the clients
+const (
+	RequiredCode      = "required"
+	TooShortCode      = "too_short"
+	TooLongCode       = "too_long"
+	InvalidCharsCode  = "invalid_chars"
+	InvalidEmailCode  = "invalid_email"
+	WeakPasswordCode  = "weak_password"
+	InvalidFormatCode = "invalid_format"
+	InvalidChoiceCode = "invalid_choice"
+	PastDateCode      = "past_date"
+
+	ContainsIdentityCode = "contains_identity"
+	BreachedPasswordCode = "breached_password"
+)
+
+const (
+	usernameMinLen = 3
+	usernameMaxLen = 30
+
+	nicknameMaxLen = 50
+	emailMaxLen    = 254
+	bioMaxLen      = 500
+
+	bookTitleMaxLen       = 200
+	bookDescriptionMaxLen = 5000
+)
+
+var usernameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// validator collects the errors of every field, so the clients can show all
+// of them at once
+type validator struct {
+	fields []repos.FieldError
+
+	// an error that is not of a field, like a failed read of the breached
+	// passwords list
+	failure error
+}
+
+func (v *validator) add(field, code, message string) {
+	v.fields = append(v.fields, repos.FieldError{Field: field, Code: code, Message: message})
+}
+
+// returns a [repos.ValidationError] if any field failed, else nil. The errors
+// that are not of a field are returned first
+func (v *validator) err() error {
+	if v.failure != nil {
+		return v.failure
+	}
+
+	if len(v.fields) == 0 {
+		return nil
+	}
+
+	return repos.ValidationError{Fields: v.fields}
+}
+
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, RequiredCode, "this field is required")
+		return false
+	}
+
+	return true
+}
+
+func (v *validator) maxLen(field, value string, max int) bool {
+	if utf8.RuneCountInString(value) > max {
+		v.add(field, TooLongCode, "must have at most "+strconv.Itoa(max)+" characters")
+		return false
+	}
+
+	return true
+}
+
+func (v *validator) username(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+
+	if utf8.RuneCountInString(value) < usernameMinLen {
+		v.add(field, TooShortCode, "must have at least "+strconv.Itoa(usernameMinLen)+" characters")
+		return
+	}
+
+	if !v.maxLen(field, value, usernameMaxLen) {
+		return
+	}
+
+	if !usernameRegexp.MatchString(value) {
+		v.add(field, InvalidCharsCode, "must only contain letters, numbers, dots, hyphens and underscores")
+	}
+}
+
+func (v *validator) email(field, value string) {
+	if !v.required(field, value) || !v.maxLen(field, value, emailMaxLen) {
+		return
+	}
+
+	addr, err := mail.ParseAddress(value)
+
+	// the display names like "Name <name@mail.com>" are not accepted
+	if err != nil || addr.Address != value || !strings.Contains(value[strings.LastIndexByte(value, '@'):], ".") {
+		v.add(field, InvalidEmailCode, "must be a valid email address")
+	}
+}
+
+// checks the password against the current policy, the identities are the
+// username and email of the user
+func (v *validator) password(field, value string, identities ...string) {
+	if !v.required(field, value) {
+		return
+	}
+
+	policy := valobjs.CurrentPasswordPolicy()
+
+	err := policy.Check(value, identities...)
+
+	if err == nil {
+		return
+	}
+
+	var ppe valobjs.PasswordPolicyError
+
+	if !errors.As(err, &ppe) {
+		v.failure = err
+		return
+	}
+
+	switch ppe.Violation {
+	case valobjs.PasswordTooShort:
+		v.add(field, TooShortCode, "must have at least "+strconv.Itoa(policy.MinLength)+" characters")
+	case valobjs.PasswordTooLong:
+		v.add(field, TooLongCode, "must have at most "+strconv.Itoa(policy.MaxBytes)+" bytes")
+	case valobjs.PasswordNotMixed:
+		v.add(field, WeakPasswordCode, "must contain letters and numbers or symbols")
+	case valobjs.PasswordContainsIdentity:
+		v.add(field, ContainsIdentityCode, "must not contain the username or email")
+	case valobjs.PasswordBreached:
+		v.add(field, BreachedPasswordCode, "appears in a data breach, choose another one")
+	default:
+		v.add(field, WeakPasswordCode, ppe.Error())
+	}
+}
@@ -0,0 +1,161 @@
+package payloads
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/repos"
+)
+
+type validatable interface {
+	Validate() error
+}
+
+// returns the field errors of err as "field:code"
+func fieldCodes(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	var ve repos.ValidationError
+
+	if !errors.As(err, &ve) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+
+	codes := make([]string, len(ve.Fields))
+
+	for i, fe := range ve.Fields {
+		codes[i] = fe.Field + ":" + fe.Code
+	}
+
+	return codes
+}
+
+func TestValidate(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	validUser := UserCreate{
+		Username: "jane_doe",
+		Email:    "jane@example.com",
+		Password: "s3cret-pass",
+	}
+
+	userWith := func(change func(uc *UserCreate)) UserCreate {
+		uc := validUser
+		change(&uc)
+
+		return uc
+	}
+
+	tests := []struct {
+		name    string
+		payload validatable
+		want    []string
+	}{
+		// user create
+		{"user", validUser, nil},
+		{"user with every field", userWith(func(uc *UserCreate) {
+			uc.Nickname = "Jane"
+			uc.Bio = "reader"
+		}), nil},
+		{"empty user", UserCreate{}, []string{"username:required", "email:required", "password:required"}},
+		{"blank username", userWith(func(uc *UserCreate) { uc.Username = "   " }), []string{"username:required"}},
+		{"short username", userWith(func(uc *UserCreate) { uc.Username = "ja" }), []string{"username:too_short"}},
+		{"shortest username", userWith(func(uc *UserCreate) { uc.Username = "jan" }), nil},
+		{"longest username", userWith(func(uc *UserCreate) { uc.Username = strings.Repeat("j", 30) }), nil},
+		{"long username", userWith(func(uc *UserCreate) { uc.Username = strings.Repeat("j", 31) }), []string{"username:too_long"}},
+		{"username with dots and hyphens", userWith(func(uc *UserCreate) { uc.Username = "jane.doe-1" }), nil},
+		{"username with spaces", userWith(func(uc *UserCreate) { uc.Username = "jane doe" }), []string{"username:invalid_chars"}},
+		{"username with an at", userWith(func(uc *UserCreate) { uc.Username = "jane@doe" }), []string{"username:invalid_chars"}},
+		{"username with accents", userWith(func(uc *UserCreate) { uc.Username = "josé" }), []string{"username:invalid_chars"}},
+		{"long nickname", userWith(func(uc *UserCreate) { uc.Nickname = strings.Repeat("n", 51) }), []string{"nickname:too_long"}},
+		{"email without domain", userWith(func(uc *UserCreate) { uc.Email = "jane" }), []string{"email:invalid_email"}},
+		{"email without dot", userWith(func(uc *UserCreate) { uc.Email = "jane@localhost" }), []string{"email:invalid_email"}},
+		{"email with name", userWith(func(uc *UserCreate) { uc.Email = "Jane <jane@example.com>" }), []string{"email:invalid_email"}},
+		{"email with spaces", userWith(func(uc *UserCreate) { uc.Email = " jane@example.com" }), []string{"email:invalid_email"}},
+		{"long email", userWith(func(uc *UserCreate) { uc.Email = strings.Repeat("j", 243) + "@example.com" }), []string{"email:too_long"}},
+		{"longest bio", userWith(func(uc *UserCreate) { uc.Bio = strings.Repeat("é", 500) }), nil},
+		{"long bio", userWith(func(uc *UserCreate) { uc.Bio = strings.Repeat("b", 501) }), []string{"bio:too_long"}},
+		{"short password", userWith(func(uc *UserCreate) { uc.Password = "s3cret" }), []string{"password:too_short"}},
+		{"password of letters", userWith(func(uc *UserCreate) { uc.Password = "secretpass" }), []string{"password:weak_password"}},
+		{"password with the username", userWith(func(uc *UserCreate) { uc.Password = "jane_doe!1" }), []string{"password:contains_identity"}},
+		{"many errors", UserCreate{Username: "j", Email: "jane", Bio: strings.Repeat("b", 501), Password: "x"}, []string{
+			"username:too_short", "email:invalid_email", "bio:too_long", "password:too_short",
+		}},
+
+		// user update
+		{"empty user update", UserUpdate{}, nil},
+		{"user update", UserUpdate{Nickname: "Jane", Bio: "reader"}, nil},
+		{"long user update", UserUpdate{Nickname: strings.Repeat("n", 51), Bio: strings.Repeat("b", 501)}, []string{
+			"nickname:too_long", "bio:too_long",
+		}},
+
+		// sign in and accounts
+		{"role", RoleAssignment{Role: models.RoleAuthor}, nil},
+		{"no role", RoleAssignment{}, []string{"role:required"}},
+		{"credentials", UserCredentials{Login: "jane", Password: "x"}, nil},
+		{"empty credentials", UserCredentials{}, []string{"login:required", "password:required"}},
+		{"email verification", EmailVerification{}, []string{"token:required"}},
+		{"email request", EmailRequest{Email: "jane@example.com"}, nil},
+		{"invalid email request", EmailRequest{Email: "jane"}, []string{"email:invalid_email"}},
+		{"password reset", PasswordReset{Token: "t", Password: "x"}, nil},
+		{"empty password reset", PasswordReset{}, []string{"token:required", "password:required"}},
+		{"two factor code", TwoFactorCode{}, []string{"code:required"}},
+		{"two factor reauth", TwoFactorReauth{}, []string{"password:required", "code:required"}},
+		{"oidc callback", OIDCCallback{}, []string{"code:required", "state:required"}},
+		{"oidc two factor", OIDCTwoFactor{}, []string{"ticket:required", "code:required"}},
+
+		// books
+		{"book", BookCreate{Title: "Dune", Description: "spice"}, nil},
+		{"book without title", BookCreate{Title: " "}, []string{"title:required"}},
+		{"longest title", BookCreate{Title: strings.Repeat("t", 200)}, nil},
+		{"long title", BookCreate{Title: strings.Repeat("t", 201)}, []string{"title:too_long"}},
+		{"long description", BookCreate{Title: "Dune", Description: strings.Repeat("d", 5001)}, []string{"description:too_long"}},
+		{"empty book update", BookUpdate{}, nil},
+		{"long book update", BookUpdate{Title: strings.Repeat("t", 201), Description: strings.Repeat("d", 5001)}, []string{
+			"title:too_long", "description:too_long",
+		}},
+
+		// api tokens
+		{"api token", APITokenCreate{Name: "ci", Scopes: []string{models.ScopeBooksRead}, ExpiresAt: &future}, nil},
+		{"empty api token", APITokenCreate{}, []string{"name:required", "scopes:required"}},
+		{"unknown scope", APITokenCreate{Name: "ci", Scopes: []string{models.ScopeBooksRead, "admin"}}, []string{"scopes:invalid_choice"}},
+		{"expired api token", APITokenCreate{Name: "ci", Scopes: []string{models.ScopeBooksRead}, ExpiresAt: &past}, []string{"expires_at:past_date"}},
+
+		// moderation
+		{"ban", UserBan{Reason: "spam", ExpiresAt: &future}, nil},
+		{"ban without reason", UserBan{}, []string{"reason:required"}},
+		{"expired ban", UserBan{Reason: "spam", ExpiresAt: &past}, []string{"expires_at:past_date"}},
+		{"long reason", ModerationReason{Reason: strings.Repeat("r", 1001)}, []string{"reason:too_long"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fieldCodes(t, tt.payload.Validate())
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordResetValidateFor(t *testing.T) {
+	pr := PasswordReset{Token: "t", Password: "doe@example!1"}
+
+	if got := fieldCodes(t, pr.ValidateFor("jane", "doe@example.com")); !reflect.DeepEqual(got, []string{"password:contains_identity"}) {
+		t.Errorf("errors = %v, want the identity to be rejected", got)
+	}
+
+	if got := fieldCodes(t, pr.ValidateFor("jane", "jane@example.com")); got != nil {
+		t.Errorf("errors = %v", got)
+	}
+}
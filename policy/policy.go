@@ -0,0 +1,130 @@
+// Package policy decides what every user can do, the services call it before
+// acting so the authorization rules live in one place.
+package policy
+
+import (
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/repos"
+)
+
+// Actor is the user that performs an action, the zero actor is anonymous
+type Actor struct {
+	UserID uuid.UUID
+	Role   models.Role
+}
+
+func (a Actor) IsAnonymous() bool {
+	return a.UserID == uuid.Nil
+}
+
+func (a Actor) can(p models.Permission) bool {
+	return !a.IsAnonymous() && a.Role.Can(p)
+}
+
+func (a Actor) owns(book models.Book) bool {
+	return !a.IsAnonymous() && book.AuthorID == a.UserID
+}
+
+// CreateBook lets the authors and the higher roles create books
+func CreateBook(a Actor) error {
+	if !a.can(models.PermissionWriteOwnBooks) {
+		return repos.PermissionDeniedError{}
+	}
+
+	return nil
+}
+
+// ReadBook lets everyone read the public books, and the drafts, private and
+// hidden books only to their author and the moderators. The books that the
+// actor can't read return a [repos.NotFoundError], so their existence is not
+// revealed
+func ReadBook(a Actor, book models.Book) error {
+	switch book.Status {
+	case models.BookStatusPublic:
+		return nil
+	case models.BookStatusDraft, models.BookStatusPrivate, models.BookStatusHidden:
+		if a.owns(book) || a.can(models.PermissionReadAnyBook) {
+			return nil
+		}
+	}
+
+	return repos.NotFoundError{}
+}
+
+// UpdateBook lets the author update, publish and upload the files of its
+// books while it can write books, and the admins update any book
+func UpdateBook(a Actor, book models.Book) error {
+	if a.owns(book) && a.can(models.PermissionWriteOwnBooks) {
+		return nil
+	}
+
+	if a.can(models.PermissionWriteAnyBook) {
+		return nil
+	}
+
+	return repos.PermissionDeniedError{}
+}
+
+// DeleteBook lets the author delete its books, even if it can't write books
+// anymore, and the moderators delete any book
+func DeleteBook(a Actor, book models.Book) error {
+	if a.owns(book) || a.can(models.PermissionDeleteAnyBook) {
+		return nil
+	}
+
+	return repos.PermissionDeniedError{}
+}
+
+// BanUser lets the moderators ban and unban the users with a lower role,
+// nobody can ban itself
+func BanUser(a Actor, target models.User) error {
+	return actOnUser(a, target, models.PermissionBanUsers)
+}
+
+// DeleteUser lets the admins delete the users with a lower role, nobody can
+// delete itself here
+func DeleteUser(a Actor, target models.User) error {
+	return actOnUser(a, target, models.PermissionDeleteUsers)
+}
+
+// ListModerationEvents lets the moderators see the moderation history of the
+// users
+func ListModerationEvents(a Actor) error {
+	if !a.can(models.PermissionBanUsers) {
+		return repos.PermissionDeniedError{}
+	}
+
+	return nil
+}
+
+// AssignRole lets the admins change the role of the other users, the admins
+// can't change their own role so there is always an admin
+func AssignRole(a Actor, target models.User, role models.Role) error {
+	if role < models.RoleReader || role > models.RoleAdmin {
+		return repos.PermissionDeniedError{}
+	}
+
+	if !a.can(models.PermissionManageRoles) || target.ID == a.UserID {
+		return repos.PermissionDeniedError{}
+	}
+
+	return nil
+}
+
+// ListLockouts lets the admins see the locked users and ips
+func ListLockouts(a Actor) error {
+	if !a.can(models.PermissionViewLockouts) {
+		return repos.PermissionDeniedError{}
+	}
+
+	return nil
+}
+
+func actOnUser(a Actor, target models.User, p models.Permission) error {
+	if !a.can(p) || target.ID == a.UserID || target.Role >= a.Role {
+		return repos.PermissionDeniedError{}
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/repos"
+)
+
+var (
+	anonymous = Actor{}
+	reader    = Actor{UserID: uuid.New(), Role: models.RoleReader}
+	author    = Actor{UserID: uuid.New(), Role: models.RoleAuthor}
+	moderator = Actor{UserID: uuid.New(), Role: models.RoleModerator}
+	admin     = Actor{UserID: uuid.New(), Role: models.RoleAdmin}
+
+	// an author that became a reader, it keeps its books
+	demoted = Actor{UserID: uuid.New(), Role: models.RoleReader}
+)
+
+var (
+	errDenied   = repos.PermissionDeniedError{}
+	errNotFound = repos.NotFoundError{}
+)
+
+func checkErr(t *testing.T, got, want error) {
+	t.Helper()
+
+	if want == nil && got != nil || want != nil && !errors.Is(got, want) {
+		t.Errorf("err = %v, want %v", got, want)
+	}
+}
+
+func bookOf(a Actor, status models.BookStatus) models.Book {
+	return models.Book{ID: uuid.New(), AuthorID: a.UserID, Status: status}
+}
+
+func userOf(a Actor) models.User {
+	return models.User{ID: a.UserID, Role: a.Role}
+}
+
+func TestCreateBook(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		want  error
+	}{
+		{"anonymous", anonymous, errDenied},
+		{"reader", reader, errDenied},
+		{"author", author, nil},
+		{"moderator", moderator, nil},
+		{"admin", admin, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, CreateBook(tt.actor), tt.want)
+		})
+	}
+}
+
+func TestReadBook(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		book  models.Book
+		want  error
+	}{
+		{"anonymous public", anonymous, bookOf(author, models.BookStatusPublic), nil},
+		{"reader public", reader, bookOf(author, models.BookStatusPublic), nil},
+		{"anonymous draft", anonymous, bookOf(author, models.BookStatusDraft), errNotFound},
+		{"reader draft", reader, bookOf(author, models.BookStatusDraft), errNotFound},
+		{"other author draft", author, bookOf(admin, models.BookStatusDraft), errNotFound},
+		{"other author private", author, bookOf(admin, models.BookStatusPrivate), errNotFound},
+		{"other author hidden", author, bookOf(admin, models.BookStatusHidden), errNotFound},
+		{"owner draft", author, bookOf(author, models.BookStatusDraft), nil},
+		{"owner private", author, bookOf(author, models.BookStatusPrivate), nil},
+		{"owner hidden", author, bookOf(author, models.BookStatusHidden), nil},
+		{"demoted owner private", demoted, bookOf(demoted, models.BookStatusPrivate), nil},
+		{"moderator draft", moderator, bookOf(author, models.BookStatusDraft), nil},
+		{"moderator hidden", moderator, bookOf(author, models.BookStatusHidden), nil},
+		{"admin private", admin, bookOf(author, models.BookStatusPrivate), nil},
+		{"unknown status", admin, bookOf(author, models.BookStatusUnknown), errNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, ReadBook(tt.actor, tt.book), tt.want)
+		})
+	}
+}
+
+func TestUpdateBook(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		book  models.Book
+		want  error
+	}{
+		{"anonymous", anonymous, bookOf(author, models.BookStatusPublic), errDenied},
+		{"reader", reader, bookOf(author, models.BookStatusPublic), errDenied},
+		{"owner", author, bookOf(author, models.BookStatusDraft), nil},
+		{"other author", author, bookOf(moderator, models.BookStatusDraft), errDenied},
+		{"demoted owner", demoted, bookOf(demoted, models.BookStatusDraft), errDenied},
+		{"moderator owner", moderator, bookOf(moderator, models.BookStatusDraft), nil},
+		{"moderator", moderator, bookOf(author, models.BookStatusPublic), errDenied},
+		{"admin", admin, bookOf(author, models.BookStatusPublic), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, UpdateBook(tt.actor, tt.book), tt.want)
+		})
+	}
+}
+
+func TestDeleteBook(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		book  models.Book
+		want  error
+	}{
+		{"anonymous", anonymous, bookOf(author, models.BookStatusPublic), errDenied},
+		{"reader", reader, bookOf(author, models.BookStatusPublic), errDenied},
+		{"owner", author, bookOf(author, models.BookStatusPublic), nil},
+		{"other author", author, bookOf(admin, models.BookStatusPublic), errDenied},
+		{"demoted owner", demoted, bookOf(demoted, models.BookStatusPublic), nil},
+		{"moderator", moderator, bookOf(author, models.BookStatusPublic), nil},
+		{"admin", admin, bookOf(author, models.BookStatusPublic), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, DeleteBook(tt.actor, tt.book), tt.want)
+		})
+	}
+}
+
+func TestBanUser(t *testing.T) {
+	otherModerator := Actor{UserID: uuid.New(), Role: models.RoleModerator}
+	otherAdmin := Actor{UserID: uuid.New(), Role: models.RoleAdmin}
+
+	tests := []struct {
+		name   string
+		actor  Actor
+		target Actor
+		want   error
+	}{
+		{"anonymous", anonymous, reader, errDenied},
+		{"reader", reader, author, errDenied},
+		{"author", author, reader, errDenied},
+		{"moderator on reader", moderator, reader, nil},
+		{"moderator on author", moderator, author, nil},
+		{"moderator on moderator", moderator, otherModerator, errDenied},
+		{"moderator on admin", moderator, admin, errDenied},
+		{"moderator on itself", moderator, moderator, errDenied},
+		{"admin on moderator", admin, moderator, nil},
+		{"admin on admin", admin, otherAdmin, errDenied},
+		{"admin on itself", admin, admin, errDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, BanUser(tt.actor, userOf(tt.target)), tt.want)
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	otherAdmin := Actor{UserID: uuid.New(), Role: models.RoleAdmin}
+
+	tests := []struct {
+		name   string
+		actor  Actor
+		target Actor
+		want   error
+	}{
+		{"anonymous", anonymous, reader, errDenied},
+		{"reader", reader, author, errDenied},
+		{"author", author, reader, errDenied},
+		{"moderator", moderator, reader, errDenied},
+		{"admin on reader", admin, reader, nil},
+		{"admin on moderator", admin, moderator, nil},
+		{"admin on admin", admin, otherAdmin, errDenied},
+		{"admin on itself", admin, admin, errDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, DeleteUser(tt.actor, userOf(tt.target)), tt.want)
+		})
+	}
+}
+
+func TestAssignRole(t *testing.T) {
+	otherAdmin := Actor{UserID: uuid.New(), Role: models.RoleAdmin}
+
+	tests := []struct {
+		name   string
+		actor  Actor
+		target Actor
+		role   models.Role
+		want   error
+	}{
+		{"anonymous", anonymous, reader, models.RoleAuthor, errDenied},
+		{"author", author, reader, models.RoleAuthor, errDenied},
+		{"moderator", moderator, reader, models.RoleAuthor, errDenied},
+		{"admin promotes", admin, reader, models.RoleAuthor, nil},
+		{"admin demotes", admin, author, models.RoleReader, nil},
+		{"admin makes an admin", admin, moderator, models.RoleAdmin, nil},
+		{"admin demotes an admin", admin, otherAdmin, models.RoleModerator, nil},
+		{"admin on itself", admin, admin, models.RoleReader, errDenied},
+		{"unknown role", admin, reader, models.RoleUnknown, errDenied},
+		{"role out of range", admin, reader, models.RoleAdmin + 1, errDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, AssignRole(tt.actor, userOf(tt.target), tt.role), tt.want)
+		})
+	}
+}
+
+func TestListLockoutsAndModerationEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		actor      Actor
+		wantEvents error
+		wantLocks  error
+	}{
+		{"anonymous", anonymous, errDenied, errDenied},
+		{"reader", reader, errDenied, errDenied},
+		{"author", author, errDenied, errDenied},
+		{"moderator", moderator, nil, errDenied},
+		{"admin", admin, nil, nil},
+
+		// the role without the user is still anonymous
+		{"anonymous with a role", Actor{Role: models.RoleAdmin}, errDenied, errDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, ListModerationEvents(tt.actor), tt.wantEvents)
+			checkErr(t, ListLockouts(tt.actor), tt.wantLocks)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+type APITokenRepo interface {
+	// Creates one api token and returns the created token
+	CreateOne(ctx context.Context, t models.APIToken) (models.APIToken, error)
+
+	// Returns the not expired token with the given hash of an user with the
+	// given status and marks it as used now, if find nothing, returns a
+	// [NotFoundError]
+	UseByTokenHash(ctx context.Context, tokenHash string, userStatus models.UserStatus) (models.APIToken, error)
+
+	// Returns the tokens of the given user, including the expired ones, the
+	// newest first
+	FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.APIToken, error)
+
+	// Deletes and returns the token with the given id of the given user, if
+	// find nothing, returns a [NotFoundError]
+	DeleteByID(ctx context.Context, id, userID uuid.UUID) (models.APIToken, error)
+
+	// Deletes all the tokens of the given user, returns the number of deleted
+	// tokens
+	DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type psqlAPITokenRepo struct {
+	db DB
+}
+
+func PSQLAPITokenRepo(db DB) APITokenRepo {
+	return psqlAPITokenRepo{db}
+}
+
+func (patr psqlAPITokenRepo) CreateOne(ctx context.Context, t models.APIToken) (models.APIToken, error) {
+	err := patr.
+		db.
+		QueryRowEx(ctx, apiTokenCreateOne, nil, t.UserID, t.Name, t.TokenHash, t.Scopes, t.ExpiresAt).
+		Scan(&t.ID, &t.CreatedAt)
+
+	if err != nil {
+		return models.APIToken{}, err
+	}
+
+	return t, nil
+}
+
+func (patr psqlAPITokenRepo) UseByTokenHash(ctx context.Context, tokenHash string, userStatus models.UserStatus) (t models.APIToken, err error) {
+	err = patr.
+		db.
+		QueryRowEx(ctx, apiTokenUseByTokenHash, nil, tokenHash, userStatus).
+		Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (patr psqlAPITokenRepo) FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.APIToken, error) {
+	rows, err := patr.db.QueryEx(ctx, apiTokenFilterByUser, nil, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := make([]models.APIToken, 0)
+
+	for rows.Next() {
+		t := models.APIToken{}
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (patr psqlAPITokenRepo) DeleteByID(ctx context.Context, id, userID uuid.UUID) (t models.APIToken, err error) {
+	err = patr.
+		db.
+		QueryRowEx(ctx, apiTokenDeleteByID, nil, id, userID).
+		Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (patr psqlAPITokenRepo) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	tag, err := patr.db.ExecEx(ctx, apiTokenDeleteByUser, nil, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
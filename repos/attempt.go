@@ -0,0 +1,80 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/marlonmp/books-app/models"
+)
+
+type SignInAttemptRepo interface {
+	// Returns the attempts of the subject, if it has no failures, returns a
+	// [NotFoundError]
+	Get(ctx context.Context, scope models.AttemptScope, subject string) (models.SignInAttempt, error)
+
+	// Adds one failure to the subject and returns its attempts, the failures
+	// older than the window are forgotten
+	RegisterFailure(ctx context.Context, scope models.AttemptScope, subject string, window time.Duration) (models.SignInAttempt, error)
+
+	// Locks the subject until the given time
+	Lock(ctx context.Context, scope models.AttemptScope, subject string, until time.Time) error
+
+	// Forgets the failures and the lock of the subject
+	Reset(ctx context.Context, scope models.AttemptScope, subject string) error
+
+	// Deletes the attempts whose failures are older than the window and that
+	// are not locked, returns the number of deleted attempts
+	DeleteStale(ctx context.Context, window time.Duration) (int64, error)
+}
+
+type psqlSignInAttemptRepo struct {
+	db DB
+}
+
+func PSQLSignInAttemptRepo(db DB) SignInAttemptRepo {
+	return psqlSignInAttemptRepo{db}
+}
+
+func (par psqlSignInAttemptRepo) Get(ctx context.Context, scope models.AttemptScope, subject string) (a models.SignInAttempt, err error) {
+	err = par.
+		db.
+		QueryRowEx(ctx, attemptGet, nil, scope, subject).
+		Scan(&a.Scope, &a.Subject, &a.Failures, &a.LockedUntil, &a.UpdatedAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (par psqlSignInAttemptRepo) RegisterFailure(ctx context.Context, scope models.AttemptScope, subject string, window time.Duration) (a models.SignInAttempt, err error) {
+	err = par.
+		db.
+		QueryRowEx(ctx, attemptRegisterFailure, nil, scope, subject, window.String()).
+		Scan(&a.Scope, &a.Subject, &a.Failures, &a.LockedUntil, &a.UpdatedAt)
+
+	return
+}
+
+func (par psqlSignInAttemptRepo) Lock(ctx context.Context, scope models.AttemptScope, subject string, until time.Time) error {
+	_, err := par.db.ExecEx(ctx, attemptLock, nil, scope, subject, until)
+
+	return err
+}
+
+func (par psqlSignInAttemptRepo) Reset(ctx context.Context, scope models.AttemptScope, subject string) error {
+	_, err := par.db.ExecEx(ctx, attemptReset, nil, scope, subject)
+
+	return err
+}
+
+func (par psqlSignInAttemptRepo) DeleteStale(ctx context.Context, window time.Duration) (int64, error) {
+	tag, err := par.db.ExecEx(ctx, attemptDeleteStale, nil, window.String())
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
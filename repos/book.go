@@ -2,11 +2,10 @@ package repos
 
 import (
 	"context"
-	"strconv"
-	"strings"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx"
 	"github.com/marlonmp/books-app/models"
 )
 
@@ -15,16 +14,57 @@ type BookFilters struct {
 	AuthorID uuid.UUID
 	Status   models.BookStatus
 
+	// the books with any of the ids or statuses, the empty ones are ignored
+	BookIDs  []uuid.UUID
+	Statuses []models.BookStatus
+
+	// the books of the author with the given username
+	AuthorUsername string
+
+	// the books created or updated in the range, the zero times are ignored.
+	// The after times are included and the before times are not
+	CreatedAfter,
+	CreatedBefore,
+	UpdatedAfter,
+	UpdatedBefore time.Time
+
+	// the books deleted before the time are left out, the zero time is
+	// ignored
+	DeletedAfter time.Time
+
+	// the books with or without a file or a cover, nil are ignored
+	HasFile,
+	HasCover *bool
+
+	// fills the author of every book with its id, username and nickname
+	WithAuthor bool
+
 	Search        string
 	OrderBy       string
 	Limit, Offset int
 }
 
+// the fields that BookFilters.OrderBy accepts and their columns
+var bookSortColumns = map[string]string{
+	"title":      `"books"."title"`,
+	"status":     `"books"."status"`,
+	"created_at": `"books"."created_at"`,
+	"updated_at": `"books"."updated_at"`,
+}
+
+// the id of the books breaks the ties, see idSortKey
+var bookIDSortKey = sortKey{column: `"books"."id"`}
+
 type BookRepo interface {
 	// Return a list of books with the given filters, if find nothing, returns
 	// an empty array
 	FilterMany(ctx context.Context, bf *BookFilters) ([]models.Book, error)
 
+	// Returns one page of the books with the given filters, the limit and
+	// offset of the filters are ignored. If the cursor is not valid, returns
+	// an [InvalidCursorError]
+	FilterPage(ctx context.Context, bf *BookFilters, pf PageFilters) (Page[models.Book], error)
+
 	// Creates one book in the repo and return the created book
 	CreateOne(ctx context.Context, b models.Book) (models.Book, error)
 
@@ -36,125 +76,262 @@ type BookRepo interface {
 	// nothing, returns a [NotFoundError]
 	UpdateByID(ctx context.Context, id uuid.UUID, b models.Book) (models.Book, error)
 
-	// Delete and returns one book with the given id, if find nothing, returns
-	// a [NotFoundError]
+	// Replaces the status of the books of the given author with the given
+	// status, returns the number of updated books
+	UpdateStatusByAuthor(ctx context.Context, authorID uuid.UUID, status, next models.BookStatus) (int64, error)
+
+	// Marks as deleted and returns one not deleted book with the given id, if
+	// find nothing, returns a [NotFoundError]. The book is kept until it's
+	// purged
 	DeleteByID(ctx context.Context, id uuid.UUID) (models.Book, error)
+
+	// Gives the given status back to the book with the given id that was
+	// deleted after the given time, returns the restored book, if find
+	// nothing, returns a [NotFoundError]
+	RestoreByID(ctx context.Context, id uuid.UUID, status models.BookStatus, deletedAfter time.Time) (models.Book, error)
+
+	// Deletes forever the books deleted before the given time, returns the
+	// purged books
+	PurgeDeletedBefore(ctx context.Context, before time.Time) ([]models.Book, error)
 }
 
 type psqlBookRepo struct {
-	conn *pgx.Conn
+	db DB
 }
 
-func PSQLBookRepo(conn *pgx.Conn) BookRepo {
-	return psqlBookRepo{conn}
+func PSQLBookRepo(db DB) BookRepo {
+	return psqlBookRepo{db}
 }
 
-func (pbr psqlBookRepo) buildFilters(bf *BookFilters) (string, []any) {
-	var filters strings.Builder
-
-	values := make([]any, 0)
-	counter := 1
-
+// adds the conditions of the filters to the query
+func (pbr psqlBookRepo) writeFilters(qb *queryBuilder, bf *BookFilters) {
 	if bf == nil {
-		bf = new(BookFilters)
+		return
+	}
+
+	// the search param is added by listSource
+	if bf.Search != "" {
+		qb.where(bookSearchDocument + ` @@ "search"`)
 	}
 
 	if bf.BookID != uuid.Nil {
-		filters.WriteString(` and "id" = $`)
-		filters.WriteString(strconv.Itoa(counter))
+		qb.whereOp(`"books"."id"`, `=`, bf.BookID)
+	}
+
+	if len(bf.BookIDs) > 0 {
+		ids := make([]string, len(bf.BookIDs))
+
+		for i, id := range bf.BookIDs {
+			ids[i] = id.String()
+		}
 
-		counter++
-		values = append(values, bf.BookID)
+		qb.whereAny(`"books"."id"`, ids, `uuid`)
 	}
 
 	if bf.AuthorID != uuid.Nil {
-		filters.WriteString(` and "author_id" = $`)
-		filters.WriteString(strconv.Itoa(counter))
+		qb.whereOp(`"books"."author_id"`, `=`, bf.AuthorID)
+	}
 
-		counter++
-		values = append(values, bf.AuthorID)
+	if bf.AuthorUsername != "" {
+		qb.whereOp(`"authors"."username"`, `=`, bf.AuthorUsername)
 	}
 
 	if bf.Status != models.BookStatusUnknown {
-		filters.WriteString(` and "status" = $`)
-		filters.WriteString(strconv.Itoa(counter))
+		qb.whereOp(`"books"."status"`, `=`, bf.Status)
+	}
+
+	if len(bf.Statuses) > 0 {
+		statuses := make([]int32, len(bf.Statuses))
+
+		for i, status := range bf.Statuses {
+			statuses[i] = int32(status)
+		}
 
-		counter++
-		values = append(values, bf.Status)
+		qb.whereAny(`"books"."status"`, statuses, `int`)
 	}
 
-	if counter > 1 {
-		// remove the first ` and`
-		q := filters.String()[4:]
-		filters.Reset()
+	if !bf.CreatedAfter.IsZero() {
+		qb.whereOp(`"books"."created_at"`, `>=`, bf.CreatedAfter)
+	}
 
-		filters.WriteString(q)
+	if !bf.CreatedBefore.IsZero() {
+		qb.whereOp(`"books"."created_at"`, `<`, bf.CreatedBefore)
 	}
 
-	if bf.OrderBy != "" {
-		order := "asc"
-		orderField := bf.OrderBy
+	if !bf.UpdatedAfter.IsZero() {
+		qb.whereOp(`"books"."updated_at"`, `>=`, bf.UpdatedAfter)
+	}
 
-		if bf.OrderBy[0] == '+' {
-			orderField = orderField[1:]
-		}
+	if !bf.UpdatedBefore.IsZero() {
+		qb.whereOp(`"books"."updated_at"`, `<`, bf.UpdatedBefore)
+	}
 
-		if bf.OrderBy[0] == '-' {
-			order = "desc"
-			orderField = orderField[1:]
-		}
-		filters.WriteString(` order by "$`)
-		filters.WriteString(strconv.Itoa(counter))
-		filters.WriteString(`" `)
-		filters.WriteString(order)
+	if !bf.DeletedAfter.IsZero() {
+		qb.where(`("books"."deleted_at" is null or "books"."deleted_at" > ` + qb.param(bf.DeletedAfter) + `)`)
+	}
 
-		values = append(values, orderField)
+	// the books without file or cover have an empty path
+	if bf.HasFile != nil {
+		qb.where(`("books"."book_path" <> '') = ` + qb.param(*bf.HasFile))
 	}
 
-	if bf.Limit > 0 {
-		filters.WriteString(` limit $`)
-		filters.WriteString(strconv.Itoa(counter))
+	if bf.HasCover != nil {
+		qb.where(`("books"."cover_path" <> '') = ` + qb.param(*bf.HasCover))
+	}
+}
 
-		counter++
-		values = append(values, bf.Limit)
+// returns the sort keys of the list, they always end with the id
+func (pbr psqlBookRepo) sortKeys(bf *BookFilters) ([]sortKey, error) {
+	if bf == nil {
+		bf = new(BookFilters)
 	}
 
-	if bf.Offset > 0 {
-		filters.WriteString(` offset $`)
-		filters.WriteString(strconv.Itoa(counter))
+	keys, err := parseOrderBy(bf.OrderBy, bookSortColumns)
 
-		counter++
-		values = append(values, bf.Limit)
+	if err != nil {
+		return nil, err
 	}
 
-	return filters.String(), values
+	// the most relevant books first
+	if len(keys) == 0 && bf.Search != "" {
+		keys = []sortKey{{column: bookSearchRank, desc: true}}
+	}
+
+	return append(keys, bookIDSortKey), nil
 }
 
-func (pbr psqlBookRepo) FilterMany(ctx context.Context, bf *BookFilters) ([]models.Book, error) {
-	var query strings.Builder
+// returns the tables and the columns of a book list, the search adds the
+// highlights after the book columns and the author join adds the author
+// columns after them. The search is added to the params of the builder, so
+// every builder needs its own source
+func (pbr psqlBookRepo) listSource(qb *queryBuilder, bf *BookFilters) (string, []string) {
+	from := bookListFrom
+	columns := []string{bookListColumns}
 
-	query.WriteString(bookFilterMany)
+	if bf == nil {
+		return from, columns
+	}
 
-	filters, values := pbr.buildFilters(bf)
+	if bf.WithAuthor || bf.AuthorUsername != "" {
+		from += bookAuthorJoin
+	}
+
+	if bf.Search != "" {
+		from += fmt.Sprintf(bookSearchFrom, qb.param(bf.Search))
+		columns = append(columns, bookSearchColumns)
+	}
 
-	if len(filters) > 0 {
-		query.WriteString(filters)
+	if bf.WithAuthor {
+		columns = append(columns, bookAuthorColumns)
 	}
 
-	query.WriteRune(';')
+	return from, columns
+}
 
-	rows, err := pbr.conn.QueryEx(ctx, query.String(), nil, values...)
+func (pbr psqlBookRepo) FilterMany(ctx context.Context, bf *BookFilters) ([]models.Book, error) {
+	keys, err := pbr.sortKeys(bf)
 
 	if err != nil {
 		return nil, err
 	}
 
+	var qb queryBuilder
+
+	from, columns := pbr.listSource(&qb, bf)
+
+	qb.selectFrom(from, columns...)
+
+	pbr.writeFilters(&qb, bf)
+
+	qb.orderBy(keys)
+
+	if bf != nil {
+		qb.limit(bf.Limit, bf.Offset)
+	}
+
+	query, values := qb.build()
+
+	books, _, err := pbr.queryList(ctx, query, values, bf, 0)
+
+	return books, err
+}
+
+func (pbr psqlBookRepo) FilterPage(ctx context.Context, bf *BookFilters, pf PageFilters) (Page[models.Book], error) {
+	keys, err := pbr.sortKeys(bf)
+
+	if err != nil {
+		return Page[models.Book]{}, err
+	}
+
+	c, err := decodeCursor(pf.Cursor, keys)
+
+	if err != nil {
+		return Page[models.Book]{}, err
+	}
+
+	limit := pageLimit(pf.Limit)
+
+	var qb queryBuilder
+
+	from, columns := pbr.listSource(&qb, bf)
+
+	qb.selectFrom(from, append(columns, sortColumns(keys)...)...)
+
+	pbr.writeFilters(&qb, bf)
+
+	writePage(&qb, keys, c, limit)
+
+	query, values := qb.build()
+
+	books, sortValues, err := pbr.queryList(ctx, query, values, bf, len(keys))
+
+	if err != nil {
+		return Page[models.Book]{}, err
+	}
+
+	page := newPage(books, sortValues, keys, c, limit)
+
+	if pf.WithTotal {
+		var count queryBuilder
+
+		from, _ := pbr.listSource(&count, bf)
+
+		count.selectFrom(from, `count(*)`)
+
+		pbr.writeFilters(&count, bf)
+
+		page.Total, err = countList(ctx, pbr.db, &count)
+
+		if err != nil {
+			return Page[models.Book]{}, err
+		}
+	}
+
+	return page, nil
+}
+
+// returns the books of a list query with the columns of the filters, see
+// listSource, and the given number of sort values of every book
+func (pbr psqlBookRepo) queryList(ctx context.Context, query string, values []any, bf *BookFilters, sortKeys int) ([]models.Book, [][]string, error) {
+	if bf == nil {
+		bf = new(BookFilters)
+	}
+
+	rows, err := pbr.db.QueryEx(ctx, query, nil, values...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
 	books := make([]models.Book, 0)
+	sortValues := make([][]string, 0)
 
 	for rows.Next() {
 		book := models.Book{}
 
-		err = rows.Scan(
+		dest := []any{
 			&book.ID,
 			&book.Title,
 			&book.Description,
@@ -164,32 +341,56 @@ func (pbr psqlBookRepo) FilterMany(ctx context.Context, bf *BookFilters) ([]mode
 			&book.Status,
 			&book.CreatedAt,
 			&book.UpdatedAt,
-		)
+			&book.DeletedAt,
+		}
+
+		if bf.Search != "" {
+			book.Highlight = new(models.BookHighlight)
+			dest = append(dest, &book.Highlight.Title, &book.Highlight.Description)
+		}
+
+		if bf.WithAuthor {
+			book.Author = new(models.User)
+			dest = append(dest, &book.Author.ID, &book.Author.Username, &book.Author.Nickname)
+		}
+
+		bookSortValues := make([]string, sortKeys)
+
+		for i := range bookSortValues {
+			dest = append(dest, &bookSortValues[i])
+		}
+
+		err = rows.Scan(dest...)
 
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		books = append(books, book)
+		sortValues = append(sortValues, bookSortValues)
 	}
 
-	return books, nil
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return books, sortValues, nil
 }
 
 func (pbr psqlBookRepo) CreateOne(ctx context.Context, b models.Book) (models.Book, error) {
-	row := pbr.conn.QueryRowEx(ctx, bookCreateOne, nil, b.Title, b.Description, b.AuthorID, b.BookPath, b.CoverPath, b.Status)
+	row := pbr.db.QueryRowEx(ctx, bookCreateOne, nil, b.Title, b.Description, b.AuthorID, b.BookPath, b.CoverPath, b.Status)
 
 	err := row.Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
 
 	if err != nil {
-		return models.Book{}, nil
+		return models.Book{}, err
 	}
 
 	return b, nil
 }
 
 func (pbr psqlBookRepo) GetByID(ctx context.Context, id uuid.UUID) (b models.Book, err error) {
-	row := pbr.conn.QueryRowEx(ctx, bookGetByID, nil, id)
+	row := pbr.db.QueryRowEx(ctx, bookGetByID, nil, id)
 
 	err = row.Scan(
 		&b.ID,
@@ -201,6 +402,7 @@ func (pbr psqlBookRepo) GetByID(ctx context.Context, id uuid.UUID) (b models.Boo
 		&b.Status,
 		&b.CreatedAt,
 		&b.UpdatedAt,
+		&b.DeletedAt,
 	)
 
 	if AsNotFoundError(&err) {
@@ -211,7 +413,7 @@ func (pbr psqlBookRepo) GetByID(ctx context.Context, id uuid.UUID) (b models.Boo
 }
 
 func (pbr psqlBookRepo) UpdateByID(ctx context.Context, id uuid.UUID, b models.Book) (models.Book, error) {
-	row := pbr.conn.QueryRowEx(ctx, bookUpdateByID, nil, b.Title, b.Description, b.AuthorID, b.BookPath, b.CoverPath, b.Status, id)
+	row := pbr.db.QueryRowEx(ctx, bookUpdateByID, nil, b.Title, b.Description, b.AuthorID, b.BookPath, b.CoverPath, b.Status, id)
 
 	err := row.Scan(
 		&b.ID,
@@ -229,11 +431,25 @@ func (pbr psqlBookRepo) UpdateByID(ctx context.Context, id uuid.UUID, b models.B
 		return b, err
 	}
 
+	if err != nil {
+		return models.Book{}, err
+	}
+
 	return b, nil
 }
 
+func (pbr psqlBookRepo) UpdateStatusByAuthor(ctx context.Context, authorID uuid.UUID, status, next models.BookStatus) (int64, error) {
+	tag, err := pbr.db.ExecEx(ctx, bookUpdateStatusByAuthor, nil, next, authorID, status)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
 func (pbr psqlBookRepo) DeleteByID(ctx context.Context, id uuid.UUID) (b models.Book, err error) {
-	row := pbr.conn.QueryRowEx(ctx, bookDeleteByID, nil, id)
+	row := pbr.db.QueryRowEx(ctx, bookDeleteByID, nil, models.BookStatusDeleted, id)
 
 	err = row.Scan(
 		&b.ID,
@@ -245,6 +461,7 @@ func (pbr psqlBookRepo) DeleteByID(ctx context.Context, id uuid.UUID) (b models.
 		&b.Status,
 		&b.CreatedAt,
 		&b.UpdatedAt,
+		&b.DeletedAt,
 	)
 
 	if AsNotFoundError(&err) {
@@ -253,3 +470,67 @@ func (pbr psqlBookRepo) DeleteByID(ctx context.Context, id uuid.UUID) (b models.
 
 	return
 }
+
+func (pbr psqlBookRepo) RestoreByID(ctx context.Context, id uuid.UUID, status models.BookStatus, deletedAfter time.Time) (b models.Book, err error) {
+	row := pbr.db.QueryRowEx(ctx, bookRestoreByID, nil, status, id, models.BookStatusDeleted, deletedAfter)
+
+	err = row.Scan(
+		&b.ID,
+		&b.Title,
+		&b.Description,
+		&b.AuthorID,
+		&b.BookPath,
+		&b.CoverPath,
+		&b.Status,
+		&b.CreatedAt,
+		&b.UpdatedAt,
+		&b.DeletedAt,
+	)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (pbr psqlBookRepo) PurgeDeletedBefore(ctx context.Context, before time.Time) ([]models.Book, error) {
+	rows, err := pbr.db.QueryEx(ctx, bookPurgeDeletedBefore, nil, models.BookStatusDeleted, before)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	books := make([]models.Book, 0)
+
+	for rows.Next() {
+		book := models.Book{}
+
+		err = rows.Scan(
+			&book.ID,
+			&book.Title,
+			&book.Description,
+			&book.AuthorID,
+			&book.BookPath,
+			&book.CoverPath,
+			&book.Status,
+			&book.CreatedAt,
+			&book.UpdatedAt,
+			&book.DeletedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		books = append(books, book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
@@ -0,0 +1,152 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx"
+	"github.com/marlonmp/books-app/models"
+)
+
+var errCaptured = errors.New("query captured")
+
+// captureDB records the queries instead of running them
+type captureDB struct {
+	DB
+
+	queries []string
+	values  [][]any
+}
+
+func (cdb *captureDB) QueryEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...any) (*pgx.Rows, error) {
+	cdb.queries = append(cdb.queries, sql)
+	cdb.values = append(cdb.values, args)
+
+	return nil, errCaptured
+}
+
+var placeholderRegexp = regexp.MustCompile(`\$(\d+)`)
+
+// checks that the query uses every value and no more, and that the given
+// sql is written with the placeholder of the value
+func checkPlaceholders(t *testing.T, query string, values []any, sql string, value any) {
+	t.Helper()
+
+	used := make(map[int]bool)
+
+	for _, m := range placeholderRegexp.FindAllStringSubmatch(query, -1) {
+		n, _ := strconv.Atoi(m[1])
+		used[n] = true
+	}
+
+	for n := 1; n <= len(values); n++ {
+		if !used[n] {
+			t.Errorf("$%d is not used in %s", n, query)
+		}
+	}
+
+	if len(used) != len(values) {
+		t.Errorf("%d placeholders for %d values in %s", len(used), len(values), query)
+	}
+
+	for i, v := range values {
+		if v != value {
+			continue
+		}
+
+		if want := strings.ReplaceAll(sql, "%s", "$"+strconv.Itoa(i+1)); !strings.Contains(query, want) {
+			t.Errorf("query %s has no %s", query, want)
+		}
+
+		return
+	}
+
+	t.Errorf("values %v have no %v", values, value)
+}
+
+func TestBookSearchPlaceholder(t *testing.T) {
+	yes := true
+
+	tests := []struct {
+		name string
+		bf   BookFilters
+		pf   PageFilters
+	}{
+		{
+			name: "only the search",
+			bf:   BookFilters{Search: "dune"},
+		},
+		{
+			name: "search with filters",
+			bf: BookFilters{
+				Search:         "dune",
+				AuthorUsername: "frank",
+				Statuses:       []models.BookStatus{models.BookStatusPublic},
+				HasFile:        &yes,
+				WithAuthor:     true,
+			},
+		},
+		{
+			name: "search after a cursor",
+			bf:   BookFilters{Search: "dune", AuthorID: uuid.New()},
+			pf: PageFilters{
+				Cursor: cursor{Sort: sortSignature([]sortKey{{column: bookSearchRank, desc: true}, bookIDSortKey}), Values: []string{"0.5", "a"}}.encode(),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &captureDB{}
+			repo := PSQLBookRepo(db)
+
+			_, err := repo.FilterMany(context.Background(), &tt.bf)
+
+			if !errors.Is(err, errCaptured) {
+				t.Fatalf("filter many: %v", err)
+			}
+
+			_, err = repo.FilterPage(context.Background(), &tt.bf, tt.pf)
+
+			if !errors.Is(err, errCaptured) {
+				t.Fatalf("filter page: %v", err)
+			}
+
+			for i, query := range db.queries {
+				checkPlaceholders(t, query, db.values[i], bookSearchFrom, tt.bf.Search)
+			}
+		})
+	}
+}
+
+func TestUserSearchPlaceholder(t *testing.T) {
+	uf := UserFilters{Search: "jane", Status: models.UserStatusActive, Limit: 10, Offset: 20}
+
+	db := &captureDB{}
+	repo := PSQLUserRepo(db)
+
+	_, err := repo.FilterMany(context.Background(), &uf)
+
+	if !errors.Is(err, errCaptured) {
+		t.Fatalf("filter many: %v", err)
+	}
+
+	keys := []sortKey{{column: userSearchRank, desc: true}, idSortKey}
+
+	_, err = repo.FilterPage(context.Background(), &uf, PageFilters{
+		Cursor: cursor{Sort: sortSignature(keys), Values: []string{"0.5", "a"}}.encode(),
+	})
+
+	if !errors.Is(err, errCaptured) {
+		t.Fatalf("filter page: %v", err)
+	}
+
+	for i, query := range db.queries {
+		checkPlaceholders(t, query, db.values[i], userSearchFrom, uf.Search)
+	}
+}
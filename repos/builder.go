@@ -0,0 +1,98 @@
+package repos
+
+import (
+	"strconv"
+	"strings"
+)
+
+// queryBuilder writes the select queries of the lists. It numbers the params
+// in the order they are added, and joins the conditions with a where and
+// ands, so the filters can be added in any order or not at all
+type queryBuilder struct {
+	sb     strings.Builder
+	values []any
+
+	// there is a condition, the next ones are added with an and
+	hasWhere bool
+}
+
+// writes the select of the columns from the given tables, it must be the
+// first thing written
+func (qb *queryBuilder) selectFrom(from string, columns ...string) {
+	qb.sb.WriteString(`select `)
+	qb.sb.WriteString(strings.Join(columns, `, `))
+	qb.sb.WriteString(` from `)
+	qb.sb.WriteString(from)
+}
+
+// adds a value to the params and returns its placeholder, like $1. The same
+// placeholder can be used many times
+func (qb *queryBuilder) param(v any) string {
+	qb.values = append(qb.values, v)
+
+	return `$` + strconv.Itoa(len(qb.values))
+}
+
+// adds the condition to the where, the values of the condition must be added
+// with param
+func (qb *queryBuilder) where(cond string) {
+	if qb.hasWhere {
+		qb.sb.WriteString(` and `)
+	} else {
+		qb.sb.WriteString(` where `)
+		qb.hasWhere = true
+	}
+
+	qb.sb.WriteString(cond)
+}
+
+// adds a condition that compares the column with the value, like
+// "status" = $1
+func (qb *queryBuilder) whereOp(column, op string, v any) {
+	qb.where(column + ` ` + op + ` ` + qb.param(v))
+}
+
+// adds a condition that matches the column with any of the values, the values
+// must be a slice that is sent as an array of the given type
+func (qb *queryBuilder) whereAny(column string, values any, typ string) {
+	qb.where(column + ` = any(` + qb.param(values) + `::` + typ + `[])`)
+}
+
+// writes the order by of the sort keys, nothing if there are no keys
+func (qb *queryBuilder) orderBy(keys []sortKey) {
+	if len(keys) == 0 {
+		return
+	}
+
+	qb.sb.WriteString(` order by `)
+
+	for i, key := range keys {
+		if i > 0 {
+			qb.sb.WriteString(`, `)
+		}
+
+		qb.sb.WriteString(key.column)
+
+		if key.desc {
+			qb.sb.WriteString(` desc`)
+		}
+	}
+}
+
+// writes the limit and the offset, the zero ones are left out
+func (qb *queryBuilder) limit(limit, offset int) {
+	if limit > 0 {
+		qb.sb.WriteString(` limit `)
+		qb.sb.WriteString(qb.param(limit))
+	}
+
+	if offset > 0 {
+		qb.sb.WriteString(` offset `)
+		qb.sb.WriteString(qb.param(offset))
+	}
+}
+
+// returns the query and the values of its params
+func (qb *queryBuilder) build() (string, []any) {
+	return qb.sb.String() + `;`, qb.values
+}
@@ -0,0 +1,167 @@
+package repos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	tests := []struct {
+		name       string
+		build      func(qb *queryBuilder)
+		wantQuery  string
+		wantValues []any
+	}{
+		{
+			name: "select",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`, `"title"`)
+			},
+			wantQuery: `select "id", "title" from "books";`,
+		},
+		{
+			name: "one condition",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.whereOp(`"status"`, `=`, 2)
+			},
+			wantQuery:  `select "id" from "books" where "status" = $1;`,
+			wantValues: []any{2},
+		},
+		{
+			name: "conditions joined with and",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.whereOp(`"status"`, `=`, 2)
+				qb.where(`"book_path" <> ''`)
+				qb.whereOp(`"created_at"`, `>=`, "2024-01-01")
+			},
+			wantQuery:  `select "id" from "books" where "status" = $1 and "book_path" <> '' and "created_at" >= $2;`,
+			wantValues: []any{2, "2024-01-01"},
+		},
+		{
+			name: "placeholder used twice",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"users"`, `"id"`)
+
+				p := qb.param("jane")
+				qb.where(`(` + p + ` = "username" or ` + p + ` = "nickname")`)
+				qb.whereOp(`"status"`, `=`, 1)
+			},
+			wantQuery:  `select "id" from "users" where ($1 = "username" or $1 = "nickname") and "status" = $2;`,
+			wantValues: []any{"jane", 1},
+		},
+		{
+			name: "param before the select",
+			build: func(qb *queryBuilder) {
+				p := qb.param("dune")
+				qb.selectFrom(`"books", f(`+p+`) as "search"`, `"id"`)
+				qb.whereOp(`"status"`, `=`, 2)
+			},
+			wantQuery:  `select "id" from "books", f($1) as "search" where "status" = $2;`,
+			wantValues: []any{"dune", 2},
+		},
+		{
+			name: "any",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.whereOp(`"author_id"`, `=`, "a")
+				qb.whereAny(`"status"`, []int32{1, 2}, `int`)
+			},
+			wantQuery:  `select "id" from "books" where "author_id" = $1 and "status" = any($2::int[]);`,
+			wantValues: []any{"a", []int32{1, 2}},
+		},
+		{
+			name: "order by",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.orderBy([]sortKey{{column: `"title"`}, {column: `"created_at"`, desc: true}, idSortKey})
+			},
+			wantQuery: `select "id" from "books" order by "title", "created_at" desc, "id";`,
+		},
+		{
+			name: "no order by",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.orderBy(nil)
+			},
+			wantQuery: `select "id" from "books";`,
+		},
+		{
+			name: "limit and offset",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.limit(10, 20)
+			},
+			wantQuery:  `select "id" from "books" limit $1 offset $2;`,
+			wantValues: []any{10, 20},
+		},
+		{
+			name: "limit without offset",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.limit(10, 0)
+			},
+			wantQuery:  `select "id" from "books" limit $1;`,
+			wantValues: []any{10},
+		},
+		{
+			// the offset must bind its own value, not the limit
+			name: "offset without limit",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.limit(0, 20)
+			},
+			wantQuery:  `select "id" from "books" offset $1;`,
+			wantValues: []any{20},
+		},
+		{
+			name: "no limit and offset",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.limit(0, 0)
+			},
+			wantQuery: `select "id" from "books";`,
+		},
+		{
+			name: "everything",
+			build: func(qb *queryBuilder) {
+				qb.selectFrom(`"books"`, `"id"`)
+				qb.whereOp(`"author_id"`, `=`, "a")
+				qb.whereAny(`"id"`, []string{"b", "c"}, `uuid`)
+				qb.orderBy([]sortKey{idSortKey})
+				qb.limit(5, 15)
+			},
+			wantQuery:  `select "id" from "books" where "author_id" = $1 and "id" = any($2::uuid[]) order by "id" limit $3 offset $4;`,
+			wantValues: []any{"a", []string{"b", "c"}, 5, 15},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var qb queryBuilder
+
+			tt.build(&qb)
+
+			query, values := qb.build()
+
+			if query != tt.wantQuery {
+				t.Errorf("query = %s\nwant    %s", query, tt.wantQuery)
+			}
+
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("values = %v, want %v", values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderParam(t *testing.T) {
+	var qb queryBuilder
+
+	for i, want := range []string{"$1", "$2", "$3", "$4", "$5", "$6", "$7", "$8", "$9", "$10"} {
+		if got := qb.param(i); got != want {
+			t.Errorf("param %d = %s, want %s", i, got, want)
+		}
+	}
+}
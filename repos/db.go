@@ -0,0 +1,72 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/jackc/pgx"
+)
+
+// DB is the set of pgx methods used by the repos, it's satisfied by
+// [pgx.Conn], [pgx.ConnPool] and [pgx.Tx]
+type DB interface {
+	QueryEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...any) (*pgx.Rows, error)
+
+	QueryRowEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...any) *pgx.Row
+
+	ExecEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...any) (pgx.CommandTag, error)
+}
+
+// TxDB is a [DB] that can begin transactions, it's satisfied by [pgx.Conn]
+// and [pgx.ConnPool]
+type TxDB interface {
+	DB
+
+	BeginEx(ctx context.Context, txOptions *pgx.TxOptions) (*pgx.Tx, error)
+}
+
+// Tx are the repos of a transaction, their writes are committed together
+type Tx struct {
+	Users      UserRepo
+	Books      BookRepo
+	Sessions   SessionRepo
+	Moderation ModerationRepo
+}
+
+// Transactor runs the writes that must be done all or none
+type Transactor interface {
+	// Runs the function in a transaction, it's committed if the function
+	// returns nil, else it's rolled back and the error is returned
+	InTx(ctx context.Context, fn func(tx Tx) error) error
+}
+
+type psqlTransactor struct {
+	db TxDB
+}
+
+func PSQLTransactor(db TxDB) Transactor {
+	return psqlTransactor{db}
+}
+
+func (pt psqlTransactor) InTx(ctx context.Context, fn func(tx Tx) error) error {
+	tx, err := pt.db.BeginEx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// it does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	err = fn(Tx{
+		Users:      PSQLUserRepo(tx),
+		Books:      PSQLBookRepo(tx),
+		Sessions:   PSQLSessionRepo(tx),
+		Moderation: PSQLModerationRepo(tx),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return tx.CommitEx(ctx)
+}
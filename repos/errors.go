@@ -2,12 +2,16 @@ package repos
 
 import (
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx"
 )
 
 type errorCode string
 
+// postgres error code for unique constraints violations
+const uniqueViolationCode = "23505"
+
 const (
 	NotFoundErrorCode     errorCode = "resource_not_found"
 	DoesNotExistErrorCode errorCode = "resource_does_not_exist"
@@ -16,8 +20,33 @@ const (
 
 	InvalidCredentialsErrorCode errorCode = "invalid_authentication_credentials"
 	MissingCredentialsErrorCode errorCode = "missing_authentication_credentials"
+	TwoFactorRequiredErrorCode  errorCode = "two_factor_required"
+
+	PermissionDeniedErrorCode errorCode = "permission_denied"
+
+	InvalidStatusTransitionErrorCode errorCode = "invalid_status_transition"
+
+	UnsupportedFileErrorCode errorCode = "unsupported_file"
+
+	TooManyAttemptsErrorCode errorCode = "too_many_attempts"
+
+	UnknownSortFieldErrorCode errorCode = "unknown_sort_field"
+	InvalidCursorErrorCode    errorCode = "invalid_cursor"
+
+	ValidationErrorCode errorCode = "validation_failed"
 )
 
+// CodedError is implemented by all the errors of this package, the code can
+// be sent to the clients to identify the error
+type CodedError interface {
+	error
+	Code() errorCode
+}
+
+func (ec errorCode) String() string {
+	return string(ec)
+}
+
 // NotFoundError must be returned when a resource may exist but after
 // searching found nothing.
 type NotFoundError struct {
@@ -37,7 +66,7 @@ func (enf NotFoundError) Code() errorCode {
 }
 
 func IsNotFoundError(err error) bool {
-	var enf *NotFoundError
+	var enf NotFoundError
 	return errors.As(err, &enf)
 }
 
@@ -76,7 +105,7 @@ func (dne DoesNotExistError) Code() errorCode {
 }
 
 func IsDoesNotExistError(err error) bool {
-	var dne *DoesNotExistError
+	var dne DoesNotExistError
 	return errors.As(err, &dne)
 }
 
@@ -97,10 +126,27 @@ func (ce ConflictError) Code() errorCode {
 }
 
 func IsConflictError(err error) bool {
-	var ce *ConflictError
+	var ce ConflictError
 	return errors.As(err, &ce)
 }
 
+// if the err is an unique violation, it gets wrapped into a ConflictError
+// and returns true, else do nothig and returns false
+func AsConflictError(err *error) bool {
+	if err == nil || *err == nil {
+		return false
+	}
+
+	var pgErr pgx.PgError
+
+	if errors.As(*err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		*err = ConflictError{*err}
+		return true
+	}
+
+	return false
+}
+
 // InvalidCredentialsError must be returned when the provided credentials
 // are invalid
 type InvalidCredentialsError struct {
@@ -119,6 +165,11 @@ func (ice InvalidCredentialsError) Code() errorCode {
 	return InvalidCredentialsErrorCode
 }
 
+func IsInvalidCredentialsError(err error) bool {
+	var ice InvalidCredentialsError
+	return errors.As(err, &ice)
+}
+
 // MissingCredentialsError must be returned when the credentials are not provided
 type MissingCredentialsError struct {
 	err error
@@ -135,3 +186,201 @@ func (ice MissingCredentialsError) Unwrap() error {
 func (ice MissingCredentialsError) Code() errorCode {
 	return MissingCredentialsErrorCode
 }
+
+// TwoFactorRequiredError must be returned when the password of an user with
+// two factor authentication is right, but the second factor was not provided
+type TwoFactorRequiredError struct {
+	// ticket to send with the code instead of signing in again, only for the
+	// sign ins that can't be repeated, like the ones with a provider
+	Ticket string
+
+	err error
+}
+
+func (tfre TwoFactorRequiredError) Error() string {
+	return "two factor required: a totp or recovery code must be provided"
+}
+
+func (tfre TwoFactorRequiredError) Unwrap() error {
+	return tfre.err
+}
+
+func (tfre TwoFactorRequiredError) Code() errorCode {
+	return TwoFactorRequiredErrorCode
+}
+
+// PermissionDeniedError must be returned when an user is authenticated but is
+// not allowed to perform an action over a resource
+type PermissionDeniedError struct {
+	err error
+}
+
+func (pde PermissionDeniedError) Error() string {
+	return "permission denied: you are not allowed to perform this action"
+}
+
+func (pde PermissionDeniedError) Unwrap() error {
+	return pde.err
+}
+
+func (pde PermissionDeniedError) Code() errorCode {
+	return PermissionDeniedErrorCode
+}
+
+func IsPermissionDeniedError(err error) bool {
+	var pde PermissionDeniedError
+	return errors.As(err, &pde)
+}
+
+// InvalidStatusTransitionError must be returned when a resource can't change
+// from its current status to the requested one
+type InvalidStatusTransitionError struct {
+	err error
+}
+
+func (iste InvalidStatusTransitionError) Error() string {
+	return "invalid status transition: the resource can't change to the requested status"
+}
+
+func (iste InvalidStatusTransitionError) Unwrap() error {
+	return iste.err
+}
+
+func (iste InvalidStatusTransitionError) Code() errorCode {
+	return InvalidStatusTransitionErrorCode
+}
+
+func IsInvalidStatusTransitionError(err error) bool {
+	var iste InvalidStatusTransitionError
+	return errors.As(err, &iste)
+}
+
+// UnsupportedFileError must be returned when an uploaded file has a type that
+// is not accepted
+type UnsupportedFileError struct {
+	err error
+}
+
+func (ufe UnsupportedFileError) Error() string {
+	return "unsupported file: the file type is not accepted"
+}
+
+func (ufe UnsupportedFileError) Unwrap() error {
+	return ufe.err
+}
+
+func (ufe UnsupportedFileError) Code() errorCode {
+	return UnsupportedFileErrorCode
+}
+
+func IsUnsupportedFileError(err error) bool {
+	var ufe UnsupportedFileError
+	return errors.As(err, &ufe)
+}
+
+// TooManyAttemptsError must be returned when an user or an ip is temporarily
+// locked after too many failed attempts
+type TooManyAttemptsError struct {
+	// time left until the lock ends
+	RetryAfter time.Duration
+
+	err error
+}
+
+func (tmae TooManyAttemptsError) Error() string {
+	return "too many attempts: try again later"
+}
+
+func (tmae TooManyAttemptsError) Unwrap() error {
+	return tmae.err
+}
+
+func (tmae TooManyAttemptsError) Code() errorCode {
+	return TooManyAttemptsErrorCode
+}
+
+func IsTooManyAttemptsError(err error) bool {
+	var tmae TooManyAttemptsError
+	return errors.As(err, &tmae)
+}
+
+// UnknownSortFieldError must be returned when a list is sorted by a field that
+// is not sortable
+type UnknownSortFieldError struct {
+	Field string
+
+	err error
+}
+
+func (usfe UnknownSortFieldError) Error() string {
+	return "unknown sort field: " + usfe.Field + " can't be used to sort"
+}
+
+func (usfe UnknownSortFieldError) Unwrap() error {
+	return usfe.err
+}
+
+func (usfe UnknownSortFieldError) Code() errorCode {
+	return UnknownSortFieldErrorCode
+}
+
+func IsUnknownSortFieldError(err error) bool {
+	var usfe UnknownSortFieldError
+	return errors.As(err, &usfe)
+}
+
+// InvalidCursorError must be returned when a page cursor can't be decoded or
+// was made for a list with other sort fields
+type InvalidCursorError struct {
+	err error
+}
+
+func (ice InvalidCursorError) Error() string {
+	return "invalid cursor: the cursor doesn't belong to this list"
+}
+
+func (ice InvalidCursorError) Unwrap() error {
+	return ice.err
+}
+
+func (ice InvalidCursorError) Code() errorCode {
+	return InvalidCursorErrorCode
+}
+
+func IsInvalidCursorError(err error) bool {
+	var ice InvalidCursorError
+	return errors.As(err, &ice)
+}
+
+// FieldError describes why a field of a payload is not valid, the code can be
+// used by the clients to show their own message
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError must be returned when a payload is not valid, it contains
+// every field that failed
+type ValidationError struct {
+	Fields []FieldError
+
+	err error
+}
+
+func (ve ValidationError) Error() string {
+	return "invalid payload: some fields are not valid"
+}
+
+func (ve ValidationError) Unwrap() error {
+	return ve.err
+}
+
+func (ve ValidationError) Code() errorCode {
+	return ValidationErrorCode
+}
+
+func IsValidationError(err error) bool {
+	var ve ValidationError
+	return errors.As(err, &ve)
+}
@@ -0,0 +1,237 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+type ExternalIdentityRepo interface {
+	// Creates one identity and returns the created identity, if the identity
+	// or the provider of the user are already linked, returns a [ConflictError]
+	CreateOne(ctx context.Context, ei models.ExternalIdentity) (models.ExternalIdentity, error)
+
+	// Returns the identity with the given subject in the provider, if find
+	// nothing, returns a [NotFoundError]
+	GetBySubject(ctx context.Context, provider, subject string) (models.ExternalIdentity, error)
+
+	// Returns the identities of the given user, the oldest first
+	FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.ExternalIdentity, error)
+
+	// Deletes and returns the identity of the given user in the provider, if
+	// find nothing, returns a [NotFoundError]
+	DeleteByProvider(ctx context.Context, userID uuid.UUID, provider string) (models.ExternalIdentity, error)
+}
+
+type psqlExternalIdentityRepo struct {
+	db DB
+}
+
+func PSQLExternalIdentityRepo(db DB) ExternalIdentityRepo {
+	return psqlExternalIdentityRepo{db}
+}
+
+func (peir psqlExternalIdentityRepo) CreateOne(ctx context.Context, ei models.ExternalIdentity) (models.ExternalIdentity, error) {
+	err := peir.
+		db.
+		QueryRowEx(ctx, externalIdentityCreateOne, nil, ei.UserID, ei.Provider, ei.Subject, ei.Email).
+		Scan(&ei.ID, &ei.CreatedAt)
+
+	if AsConflictError(&err) {
+		return models.ExternalIdentity{}, err
+	}
+
+	if err != nil {
+		return models.ExternalIdentity{}, err
+	}
+
+	return ei, nil
+}
+
+func (peir psqlExternalIdentityRepo) GetBySubject(ctx context.Context, provider, subject string) (ei models.ExternalIdentity, err error) {
+	err = peir.
+		db.
+		QueryRowEx(ctx, externalIdentityGetBySubject, nil, provider, subject).
+		Scan(&ei.ID, &ei.UserID, &ei.Provider, &ei.Subject, &ei.Email, &ei.CreatedAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (peir psqlExternalIdentityRepo) FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.ExternalIdentity, error) {
+	rows, err := peir.db.QueryEx(ctx, externalIdentityFilterByUser, nil, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	identities := make([]models.ExternalIdentity, 0)
+
+	for rows.Next() {
+		ei := models.ExternalIdentity{}
+
+		err = rows.Scan(&ei.ID, &ei.UserID, &ei.Provider, &ei.Subject, &ei.Email, &ei.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, ei)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+func (peir psqlExternalIdentityRepo) DeleteByProvider(ctx context.Context, userID uuid.UUID, provider string) (ei models.ExternalIdentity, err error) {
+	err = peir.
+		db.
+		QueryRowEx(ctx, externalIdentityDeleteByProvider, nil, userID, provider).
+		Scan(&ei.ID, &ei.UserID, &ei.Provider, &ei.Subject, &ei.Email, &ei.CreatedAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+type OIDCStateRepo interface {
+	// Creates one state and returns the created state
+	CreateOne(ctx context.Context, s models.OIDCState) (models.OIDCState, error)
+
+	// Deletes and returns the not expired state with the given hash of the
+	// provider, if find nothing, returns a [NotFoundError]
+	Consume(ctx context.Context, stateHash, provider string) (models.OIDCState, error)
+
+	// Deletes the expired states, returns the number of deleted states
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type psqlOIDCStateRepo struct {
+	db DB
+}
+
+func PSQLOIDCStateRepo(db DB) OIDCStateRepo {
+	return psqlOIDCStateRepo{db}
+}
+
+func (posr psqlOIDCStateRepo) CreateOne(ctx context.Context, s models.OIDCState) (models.OIDCState, error) {
+	err := posr.
+		db.
+		QueryRowEx(ctx, oidcStateCreateOne, nil, s.StateHash, s.Provider, s.Nonce, s.CodeVerifier, s.LinkUserID, s.ExpiresAt).
+		Scan(&s.ID, &s.CreatedAt)
+
+	if err != nil {
+		return models.OIDCState{}, err
+	}
+
+	return s, nil
+}
+
+func (posr psqlOIDCStateRepo) Consume(ctx context.Context, stateHash, provider string) (s models.OIDCState, err error) {
+	err = posr.
+		db.
+		QueryRowEx(ctx, oidcStateConsume, nil, stateHash, provider).
+		Scan(&s.ID, &s.StateHash, &s.Provider, &s.Nonce, &s.CodeVerifier, &s.LinkUserID, &s.CreatedAt, &s.ExpiresAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (posr psqlOIDCStateRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := posr.db.ExecEx(ctx, oidcStateDeleteExpired, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+type TwoFactorTicketRepo interface {
+	// Creates one ticket and returns the created ticket
+	CreateOne(ctx context.Context, t models.TwoFactorTicket) (models.TwoFactorTicket, error)
+
+	// Returns the not expired ticket with the given hash, if find nothing,
+	// returns a [NotFoundError]. The ticket is kept, so a wrong code can be
+	// sent again until the ticket expires
+	GetByHash(ctx context.Context, ticketHash string) (models.TwoFactorTicket, error)
+
+	// Deletes the ticket with the given id, if find nothing, returns a
+	// [NotFoundError]
+	DeleteByID(ctx context.Context, id uuid.UUID) error
+
+	// Deletes the expired tickets, returns the number of deleted tickets
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type psqlTwoFactorTicketRepo struct {
+	db DB
+}
+
+func PSQLTwoFactorTicketRepo(db DB) TwoFactorTicketRepo {
+	return psqlTwoFactorTicketRepo{db}
+}
+
+func (ptftr psqlTwoFactorTicketRepo) CreateOne(ctx context.Context, t models.TwoFactorTicket) (models.TwoFactorTicket, error) {
+	err := ptftr.
+		db.
+		QueryRowEx(ctx, twoFactorTicketCreateOne, nil, t.TicketHash, t.UserID, t.ExpiresAt).
+		Scan(&t.ID, &t.CreatedAt)
+
+	if err != nil {
+		return models.TwoFactorTicket{}, err
+	}
+
+	return t, nil
+}
+
+func (ptftr psqlTwoFactorTicketRepo) GetByHash(ctx context.Context, ticketHash string) (t models.TwoFactorTicket, err error) {
+	err = ptftr.
+		db.
+		QueryRowEx(ctx, twoFactorTicketGetByHash, nil, ticketHash).
+		Scan(&t.ID, &t.TicketHash, &t.UserID, &t.CreatedAt, &t.ExpiresAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (ptftr psqlTwoFactorTicketRepo) DeleteByID(ctx context.Context, id uuid.UUID) error {
+	tag, err := ptftr.db.ExecEx(ctx, twoFactorTicketDeleteByID, nil, id)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (ptftr psqlTwoFactorTicketRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := ptftr.db.ExecEx(ctx, twoFactorTicketDeleteExpired, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
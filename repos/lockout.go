@@ -0,0 +1,78 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/marlonmp/books-app/models"
+)
+
+// the newest lockout events first
+var lockoutSortKeys = []sortKey{{column: `"created_at"`, desc: true}, idSortKey}
+
+type LockoutRepo interface {
+	// Creates one lockout event and returns the created event
+	CreateOne(ctx context.Context, e models.LockoutEvent) (models.LockoutEvent, error)
+
+	// Returns the lockout events, the newest first. A zero limit returns all
+	// the events
+	FilterMany(ctx context.Context, limit, offset int) ([]models.LockoutEvent, error)
+}
+
+type psqlLockoutRepo struct {
+	db DB
+}
+
+func PSQLLockoutRepo(db DB) LockoutRepo {
+	return psqlLockoutRepo{db}
+}
+
+func (plr psqlLockoutRepo) CreateOne(ctx context.Context, e models.LockoutEvent) (models.LockoutEvent, error) {
+	err := plr.
+		db.
+		QueryRowEx(ctx, lockoutCreateOne, nil, e.Scope, e.Subject, e.Failures, e.LockedUntil).
+		Scan(&e.ID, &e.CreatedAt)
+
+	if err != nil {
+		return models.LockoutEvent{}, err
+	}
+
+	return e, nil
+}
+
+func (plr psqlLockoutRepo) FilterMany(ctx context.Context, limit, offset int) ([]models.LockoutEvent, error) {
+	var qb queryBuilder
+
+	qb.selectFrom(lockoutListFrom, lockoutListColumns)
+	qb.orderBy(lockoutSortKeys)
+	qb.limit(limit, offset)
+
+	query, values := qb.build()
+
+	rows, err := plr.db.QueryEx(ctx, query, nil, values...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := make([]models.LockoutEvent, 0)
+
+	for rows.Next() {
+		e := models.LockoutEvent{}
+
+		err = rows.Scan(&e.ID, &e.Scope, &e.Subject, &e.Failures, &e.LockedUntil, &e.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
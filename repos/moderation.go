@@ -0,0 +1,67 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+type ModerationRepo interface {
+	// Creates one moderation event and returns the created event
+	CreateOne(ctx context.Context, e models.ModerationEvent) (models.ModerationEvent, error)
+
+	// Returns the moderation events of the given user, the newest first
+	FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.ModerationEvent, error)
+}
+
+type psqlModerationRepo struct {
+	db DB
+}
+
+func PSQLModerationRepo(db DB) ModerationRepo {
+	return psqlModerationRepo{db}
+}
+
+func (pmr psqlModerationRepo) CreateOne(ctx context.Context, e models.ModerationEvent) (models.ModerationEvent, error) {
+	err := pmr.
+		db.
+		QueryRowEx(ctx, moderationEventCreateOne, nil, e.UserID, e.ModeratorID, e.Action, e.Reason, e.ExpiresAt).
+		Scan(&e.ID, &e.CreatedAt)
+
+	if err != nil {
+		return models.ModerationEvent{}, err
+	}
+
+	return e, nil
+}
+
+func (pmr psqlModerationRepo) FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.ModerationEvent, error) {
+	rows, err := pmr.db.QueryEx(ctx, moderationEventFilterByUser, nil, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := make([]models.ModerationEvent, 0)
+
+	for rows.Next() {
+		e := models.ModerationEvent{}
+
+		err = rows.Scan(&e.ID, &e.UserID, &e.ModeratorID, &e.Action, &e.Reason, &e.ExpiresAt, &e.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,244 @@
+package repos
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+const (
+	// rows of a page when the limit is not given
+	DefaultPageLimit = 20
+
+	MaxPageLimit = 100
+)
+
+// PageFilters select one page of a list. The pages start after the sort values
+// of a row instead of an offset, so they are fast at any depth and don't skip
+// or repeat rows when other rows are added
+type PageFilters struct {
+	// cursor taken from the next or prev of another page of the same list,
+	// empty for the first page
+	Cursor string
+
+	// max number of rows of the page, zero uses the default limit
+	Limit int
+
+	// counts all the rows that match the filters, it needs an extra query
+	WithTotal bool
+}
+
+// Page is one page of a list with the cursors of the pages around it
+type Page[T any] struct {
+	Items []T
+
+	// cursors of the next and previous pages, empty if there is no page
+	Next,
+	Prev string
+
+	// number of rows that match the filters, nil if it was not requested
+	Total *int64
+}
+
+// cursor is the position of a row in a list, the clients get it as an opaque
+// token
+type cursor struct {
+	// signature of the sort keys of the list, see sortSignature
+	Sort string `json:"s"`
+
+	// sort values of the row as text, the id is the last one
+	Values []string `json:"v"`
+
+	// the page is the one before the row instead of the one after it
+	Backward bool `json:"b,omitempty"`
+}
+
+func (c cursor) encode() string {
+	// a cursor is always marshaled
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodes the cursor of a list sorted by the keys, the empty token is the start
+// of the list. If the token is not valid or belongs to a list with other sort
+// keys, returns an [InvalidCursorError]
+func decodeCursor(token string, keys []sortKey) (cursor, error) {
+	if token == "" {
+		return cursor{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil {
+		return cursor{}, InvalidCursorError{err}
+	}
+
+	var c cursor
+
+	err = json.Unmarshal(data, &c)
+
+	if err != nil {
+		return cursor{}, InvalidCursorError{err}
+	}
+
+	if c.Sort != sortSignature(keys) || len(c.Values) != len(keys) {
+		return cursor{}, InvalidCursorError{}
+	}
+
+	return c, nil
+}
+
+// identifies the sort keys, so a cursor is not used with another sort
+func sortSignature(keys []sortKey) string {
+	h := fnv.New32a()
+
+	for _, key := range keys {
+		h.Write([]byte(key.column))
+
+		if key.desc {
+			h.Write([]byte(" desc"))
+		}
+
+		h.Write([]byte{','})
+	}
+
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
+
+// returns the sort keys as text columns, they are selected after the columns
+// of a list so the cursors can be built from the rows
+func sortColumns(keys []sortKey) []string {
+	columns := make([]string, len(keys))
+
+	for i, key := range keys {
+		columns[i] = `(` + key.column + `)::text`
+	}
+
+	return columns
+}
+
+// writes the rest of the query of a page after the filters. The page has one
+// more row than the limit to know if there are more rows, see newPage
+func writePage(qb *queryBuilder, keys []sortKey, c cursor, limit int) {
+	// the rows before the cursor are found in the reverse order
+	if c.Backward {
+		keys = reverseKeys(keys)
+	}
+
+	if len(c.Values) > 0 {
+		writeKeyset(qb, keys, c.Values)
+	}
+
+	qb.orderBy(keys)
+	qb.limit(limit+1, 0)
+}
+
+// adds the condition of the rows after the given sort values, like
+// (a > $1 or (a = $1 and b > $2)), the descending keys use <
+func writeKeyset(qb *queryBuilder, keys []sortKey, sortValues []string) {
+	params := make([]string, len(sortValues))
+
+	for i, v := range sortValues {
+		params[i] = qb.param(v)
+	}
+
+	var cond strings.Builder
+
+	cond.WriteString(`(`)
+
+	for i, key := range keys {
+		if i > 0 {
+			cond.WriteString(` or `)
+		}
+
+		cond.WriteString(`(`)
+
+		for j := 0; j < i; j++ {
+			cond.WriteString(keys[j].column + ` = ` + params[j] + ` and `)
+		}
+
+		op := `>`
+
+		if key.desc {
+			op = `<`
+		}
+
+		cond.WriteString(key.column + ` ` + op + ` ` + params[i])
+		cond.WriteString(`)`)
+	}
+
+	cond.WriteString(`)`)
+
+	qb.where(cond.String())
+}
+
+// builds the page from the rows found after or before the cursor, with their
+// sort values. There is one more row than the limit if there are more rows in
+// the direction of the cursor
+func newPage[T any](items []T, sortValues [][]string, keys []sortKey, c cursor, limit int) Page[T] {
+	more := len(items) > limit
+
+	if more {
+		items = items[:limit]
+		sortValues = sortValues[:limit]
+	}
+
+	// the rows before the cursor were found in the reverse order
+	if c.Backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+			sortValues[i], sortValues[j] = sortValues[j], sortValues[i]
+		}
+	}
+
+	page := Page[T]{Items: items}
+
+	if len(items) == 0 {
+		return page
+	}
+
+	sort := sortSignature(keys)
+
+	// going backward there is always a next page, the cursor comes from it.
+	// Going forward there is a previous page if there is a cursor
+	if more || c.Backward {
+		page.Next = cursor{Sort: sort, Values: sortValues[len(items)-1]}.encode()
+	}
+
+	if more && c.Backward || !c.Backward && len(c.Values) > 0 {
+		page.Prev = cursor{Sort: sort, Values: sortValues[0], Backward: true}.encode()
+	}
+
+	return page
+}
+
+// returns the limit of a page, the zero limit is the default and the big ones
+// are the max
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+
+	return min(limit, MaxPageLimit)
+}
+
+// returns the result of a query that selects the count of rows of a list
+func countList(ctx context.Context, db DB, qb *queryBuilder) (*int64, error) {
+	var total int64
+
+	query, values := qb.build()
+
+	err := db.
+		QueryRowEx(ctx, query, nil, values...).
+		Scan(&total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &total, nil
+}
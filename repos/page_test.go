@@ -0,0 +1,346 @@
+package repos
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWriteKeyset(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []sortKey
+		values     []string
+		wantQuery  string
+		wantValues []any
+	}{
+		{
+			name:       "only the id",
+			keys:       []sortKey{idSortKey},
+			values:     []string{"a"},
+			wantQuery:  `select "id" from "books" where "status" = $1 and (("id" > $2));`,
+			wantValues: []any{2, "a"},
+		},
+		{
+			name:       "descending",
+			keys:       []sortKey{{column: `"created_at"`, desc: true}, idSortKey},
+			values:     []string{"2024", "a"},
+			wantQuery:  `select "id" from "books" where "status" = $1 and (("created_at" < $2) or ("created_at" = $2 and "id" > $3));`,
+			wantValues: []any{2, "2024", "a"},
+		},
+		{
+			name:   "mixed",
+			keys:   []sortKey{{column: `"title"`}, {column: `"created_at"`, desc: true}, idSortKey},
+			values: []string{"dune", "2024", "a"},
+			wantQuery: `select "id" from "books" where "status" = $1 and (("title" > $2) or ` +
+				`("title" = $2 and "created_at" < $3) or ` +
+				`("title" = $2 and "created_at" = $3 and "id" > $4));`,
+			wantValues: []any{2, "dune", "2024", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var qb queryBuilder
+
+			qb.selectFrom(`"books"`, `"id"`)
+			qb.whereOp(`"status"`, `=`, 2)
+
+			writeKeyset(&qb, tt.keys, tt.values)
+
+			query, values := qb.build()
+
+			if query != tt.wantQuery {
+				t.Errorf("query = %s\nwant    %s", query, tt.wantQuery)
+			}
+
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("values = %v, want %v", values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestWritePage(t *testing.T) {
+	keys := []sortKey{{column: `"title"`}, idSortKey}
+
+	tests := []struct {
+		name       string
+		cursor     cursor
+		wantQuery  string
+		wantValues []any
+	}{
+		{
+			name:       "first page",
+			wantQuery:  `select "id" from "books" order by "title", "id" limit $1;`,
+			wantValues: []any{11},
+		},
+		{
+			name:       "forward",
+			cursor:     cursor{Values: []string{"dune", "a"}},
+			wantQuery:  `select "id" from "books" where (("title" > $1) or ("title" = $1 and "id" > $2)) order by "title", "id" limit $3;`,
+			wantValues: []any{"dune", "a", 11},
+		},
+		{
+			// the rows before the cursor are found in the reverse order
+			name:       "backward",
+			cursor:     cursor{Values: []string{"dune", "a"}, Backward: true},
+			wantQuery:  `select "id" from "books" where (("title" < $1) or ("title" = $1 and "id" < $2)) order by "title" desc, "id" desc limit $3;`,
+			wantValues: []any{"dune", "a", 11},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var qb queryBuilder
+
+			qb.selectFrom(`"books"`, `"id"`)
+
+			writePage(&qb, keys, tt.cursor, 10)
+
+			query, values := qb.build()
+
+			if query != tt.wantQuery {
+				t.Errorf("query = %s\nwant    %s", query, tt.wantQuery)
+			}
+
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("values = %v, want %v", values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	keys := []sortKey{{column: `"title"`, desc: true}, idSortKey}
+
+	tests := []cursor{
+		{Sort: sortSignature(keys), Values: []string{"dune", "a"}},
+		{Sort: sortSignature(keys), Values: []string{"", "a"}, Backward: true},
+		{Sort: sortSignature(keys), Values: []string{`"quoted", with commas`, "a"}},
+	}
+
+	for _, want := range tests {
+		got, err := decodeCursor(want.encode(), keys)
+
+		if err != nil {
+			t.Fatalf("decode %+v: %v", want, err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decoded = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	keys := []sortKey{{column: `"title"`}, idSortKey}
+	otherKeys := []sortKey{{column: `"title"`, desc: true}, idSortKey}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "valid",
+			token: cursor{Sort: sortSignature(keys), Values: []string{"dune", "a"}}.encode(),
+		},
+		{
+			name:    "other sort",
+			token:   cursor{Sort: sortSignature(otherKeys), Values: []string{"dune", "a"}}.encode(),
+			wantErr: true,
+		},
+		{
+			name:    "missing values",
+			token:   cursor{Sort: sortSignature(keys), Values: []string{"a"}}.encode(),
+			wantErr: true,
+		},
+		{
+			name:    "not base64",
+			token:   "not a cursor!",
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			token:   base64.RawURLEncoding.EncodeToString([]byte("dune")),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := decodeCursor(tt.token, keys)
+
+			if tt.wantErr {
+				var ice InvalidCursorError
+
+				if !errors.As(err, &ice) {
+					t.Errorf("err = %v, want an InvalidCursorError", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+
+			if tt.token == "" && !reflect.DeepEqual(c, cursor{}) {
+				t.Errorf("cursor = %+v, want the start of the list", c)
+			}
+		})
+	}
+}
+
+func TestSortSignature(t *testing.T) {
+	asc := []sortKey{{column: `"title"`}, idSortKey}
+	desc := []sortKey{{column: `"title"`, desc: true}, idSortKey}
+	swapped := []sortKey{idSortKey, {column: `"title"`}}
+
+	if sortSignature(asc) != sortSignature([]sortKey{{column: `"title"`}, idSortKey}) {
+		t.Error("the same keys have different signatures")
+	}
+
+	if sortSignature(asc) == sortSignature(desc) {
+		t.Error("the ascending and descending keys have the same signature")
+	}
+
+	if sortSignature(asc) == sortSignature(swapped) {
+		t.Error("the keys in other order have the same signature")
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	keys := []sortKey{idSortKey}
+
+	// the values are the sort values too
+	values := func(items []string) [][]string {
+		sortValues := make([][]string, len(items))
+
+		for i, item := range items {
+			sortValues[i] = []string{item}
+		}
+
+		return sortValues
+	}
+
+	tests := []struct {
+		name      string
+		items     []string
+		cursor    cursor
+		wantItems []string
+
+		// the first sort value of the next and prev cursors, empty if there is
+		// no page
+		wantNext,
+		wantPrev string
+	}{
+		{
+			name:      "only page",
+			items:     []string{"a", "b"},
+			wantItems: []string{"a", "b"},
+		},
+		{
+			name:      "first page",
+			items:     []string{"a", "b", "c"},
+			wantItems: []string{"a", "b"},
+			wantNext:  "b",
+		},
+		{
+			name:      "middle page",
+			items:     []string{"c", "d", "e"},
+			cursor:    cursor{Values: []string{"b"}},
+			wantItems: []string{"c", "d"},
+			wantNext:  "d",
+			wantPrev:  "c",
+		},
+		{
+			name:      "last page",
+			items:     []string{"e"},
+			cursor:    cursor{Values: []string{"d"}},
+			wantItems: []string{"e"},
+			wantPrev:  "e",
+		},
+		{
+			// the rows come in the reverse order and the extra row is the
+			// first one
+			name:      "backward",
+			items:     []string{"d", "c", "b"},
+			cursor:    cursor{Values: []string{"e"}, Backward: true},
+			wantItems: []string{"c", "d"},
+			wantNext:  "d",
+			wantPrev:  "c",
+		},
+		{
+			name:      "backward to the first page",
+			items:     []string{"b", "a"},
+			cursor:    cursor{Values: []string{"c"}, Backward: true},
+			wantItems: []string{"a", "b"},
+			wantNext:  "b",
+		},
+		{
+			name:      "empty",
+			items:     []string{},
+			cursor:    cursor{Values: []string{"z"}},
+			wantItems: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := newPage(tt.items, values(tt.items), keys, tt.cursor, 2)
+
+			if !reflect.DeepEqual(page.Items, tt.wantItems) {
+				t.Errorf("items = %v, want %v", page.Items, tt.wantItems)
+			}
+
+			checkCursor(t, "next", page.Next, keys, tt.wantNext, false)
+			checkCursor(t, "prev", page.Prev, keys, tt.wantPrev, true)
+		})
+	}
+}
+
+func checkCursor(t *testing.T, name, token string, keys []sortKey, want string, backward bool) {
+	t.Helper()
+
+	if want == "" {
+		if token != "" {
+			t.Errorf("%s = %q, want no page", name, token)
+		}
+
+		return
+	}
+
+	c, err := decodeCursor(token, keys)
+
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	if c.Values[0] != want || c.Backward != backward {
+		t.Errorf("%s = %+v, want after %q, backward %v", name, c, want, backward)
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	tests := []struct {
+		limit, want int
+	}{
+		{0, DefaultPageLimit},
+		{-1, DefaultPageLimit},
+		{1, 1},
+		{MaxPageLimit, MaxPageLimit},
+		{MaxPageLimit + 1, MaxPageLimit},
+	}
+
+	for _, tt := range tests {
+		if got := pageLimit(tt.limit); got != tt.want {
+			t.Errorf("pageLimit(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
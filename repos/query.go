@@ -1,11 +1,31 @@
 package repos
 
 const (
-	bookFilterMany = `
-		select
-			"id", "title", "description", "author_id", "book_path", "cover_path", "status", "created_at", "updated_at"
-		from "books"
-	`
+	// the book lists are built from these columns and tables with the
+	// filters, see psqlBookRepo.writeFilters. The columns are qualified
+	// because the users can be joined as the authors
+	bookListColumns = `"books"."id", "books"."title", "books"."description", "books"."author_id", "books"."book_path", ` +
+		`"books"."cover_path", "books"."status", "books"."created_at", "books"."updated_at", "books"."deleted_at"`
+
+	bookListFrom = `"books"`
+
+	bookAuthorColumns = `"authors"."id", "authors"."username", "authors"."nickname"`
+
+	bookAuthorJoin = ` join "users" as "authors" on "authors"."id" = "books"."author_id"`
+
+	// the text is escaped before it's highlighted, so the <mark> tags are its
+	// only html
+	bookSearchColumns = `ts_headline('english', html_escape("books"."title"), "search", 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'), ` +
+		`ts_headline('english', html_escape("books"."description"), "search", 'MaxFragments=2, StartSel=<mark>, StopSel=</mark>')`
+
+	// it goes after the joins, the %s is the placeholder of the search
+	bookSearchFrom = `, websearch_to_tsquery('english', %s) as "search"`
+
+	// generated column with the title and description, the matches in the
+	// title rank higher. See search.sql
+	bookSearchDocument = `"books"."search_document"`
+
+	bookSearchRank = `ts_rank(` + bookSearchDocument + `, "search")`
 
 	bookCreateOne = `
 		insert into "books" ("title", "description", "author_id", "book_path", "cover_path", "status")
@@ -15,7 +35,7 @@ const (
 
 	bookGetByID = `
 		select
-			"id", "title", "description", "author_id", "book_path", "cover_path", "status", "created_at", "updated_at"
+			"id", "title", "description", "author_id", "book_path", "cover_path", "status", "created_at", "updated_at", "deleted_at"
 		from "books"
 		where
 			"id" = $1;
@@ -24,42 +44,91 @@ const (
 	bookUpdateByID = `
 		update "books"
 		set
-			"title" = coalecase(nullif($1, ''), "title"),
-			"description" = coalecase(nullif($2, ''), "description"),
-			"author_id" = coalecase(nullif($3, ''), "author_id"),
-			"book_path" = coalecase(nullif($4, ''), "book_path"),
-			"cover_path" = coalecase(nullif($5, ''), "cover_path"),
-			"status" = coalecase(nullif($6, 0), "status"),
+			"title" = coalesce(nullif($1, ''), "title"),
+			"description" = coalesce(nullif($2, ''), "description"),
+			"author_id" = coalesce(nullif($3::uuid, '00000000-0000-0000-0000-000000000000'), "author_id"),
+			"book_path" = coalesce(nullif($4, ''), "book_path"),
+			"cover_path" = coalesce(nullif($5, ''), "cover_path"),
+			"status" = coalesce(nullif($6, 0), "status"),
 			"updated_at" = now()
 		where
 			"id" = $7
 		returning "id", "title", "description", "author_id", "book_path", "cover_path", "status", "created_at", "updated_at";
 	`
 
+	bookUpdateStatusByAuthor = `
+		update "books"
+		set
+			"status" = $1,
+			"updated_at" = now()
+		where
+			"author_id" = $2 and
+			"status" = $3;
+	`
+
+	// the deleted books are kept until the grace period ends, so they can be
+	// restored
 	bookDeleteByID = `
+		update "books"
+		set
+			"status" = $1,
+			"deleted_at" = now(),
+			"updated_at" = now()
+		where
+			"id" = $2 and
+			"status" <> $1
+		returning "id", "title", "description", "author_id", "book_path", "cover_path", "status", "created_at", "updated_at", "deleted_at";
+	`
+
+	bookRestoreByID = `
+		update "books"
+		set
+			"status" = $1,
+			"deleted_at" = null,
+			"updated_at" = now()
+		where
+			"id" = $2 and
+			"status" = $3 and
+			"deleted_at" > $4
+		returning "id", "title", "description", "author_id", "book_path", "cover_path", "status", "created_at", "updated_at", "deleted_at";
+	`
+
+	bookPurgeDeletedBefore = `
 		delete from "books"
 		where
-			"id" = $1
-		returning "id", "title", "description", "author_id", "book_path", "cover_path", "status", "created_at", "updated_at";
+			"status" = $1 and
+			"deleted_at" <= $2
+		returning "id", "title", "description", "author_id", "book_path", "cover_path", "status", "created_at", "updated_at", "deleted_at";
 	`
 )
 
 const (
-	userFilterMany = `
-		select
-			"id", "username", "nickname", "bio", "status, "created_at", "updated_at"
-		from "users"
-	`
+	// the user lists are built from these columns and tables with the
+	// filters, see psqlUserRepo.writeFilters
+	userListColumns = `"id", "username", "nickname", "bio", "status", "created_at", "updated_at"`
+
+	userListFrom = `"users"`
+
+	// the %s is the placeholder of the search, the filter and the rank use it
+	// as "search"
+	userSearchFrom = `, (select %s::text as "search") as "search"`
+
+	// the usernames and nicknames are searched by trigram similarity, so the
+	// typos still match, see search.sql
+	userSearchFilter = `("search" <% "username" or "search" <% "nickname")`
+
+	userSearchRank = `greatest(word_similarity("search", "username"), word_similarity("search", "nickname"))`
 
 	userCreateOne = `
-		insert into "users" ("username", "nickname", "email", "bio", "password", "status")
-			values ($1, $2, lower($3), $4, $5, $6)
+		insert into "users" ("username", "nickname", "email", "bio", "password", "status", "role")
+			values ($1, $2, lower($3), $4, $5, $6, $7)
 			returning "id", "email", "created_at", "updated_at";
 	`
 
 	userGetCredentialsByUsername = `
 		select
-			"id", "username", "nickname", "bio", "password", "status, "created_at", "updated_at"
+			"id", "username", "nickname", "email", "bio", "password", "status", "role", "created_at", "updated_at",
+			"totp_secret", "two_factor_enabled", "totp_last_step", coalesce("recovery_codes", '{}')
 		from "users"
 		where
 			"username" = $1 and
@@ -68,16 +137,27 @@ const (
 
 	userGetCredentialsByEmail = `
 		select
-			"id", "username", "nickname", "bio", "password", "status, "created_at", "updated_at"
+			"id", "username", "nickname", "email", "bio", "password", "status", "role", "created_at", "updated_at",
+			"totp_secret", "two_factor_enabled", "totp_last_step", coalesce("recovery_codes", '{}')
 		from "users"
 		where
 			"email" = lower($1) and
 			"status" = $2;
 	`
 
+	userGetCredentialsByID = `
+		select
+			"id", "username", "nickname", "email", "bio", "password", "status", "role", "created_at", "updated_at",
+			"totp_secret", "two_factor_enabled", "totp_last_step", coalesce("recovery_codes", '{}')
+		from "users"
+		where
+			"id" = $1 and
+			"status" = $2;
+	`
+
 	userGetByUsername = `
 		select
-			"id", "username", "nickname", "bio", "status, "created_at", "updated_at"
+			"id", "username", "nickname", "bio", "status", "created_at", "updated_at"
 		from "users"
 		where
 			"username" = $1 and
@@ -86,7 +166,7 @@ const (
 
 	userGetByID = `
 		select
-			"id", "username", "nickname", "email", "bio", "status, "created_at", "updated_at"
+			"id", "username", "nickname", "email", "bio", "status", "role", "banned_until", "created_at", "updated_at", "deleted_at"
 		from "users"
 		where
 			"id" = $1 and
@@ -96,11 +176,11 @@ const (
 	userUpdateByID = `
 		update "users"
 		set
-			"username" = coalecase(nullif($1, ''), "username"),
-			"nickname" = coalecase(nullif($2, ''), "nickname"),
-			"email" = coalecase(nullif(lower($3), ''), "email"),
-			"bio" = coalecase(nullif($4, ''), "bio"),
-			"status" = coalecase(nullif($5, 0), "status"),
+			"username" = coalesce(nullif($1, ''), "username"),
+			"nickname" = coalesce(nullif($2, ''), "nickname"),
+			"email" = coalesce(nullif(lower($3), ''), "email"),
+			"bio" = coalesce(nullif($4, ''), "bio"),
+			"status" = coalesce(nullif($5, 0), "status"),
 			"updated_at" = now()
 		where
 			"id" = $6 and
@@ -108,11 +188,525 @@ const (
 		returning "id", "username", "nickname", "email", "bio", "status", "created_at", "updated_at";
 	`
 
+	userUpdatePasswordByID = `
+		update "users"
+		set
+			"password" = $1,
+			"updated_at" = now()
+		where
+			"id" = $2 and
+			"status" = $3;
+	`
+
+	userUpdateRoleByID = `
+		update "users"
+		set
+			"role" = $1,
+			"updated_at" = now()
+		where
+			"id" = $2 and
+			"status" = $3;
+	`
+
+	userBanByID = `
+		update "users"
+		set
+			"status" = $1,
+			"banned_until" = $2,
+			"updated_at" = now()
+		where
+			"id" = $3 and
+			"status" = $4;
+	`
+
+	// the end of the ban is cleared, it only matters while the user is banned
+	userUpdateStatusByID = `
+		update "users"
+		set
+			"status" = $1,
+			"banned_until" = null,
+			"updated_at" = now()
+		where
+			"id" = $2 and
+			"status" = $3;
+	`
+
+	// the end of the ban is checked again, the user could have been banned
+	// again after its expired ban was found
+	userLiftBanByID = `
+		update "users"
+		set
+			"status" = $1,
+			"banned_until" = null,
+			"updated_at" = now()
+		where
+			"id" = $2 and
+			"status" = $3 and
+			"banned_until" <= now();
+	`
+
+	userFilterBanExpiredBefore = `
+		select "id" from "users"
+		where
+			"status" = $1 and
+			"banned_until" <= $2;
+	`
+
+	userUpdateTwoFactorByID = `
+		update "users"
+		set
+			"totp_secret" = $1,
+			"two_factor_enabled" = $2,
+			"totp_last_step" = $3,
+			"recovery_codes" = $4,
+			"updated_at" = now()
+		where
+			"id" = $5 and
+			"status" = $6;
+	`
+
+	// only a newer step is accepted, so a totp code can't be used twice
+	userUseTOTPStep = `
+		update "users"
+		set
+			"totp_last_step" = $1
+		where
+			"id" = $2 and
+			"two_factor_enabled" and
+			"totp_last_step" < $1;
+	`
+
+	// the code is removed in the same statement that finds it, so a recovery
+	// code can't be used twice
+	userUseRecoveryCode = `
+		update "users"
+		set
+			"recovery_codes" = array_remove("recovery_codes", $1)
+		where
+			"id" = $2 and
+			"two_factor_enabled" and
+			$1 = any("recovery_codes");
+	`
+
+	// the verifications are deleted in the same statement, so the foreign keys
+	// are checked after both deletes
+	userDeleteUnverifiedBefore = `
+		with "stale" as (
+			select "id" from "users"
+			where
+				"status" = $1 and
+				"created_at" < $2
+		), "stale_verifications" as (
+			delete from "user_verifications"
+			where "user_id" in (select "id" from "stale")
+		)
+		delete from "users"
+		where "id" in (select "id" from "stale");
+	`
+
+	// the deleted users are kept until the grace period ends, so they can be
+	// restored
 	userDeleteByID = `
+		update "users"
+		set
+			"status" = $1,
+			"banned_until" = null,
+			"deleted_at" = now(),
+			"updated_at" = now()
+		where
+			"id" = $2 and
+			"status" = $3
+		returning "id", "username", "nickname", "email", "bio", "status", "created_at", "updated_at", "deleted_at";
+	`
+
+	userRestoreByID = `
+		update "users"
+		set
+			"status" = $1,
+			"banned_until" = $2,
+			"deleted_at" = null,
+			"updated_at" = now()
+		where
+			"id" = $3 and
+			"status" = $4 and
+			"deleted_at" > $5;
+	`
+
+	userFilterDeletedBefore = `
+		select "id" from "users"
+		where
+			"status" = $1 and
+			"deleted_at" <= $2;
+	`
+
+	// the rows of the user are deleted in the same statement, so the foreign
+	// keys are checked after all the deletes. The moderation events done by
+	// the user are kept without moderator
+	userPurgeByID = `
+		with "purged" as (
+			select "id" from "users"
+			where
+				"id" = $1 and
+				"status" = $2 and
+				"deleted_at" <= $3
+		), "purged_books" as (
+			delete from "books"
+			where "author_id" in (select "id" from "purged")
+		), "purged_sessions" as (
+			delete from "sessions"
+			where "user_id" in (select "id" from "purged")
+		), "purged_api_tokens" as (
+			delete from "api_tokens"
+			where "user_id" in (select "id" from "purged")
+		), "purged_identities" as (
+			delete from "external_identities"
+			where "user_id" in (select "id" from "purged")
+		), "purged_oidc_states" as (
+			delete from "oidc_states"
+			where "link_user_id" in (select "id" from "purged")
+		), "purged_two_factor_tickets" as (
+			delete from "two_factor_tickets"
+			where "user_id" in (select "id" from "purged")
+		), "purged_verifications" as (
+			delete from "user_verifications"
+			where "user_id" in (select "id" from "purged")
+		), "purged_password_resets" as (
+			delete from "password_resets"
+			where "user_id" in (select "id" from "purged")
+		), "purged_moderation_events" as (
+			delete from "moderation_events"
+			where "user_id" in (select "id" from "purged")
+		), "moderated_events" as (
+			update "moderation_events"
+			set
+				"moderator_id" = null
+			where "moderator_id" in (select "id" from "purged")
+		)
 		delete from "users"
+		where "id" in (select "id" from "purged");
+	`
+)
+
+const (
+	sessionCreateOne = `
+		insert into "sessions" ("user_id", "token_hash", "user_agent", "ip", "expires_at")
+			values ($1, $2, $3, $4, $5)
+			returning "id", "created_at";
+	`
+
+	sessionGetByTokenHash = `
+		select
+			"id", "user_id", "token_hash", "user_agent", "ip", "created_at", "expires_at"
+		from "sessions"
+		where
+			"token_hash" = $1 and
+			"expires_at" > now();
+	`
+
+	sessionFilterByUser = `
+		select
+			"id", "user_id", "token_hash", "user_agent", "ip", "created_at", "expires_at"
+		from "sessions"
+		where
+			"user_id" = $1 and
+			"expires_at" > now()
+		order by "created_at" desc;
+	`
+
+	sessionDeleteByID = `
+		delete from "sessions"
 		where
 			"id" = $1 and
-			"status" = $2
-		returning "id", "username", "nickname", "email", "bio", "status", "created_at", "updated_at";
+			"user_id" = $2
+		returning "id", "user_id", "token_hash", "user_agent", "ip", "created_at", "expires_at";
+	`
+
+	sessionDeleteByUser = `
+		delete from "sessions"
+		where
+			"user_id" = $1;
+	`
+
+	sessionDeleteExpired = `
+		delete from "sessions"
+		where
+			"expires_at" <= now();
+	`
+)
+
+const (
+	verificationCreateOne = `
+		insert into "user_verifications" ("user_id", "token_hash", "expires_at")
+			values ($1, $2, $3)
+			returning "id", "created_at";
+	`
+
+	verificationGetByTokenHash = `
+		select
+			"id", "user_id", "token_hash", "created_at", "expires_at"
+		from "user_verifications"
+		where
+			"token_hash" = $1 and
+			"expires_at" > now();
+	`
+
+	verificationDeleteByUser = `
+		delete from "user_verifications"
+		where
+			"user_id" = $1;
+	`
+)
+
+const (
+	attemptGet = `
+		select
+			"scope", "subject", "failures", "locked_until", "updated_at"
+		from "sign_in_attempts"
+		where
+			"scope" = $1 and
+			"subject" = $2;
+	`
+
+	// the failures older than the window are forgotten, so the count starts
+	// again
+	attemptRegisterFailure = `
+		insert into "sign_in_attempts" ("scope", "subject", "failures", "locked_until", "updated_at")
+			values ($1, $2, 1, 'epoch', now())
+		on conflict ("scope", "subject") do update
+		set
+			"failures" = case
+				when "sign_in_attempts"."updated_at" < now() - $3::interval then 1
+				else "sign_in_attempts"."failures" + 1
+			end,
+			"updated_at" = now()
+		returning "scope", "subject", "failures", "locked_until", "updated_at";
+	`
+
+	attemptLock = `
+		update "sign_in_attempts"
+		set
+			"locked_until" = $3
+		where
+			"scope" = $1 and
+			"subject" = $2;
+	`
+
+	attemptReset = `
+		delete from "sign_in_attempts"
+		where
+			"scope" = $1 and
+			"subject" = $2;
+	`
+
+	// the failures out of the window would be forgotten by the next failure
+	// anyway, the locked attempts are kept until the lock ends
+	attemptDeleteStale = `
+		delete from "sign_in_attempts"
+		where
+			"updated_at" < now() - $1::interval and
+			"locked_until" <= now();
+	`
+)
+
+const (
+	lockoutCreateOne = `
+		insert into "lockout_events" ("scope", "subject", "failures", "locked_until")
+			values ($1, $2, $3, $4)
+			returning "id", "created_at";
+	`
+
+	lockoutListColumns = `"id", "scope", "subject", "failures", "locked_until", "created_at"`
+
+	lockoutListFrom = `"lockout_events"`
+)
+
+const (
+	passwordResetCreateOne = `
+		insert into "password_resets" ("user_id", "token_hash", "expires_at")
+			values ($1, $2, $3)
+			returning "id", "created_at";
+	`
+
+	passwordResetGetByTokenHash = `
+		select
+			"id", "user_id", "token_hash", "created_at", "expires_at"
+		from "password_resets"
+		where
+			"token_hash" = $1 and
+			"used_at" is null and
+			"expires_at" > now();
+	`
+
+	// the token is marked as used in the same statement that checks it, so
+	// it can't be used twice by concurrent requests
+	passwordResetConsume = `
+		update "password_resets"
+		set
+			"used_at" = now()
+		where
+			"token_hash" = $1 and
+			"used_at" is null and
+			"expires_at" > now()
+		returning "id", "user_id", "token_hash", "created_at", "expires_at";
+	`
+
+	passwordResetDeleteByUser = `
+		delete from "password_resets"
+		where
+			"user_id" = $1;
+	`
+)
+
+const (
+	apiTokenCreateOne = `
+		insert into "api_tokens" ("user_id", "name", "token_hash", "scopes", "expires_at")
+			values ($1, $2, $3, $4, $5)
+			returning "id", "created_at";
+	`
+
+	// the last use is tracked in the same statement that checks the token,
+	// the tokens of the inactive users are not accepted
+	apiTokenUseByTokenHash = `
+		update "api_tokens" as "t"
+		set
+			"last_used_at" = now()
+		from "users" as "u"
+		where
+			"t"."token_hash" = $1 and
+			("t"."expires_at" is null or "t"."expires_at" > now()) and
+			"u"."id" = "t"."user_id" and
+			"u"."status" = $2
+		returning "t"."id", "t"."user_id", "t"."name", "t"."token_hash", "t"."scopes", "t"."expires_at", "t"."last_used_at", "t"."created_at";
+	`
+
+	apiTokenFilterByUser = `
+		select
+			"id", "user_id", "name", "token_hash", "scopes", "expires_at", "last_used_at", "created_at"
+		from "api_tokens"
+		where
+			"user_id" = $1
+		order by "created_at" desc;
+	`
+
+	apiTokenDeleteByID = `
+		delete from "api_tokens"
+		where
+			"id" = $1 and
+			"user_id" = $2
+		returning "id", "user_id", "name", "token_hash", "scopes", "expires_at", "last_used_at", "created_at";
+	`
+
+	apiTokenDeleteByUser = `
+		delete from "api_tokens"
+		where
+			"user_id" = $1;
+	`
+)
+
+const (
+	externalIdentityCreateOne = `
+		insert into "external_identities" ("user_id", "provider", "subject", "email")
+			values ($1, $2, $3, lower($4))
+			returning "id", "created_at";
+	`
+
+	externalIdentityGetBySubject = `
+		select
+			"id", "user_id", "provider", "subject", "email", "created_at"
+		from "external_identities"
+		where
+			"provider" = $1 and
+			"subject" = $2;
+	`
+
+	externalIdentityFilterByUser = `
+		select
+			"id", "user_id", "provider", "subject", "email", "created_at"
+		from "external_identities"
+		where
+			"user_id" = $1
+		order by "created_at";
+	`
+
+	externalIdentityDeleteByProvider = `
+		delete from "external_identities"
+		where
+			"user_id" = $1 and
+			"provider" = $2
+		returning "id", "user_id", "provider", "subject", "email", "created_at";
+	`
+)
+
+const (
+	oidcStateCreateOne = `
+		insert into "oidc_states" ("state_hash", "provider", "nonce", "code_verifier", "link_user_id", "expires_at")
+			values ($1, $2, $3, $4, nullif($5::uuid, '00000000-0000-0000-0000-000000000000'), $6)
+			returning "id", "created_at";
+	`
+
+	// the state is deleted in the same statement that finds it, so it can't be
+	// used twice by concurrent requests
+	oidcStateConsume = `
+		delete from "oidc_states"
+		where
+			"state_hash" = $1 and
+			"provider" = $2 and
+			"expires_at" > now()
+		returning
+			"id", "state_hash", "provider", "nonce", "code_verifier",
+			coalesce("link_user_id", '00000000-0000-0000-0000-000000000000'::uuid), "created_at", "expires_at";
+	`
+
+	oidcStateDeleteExpired = `
+		delete from "oidc_states"
+		where
+			"expires_at" <= now();
+	`
+)
+
+const (
+	twoFactorTicketCreateOne = `
+		insert into "two_factor_tickets" ("ticket_hash", "user_id", "expires_at")
+			values ($1, $2, $3)
+			returning "id", "created_at";
+	`
+
+	twoFactorTicketGetByHash = `
+		select
+			"id", "ticket_hash", "user_id", "created_at", "expires_at"
+		from "two_factor_tickets"
+		where
+			"ticket_hash" = $1 and
+			"expires_at" > now();
+	`
+
+	twoFactorTicketDeleteByID = `
+		delete from "two_factor_tickets"
+		where
+			"id" = $1;
+	`
+
+	twoFactorTicketDeleteExpired = `
+		delete from "two_factor_tickets"
+		where
+			"expires_at" <= now();
+	`
+)
+
+const (
+	moderationEventCreateOne = `
+		insert into "moderation_events" ("user_id", "moderator_id", "action", "reason", "expires_at")
+			values ($1, nullif($2::uuid, '00000000-0000-0000-0000-000000000000'), $3, $4, $5)
+			returning "id", "created_at";
+	`
+
+	moderationEventFilterByUser = `
+		select
+			"id", "user_id", coalesce("moderator_id", '00000000-0000-0000-0000-000000000000'::uuid),
+			"action", "reason", "expires_at", "created_at"
+		from "moderation_events"
+		where
+			"user_id" = $1
+		order by "created_at" desc;
 	`
 )
@@ -0,0 +1,81 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+type PasswordResetRepo interface {
+	// Creates one password reset and returns the created reset
+	CreateOne(ctx context.Context, pr models.PasswordReset) (models.PasswordReset, error)
+
+	// Returns the not used and not expired reset with the given token hash, if
+	// find nothing, returns a [NotFoundError]
+	GetByTokenHash(ctx context.Context, tokenHash string) (models.PasswordReset, error)
+
+	// Marks as used and returns the not used and not expired reset with the
+	// given token hash, if find nothing, returns a [NotFoundError]
+	Consume(ctx context.Context, tokenHash string) (models.PasswordReset, error)
+
+	// Deletes all the resets of the given user
+	DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type psqlPasswordResetRepo struct {
+	db DB
+}
+
+func PSQLPasswordResetRepo(db DB) PasswordResetRepo {
+	return psqlPasswordResetRepo{db}
+}
+
+func (prr psqlPasswordResetRepo) CreateOne(ctx context.Context, pr models.PasswordReset) (models.PasswordReset, error) {
+	err := prr.
+		db.
+		QueryRowEx(ctx, passwordResetCreateOne, nil, pr.UserID, pr.TokenHash, pr.ExpiresAt).
+		Scan(&pr.ID, &pr.CreatedAt)
+
+	if err != nil {
+		return models.PasswordReset{}, err
+	}
+
+	return pr, nil
+}
+
+func (prr psqlPasswordResetRepo) GetByTokenHash(ctx context.Context, tokenHash string) (pr models.PasswordReset, err error) {
+	err = prr.
+		db.
+		QueryRowEx(ctx, passwordResetGetByTokenHash, nil, tokenHash).
+		Scan(&pr.ID, &pr.UserID, &pr.TokenHash, &pr.CreatedAt, &pr.ExpiresAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (prr psqlPasswordResetRepo) Consume(ctx context.Context, tokenHash string) (pr models.PasswordReset, err error) {
+	err = prr.
+		db.
+		QueryRowEx(ctx, passwordResetConsume, nil, tokenHash).
+		Scan(&pr.ID, &pr.UserID, &pr.TokenHash, &pr.CreatedAt, &pr.ExpiresAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (prr psqlPasswordResetRepo) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	tag, err := prr.db.ExecEx(ctx, passwordResetDeleteByUser, nil, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
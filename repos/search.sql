@@ -0,0 +1,37 @@
+-- Schema used by the searches of the book and user lists, see query.go. It
+-- must be applied after the books and users tables are created, and it can
+-- be applied again.
+
+-- the trigram similarity of the user search, see userSearchFilter
+create extension if not exists "pg_trgm";
+
+-- escapes the text before ts_headline adds the <mark> tags, so the tags are
+-- the only html of the highlights, see bookSearchColumns
+create or replace function "html_escape"(text) returns text
+	language sql
+	immutable
+	strict
+	parallel safe
+	as $$
+		select replace(replace(replace(replace(replace($1,
+			'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;');
+	$$;
+
+-- the document of the book search, the matches in the title rank higher than
+-- the matches in the description, see bookSearchDocument
+alter table "books"
+	add column if not exists "search_document" tsvector
+	generated always as (
+		setweight(to_tsvector('english', coalesce("title", '')), 'A') ||
+		setweight(to_tsvector('english', coalesce("description", '')), 'B')
+	) stored;
+
+create index if not exists "books_search_document_idx"
+	on "books" using gin ("search_document");
+
+-- the word_similarity operator <% of the user search uses these indexes
+create index if not exists "users_username_trgm_idx"
+	on "users" using gin ("username" gin_trgm_ops);
+
+create index if not exists "users_nickname_trgm_idx"
+	on "users" using gin ("nickname" gin_trgm_ops);
@@ -0,0 +1,128 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+type SessionRepo interface {
+	// Creates one session and returns the created session
+	CreateOne(ctx context.Context, s models.Session) (models.Session, error)
+
+	// Returns the not expired session with the given token hash, if find
+	// nothing, returns a [NotFoundError]
+	GetByTokenHash(ctx context.Context, tokenHash string) (models.Session, error)
+
+	// Returns the not expired sessions of the given user, the newest first
+	FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+
+	// Deletes and returns the session with the given id of the given user, if
+	// find nothing, returns a [NotFoundError]
+	DeleteByID(ctx context.Context, id, userID uuid.UUID) (models.Session, error)
+
+	// Deletes all the sessions of the given user, returns the number of
+	// deleted sessions
+	DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+
+	// Deletes the expired sessions, returns the number of deleted sessions
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type psqlSessionRepo struct {
+	db DB
+}
+
+func PSQLSessionRepo(db DB) SessionRepo {
+	return psqlSessionRepo{db}
+}
+
+func (psr psqlSessionRepo) CreateOne(ctx context.Context, s models.Session) (models.Session, error) {
+	err := psr.
+		db.
+		QueryRowEx(ctx, sessionCreateOne, nil, s.UserID, s.TokenHash, s.UserAgent, s.IP, s.ExpiresAt).
+		Scan(&s.ID, &s.CreatedAt)
+
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	return s, nil
+}
+
+func (psr psqlSessionRepo) GetByTokenHash(ctx context.Context, tokenHash string) (s models.Session, err error) {
+	err = psr.
+		db.
+		QueryRowEx(ctx, sessionGetByTokenHash, nil, tokenHash).
+		Scan(&s.ID, &s.UserID, &s.TokenHash, &s.UserAgent, &s.IP, &s.CreatedAt, &s.ExpiresAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (psr psqlSessionRepo) FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	rows, err := psr.db.QueryEx(ctx, sessionFilterByUser, nil, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := make([]models.Session, 0)
+
+	for rows.Next() {
+		s := models.Session{}
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.TokenHash, &s.UserAgent, &s.IP, &s.CreatedAt, &s.ExpiresAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (psr psqlSessionRepo) DeleteByID(ctx context.Context, id, userID uuid.UUID) (s models.Session, err error) {
+	err = psr.
+		db.
+		QueryRowEx(ctx, sessionDeleteByID, nil, id, userID).
+		Scan(&s.ID, &s.UserID, &s.TokenHash, &s.UserAgent, &s.IP, &s.CreatedAt, &s.ExpiresAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (psr psqlSessionRepo) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	tag, err := psr.db.ExecEx(ctx, sessionDeleteByUser, nil, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (psr psqlSessionRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := psr.db.ExecEx(ctx, sessionDeleteExpired, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,59 @@
+package repos
+
+import "strings"
+
+// sortKey is one of the comma separated fields of an order by, like
+// -created_at. The - sorts in descending order, the + or nothing in ascending
+// order
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// the id is the last sort key of every list, it breaks the ties so the rows
+// with the same values always come in the same order
+var idSortKey = sortKey{column: `"id"`}
+
+// parses the order by with the sortable columns of a repo, the map keys are the
+// fields that the clients use and the values are the sql columns. If a field
+// is not sortable, returns an [UnknownSortFieldError]
+func parseOrderBy(orderBy string, columns map[string]string) ([]sortKey, error) {
+	if orderBy == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(orderBy, ",")
+	keys := make([]sortKey, 0, len(fields)+1)
+
+	for _, field := range fields {
+		// the + of a query param is decoded as a space
+		field = strings.TrimSpace(field)
+
+		desc := strings.HasPrefix(field, "-")
+
+		if desc || strings.HasPrefix(field, "+") {
+			field = field[1:]
+		}
+
+		column, ok := columns[field]
+
+		if !ok {
+			return nil, UnknownSortFieldError{Field: field}
+		}
+
+		keys = append(keys, sortKey{column, desc})
+	}
+
+	return keys, nil
+}
+
+// returns the keys in the opposite order
+func reverseKeys(keys []sortKey) []sortKey {
+	reversed := make([]sortKey, len(keys))
+
+	for i, key := range keys {
+		reversed[i] = sortKey{key.column, !key.desc}
+	}
+
+	return reversed
+}
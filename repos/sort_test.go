@@ -0,0 +1,134 @@
+package repos
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	columns := map[string]string{
+		"title":      `"title"`,
+		"created_at": `"created_at"`,
+	}
+
+	tests := []struct {
+		name    string
+		orderBy string
+		want    []sortKey
+		wantErr bool
+
+		// field of the UnknownSortFieldError
+		wantField string
+	}{
+		{
+			name:    "empty",
+			orderBy: "",
+		},
+		{
+			name:    "ascending",
+			orderBy: "title",
+			want:    []sortKey{{column: `"title"`}},
+		},
+		{
+			name:    "descending",
+			orderBy: "-title",
+			want:    []sortKey{{column: `"title"`, desc: true}},
+		},
+		{
+			name:    "explicit ascending",
+			orderBy: "+title",
+			want:    []sortKey{{column: `"title"`}},
+		},
+		{
+			// the + of a query param is decoded as a space
+			name:    "decoded plus",
+			orderBy: " title",
+			want:    []sortKey{{column: `"title"`}},
+		},
+		{
+			name:    "many fields",
+			orderBy: "-created_at,title",
+			want:    []sortKey{{column: `"created_at"`, desc: true}, {column: `"title"`}},
+		},
+		{
+			name:    "spaces around the fields",
+			orderBy: "title , -created_at",
+			want:    []sortKey{{column: `"title"`}, {column: `"created_at"`, desc: true}},
+		},
+		{
+			name:      "unknown field",
+			orderBy:   "password",
+			wantErr:   true,
+			wantField: "password",
+		},
+		{
+			name:      "unknown descending field",
+			orderBy:   "title,-password",
+			wantErr:   true,
+			wantField: "password",
+		},
+		{
+			name:      "empty field",
+			orderBy:   "title,",
+			wantErr:   true,
+			wantField: "",
+		},
+		{
+			name:      "only the sign",
+			orderBy:   "-",
+			wantErr:   true,
+			wantField: "",
+		},
+		{
+			// the columns can't be given directly
+			name:      "column",
+			orderBy:   `"title"`,
+			wantErr:   true,
+			wantField: `"title"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseOrderBy(tt.orderBy, columns)
+
+			if tt.wantErr {
+				var usfe UnknownSortFieldError
+
+				if !errors.As(err, &usfe) {
+					t.Fatalf("err = %v, want an UnknownSortFieldError", err)
+				}
+
+				if usfe.Field != tt.wantField {
+					t.Errorf("field = %q, want %q", usfe.Field, tt.wantField)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverseKeys(t *testing.T) {
+	keys := []sortKey{{column: `"title"`}, {column: `"created_at"`, desc: true}, idSortKey}
+
+	want := []sortKey{{column: `"title"`, desc: true}, {column: `"created_at"`}, {column: `"id"`, desc: true}}
+
+	if got := reverseKeys(keys); !reflect.DeepEqual(got, want) {
+		t.Errorf("reverseKeys = %v, want %v", got, want)
+	}
+
+	// the keys are not changed
+	if keys[0].desc || !keys[1].desc {
+		t.Errorf("keys changed to %v", keys)
+	}
+}
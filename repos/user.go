@@ -2,12 +2,15 @@ package repos
 
 import (
 	"context"
-	"strconv"
+	"fmt"
+	"html"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx"
 	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/valobjs"
 )
 
 type UserFilters struct {
@@ -19,136 +22,253 @@ type UserFilters struct {
 	Limit, Offset int
 }
 
+// the fields that UserFilters.OrderBy accepts and their columns
+var userSortColumns = map[string]string{
+	"username":   `"username"`,
+	"nickname":   `"nickname"`,
+	"created_at": `"created_at"`,
+	"updated_at": `"updated_at"`,
+}
+
 type UserRepo interface {
 	FilterMany(ctx context.Context, uf *UserFilters) ([]models.User, error)
 
+	// Returns one page of the users with the given filters, the limit and
+	// offset of the filters are ignored. If the cursor is not valid, returns
+	// an [InvalidCursorError]
+	FilterPage(ctx context.Context, uf *UserFilters, pf PageFilters) (Page[models.User], error)
+
 	CreateOne(ctx context.Context, u models.User) (models.User, error)
 
 	GetCredentialsByUsername(ctx context.Context, username string, status models.UserStatus) (models.User, error)
 
 	GetCredentialsByEmail(ctx context.Context, email string, status models.UserStatus) (models.User, error)
 
+	// Returns the user with the given id and status, including its password and
+	// its second factor, if find nothing, returns a [NotFoundError]
+	GetCredentialsByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (models.User, error)
+
 	GetByUsername(ctx context.Context, username string, status models.UserStatus) (models.User, error)
 
 	GetByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (models.User, error)
 
 	UpdateByID(ctx context.Context, id uuid.UUID, status models.UserStatus, u models.User) (models.User, error)
 
+	// Replaces the password of the user with the given id and status, if find
+	// nothing, returns a [NotFoundError]
+	UpdatePasswordByID(ctx context.Context, id uuid.UUID, status models.UserStatus, pwd valobjs.Password) error
+
+	// Replaces the role of the user with the given id and status, if find
+	// nothing, returns a [NotFoundError]
+	UpdateRoleByID(ctx context.Context, id uuid.UUID, status models.UserStatus, role models.Role) error
+
+	// Bans the active user with the given id until the given time, or forever
+	// if it's nil, if find nothing, returns a [NotFoundError]
+	BanByID(ctx context.Context, id uuid.UUID, until *time.Time) error
+
+	// Replaces the status of the user with the given id and status, if find
+	// nothing, returns a [NotFoundError]
+	UpdateStatusByID(ctx context.Context, id uuid.UUID, status, next models.UserStatus) error
+
+	// Returns the ids of the banned users whose ban ended before the given
+	// time
+	FilterBanExpiredBefore(ctx context.Context, before time.Time) ([]uuid.UUID, error)
+
+	// Activates the banned user with the given id if its ban already ended,
+	// if find nothing, returns a [NotFoundError]
+	LiftBanByID(ctx context.Context, id uuid.UUID) error
+
+	// Replaces the second factor of the user with the given id and status, if
+	// find nothing, returns a [NotFoundError]
+	UpdateTwoFactorByID(ctx context.Context, id uuid.UUID, status models.UserStatus, tf models.TwoFactor) error
+
+	// Marks the totp step as used by the user with the given id, if the step
+	// is not newer than the last used one, returns a [NotFoundError]
+	UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) error
+
+	// Removes the recovery code with the given hash from the user with the
+	// given id, if the user doesn't have it, returns a [NotFoundError]
+	UseRecoveryCode(ctx context.Context, id uuid.UUID, codeHash string) error
+
+	// Marks as deleted and returns the user with the given id and status, if
+	// find nothing, returns a [NotFoundError]. The user is kept until it's
+	// purged
 	DeleteByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (models.User, error)
+
+	// Restores with the given status and end of the ban the user with the
+	// given id that was deleted after the given time, if find nothing, returns
+	// a [NotFoundError]
+	RestoreByID(ctx context.Context, id uuid.UUID, status models.UserStatus, bannedUntil *time.Time, deletedAfter time.Time) error
+
+	// Returns the ids of the users deleted before the given time
+	FilterDeletedBefore(ctx context.Context, before time.Time) ([]uuid.UUID, error)
+
+	// Deletes forever the user with the given id if it was deleted before the
+	// given time, with its books and the rest of its rows, if find nothing,
+	// returns a [NotFoundError]
+	PurgeByID(ctx context.Context, id uuid.UUID, deletedBefore time.Time) error
+
+	// Deletes the unverified users created before the given time, returns the
+	// number of deleted users
+	DeleteUnverifiedBefore(ctx context.Context, before time.Time) (int64, error)
 }
 
 type psqlUserRepo struct {
-	conn *pgx.Conn
+	db DB
+}
+
+func PSQLUserRepo(db DB) UserRepo {
+	return psqlUserRepo{db}
 }
 
-func PSQLUserRepo(conn *pgx.Conn) UserRepo {
-	return psqlUserRepo{conn}
+// adds the conditions of the filters to the query
+func (pur psqlUserRepo) writeFilters(qb *queryBuilder, uf *UserFilters) {
+	if uf == nil {
+		return
+	}
+
+	// the search param is added by listFrom
+	if uf.Search != "" {
+		qb.where(userSearchFilter)
+	}
+
+	if uf.UserID != uuid.Nil {
+		qb.whereOp(`"id"`, `=`, uf.UserID)
+	}
+
+	if uf.Status != models.UserStatusUnknown {
+		qb.whereOp(`"status"`, `=`, uf.Status)
+	}
 }
 
-func (pur psqlUserRepo) buildFilters(uf *UserFilters) (string, []any) {
-	var filters strings.Builder
+// returns the tables of a user list, the search is added to the params of the
+// builder, so every builder needs its own tables
+func (pur psqlUserRepo) listFrom(qb *queryBuilder, uf *UserFilters) string {
+	if uf == nil || uf.Search == "" {
+		return userListFrom
+	}
 
-	values := make([]any, 0)
-	counter := 1
+	return userListFrom + fmt.Sprintf(userSearchFrom, qb.param(uf.Search))
+}
 
+// returns the sort keys of the list, they always end with the id
+func (pur psqlUserRepo) sortKeys(uf *UserFilters) ([]sortKey, error) {
 	if uf == nil {
 		uf = new(UserFilters)
 	}
 
-	if uf.UserID != uuid.Nil {
-		filters.WriteString(` and "id" = $`)
-		filters.WriteString(strconv.Itoa(counter))
+	keys, err := parseOrderBy(uf.OrderBy, userSortColumns)
 
-		counter++
-		values = append(values, uf.UserID)
+	if err != nil {
+		return nil, err
 	}
 
-	if uf.Status != models.UserStatusUnknown {
-		filters.WriteString(` and "status" = $`)
-		filters.WriteString(strconv.Itoa(counter))
-
-		counter++
-		values = append(values, uf.Status)
+	// the most similar users first
+	if len(keys) == 0 && uf.Search != "" {
+		keys = []sortKey{{column: userSearchRank, desc: true}}
 	}
 
-	if counter > 1 {
-		// remove the first ` and`
-		q := filters.String()[4:]
-		filters.Reset()
+	return append(keys, idSortKey), nil
+}
+
+func (pur psqlUserRepo) FilterMany(ctx context.Context, uf *UserFilters) ([]models.User, error) {
+	keys, err := pur.sortKeys(uf)
 
-		filters.WriteString(q)
+	if err != nil {
+		return nil, err
 	}
 
-	if uf.OrderBy != "" {
-		order := "asc"
-		orderField := uf.OrderBy
+	var qb queryBuilder
 
-		if uf.OrderBy[0] == '+' {
-			orderField = orderField[1:]
-		}
+	qb.selectFrom(pur.listFrom(&qb, uf), userListColumns)
 
-		if uf.OrderBy[0] == '-' {
-			order = "desc"
-			orderField = orderField[1:]
-		}
-		filters.WriteString(` order by "$`)
-		filters.WriteString(strconv.Itoa(counter))
-		filters.WriteString(`" `)
-		filters.WriteString(order)
+	pur.writeFilters(&qb, uf)
 
-		values = append(values, orderField)
+	qb.orderBy(keys)
+
+	if uf != nil {
+		qb.limit(uf.Limit, uf.Offset)
 	}
 
-	if uf.Limit > 0 {
-		filters.WriteString(` limit $`)
-		filters.WriteString(strconv.Itoa(counter))
+	query, values := qb.build()
+
+	users, _, err := pur.queryList(ctx, query, values, uf, 0)
+
+	return users, err
+}
+
+func (pur psqlUserRepo) FilterPage(ctx context.Context, uf *UserFilters, pf PageFilters) (Page[models.User], error) {
+	keys, err := pur.sortKeys(uf)
 
-		counter++
-		values = append(values, uf.Limit)
+	if err != nil {
+		return Page[models.User]{}, err
 	}
 
-	if uf.Offset > 0 {
-		filters.WriteString(` offset $`)
-		filters.WriteString(strconv.Itoa(counter))
+	c, err := decodeCursor(pf.Cursor, keys)
 
-		counter++
-		values = append(values, uf.Limit)
+	if err != nil {
+		return Page[models.User]{}, err
 	}
 
-	return filters.String(), values
-}
+	limit := pageLimit(pf.Limit)
 
-func (pur psqlUserRepo) buildFilterQuery(queryStr string, uf *UserFilters) (string, []any) {
-	var query strings.Builder
+	var qb queryBuilder
 
-	query.WriteString(queryStr)
+	qb.selectFrom(pur.listFrom(&qb, uf), append([]string{userListColumns}, sortColumns(keys)...)...)
 
-	filters, values := pur.buildFilters(uf)
+	pur.writeFilters(&qb, uf)
 
-	if len(filters) > 0 {
-		query.WriteString(filters)
+	writePage(&qb, keys, c, limit)
+
+	query, values := qb.build()
+
+	users, sortValues, err := pur.queryList(ctx, query, values, uf, len(keys))
+
+	if err != nil {
+		return Page[models.User]{}, err
 	}
 
-	query.WriteRune(';')
+	page := newPage(users, sortValues, keys, c, limit)
+
+	if pf.WithTotal {
+		var count queryBuilder
+
+		count.selectFrom(pur.listFrom(&count, uf), `count(*)`)
+
+		pur.writeFilters(&count, uf)
+
+		page.Total, err = countList(ctx, pur.db, &count)
+
+		if err != nil {
+			return Page[models.User]{}, err
+		}
+	}
 
-	return query.String(), values
+	return page, nil
 }
 
-func (pur psqlUserRepo) FilterMany(ctx context.Context, uf *UserFilters) ([]models.User, error) {
-	query, values := pur.buildFilterQuery(userFilterMany, uf)
+// returns the users of a list query with the given number of sort values of
+// every user
+func (pur psqlUserRepo) queryList(ctx context.Context, query string, values []any, uf *UserFilters, sortKeys int) ([]models.User, [][]string, error) {
+	if uf == nil {
+		uf = new(UserFilters)
+	}
 
-	rows, err := pur.conn.QueryEx(ctx, query, nil, values...)
+	rows, err := pur.db.QueryEx(ctx, query, nil, values...)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	defer rows.Close()
+
 	users := make([]models.User, 0)
+	sortValues := make([][]string, 0)
 
 	for rows.Next() {
 		u := models.User{}
 
-		err = rows.Scan(
+		dest := []any{
 			&u.ID,
 			&u.Username,
 			&u.Nickname,
@@ -156,28 +276,48 @@ func (pur psqlUserRepo) FilterMany(ctx context.Context, uf *UserFilters) ([]mode
 			&u.Status,
 			&u.CreatedAt,
 			&u.UpdatedAt,
-		)
+		}
+
+		userSortValues := make([]string, sortKeys)
+
+		for i := range userSortValues {
+			dest = append(dest, &userSortValues[i])
+		}
+
+		err = rows.Scan(dest...)
 
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+
+		if uf.Search != "" {
+			u.Highlight = &models.UserHighlight{
+				Username: highlightTerms(u.Username, uf.Search),
+				Nickname: highlightTerms(u.Nickname, uf.Search),
+			}
 		}
 
 		users = append(users, u)
+		sortValues = append(sortValues, userSortValues)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return users, nil
+	return users, sortValues, nil
 }
 
 func (pur psqlUserRepo) CreateOne(ctx context.Context, u models.User) (models.User, error) {
 	err := pur.
-		conn.
-		QueryRowEx(ctx, userCreateOne, nil, u.Username, u.Nickname, u.Email, u.Bio, u.Password, u.Status).
+		db.
+		QueryRowEx(ctx, userCreateOne, nil, u.Username, u.Nickname, u.Email, u.Bio, u.Password, u.Status, u.Role).
 		Scan(&u.ID, &u.Email, &u.CreatedAt, &u.UpdatedAt)
 
+	if AsConflictError(&err) {
+		return models.User{}, err
+	}
+
 	if err != nil {
 		return models.User{}, err
 	}
@@ -187,9 +327,10 @@ func (pur psqlUserRepo) CreateOne(ctx context.Context, u models.User) (models.Us
 
 func (pur psqlUserRepo) GetCredentialsByUsername(ctx context.Context, username string, status models.UserStatus) (u models.User, err error) {
 	err = pur.
-		conn.
+		db.
 		QueryRowEx(ctx, userGetCredentialsByUsername, nil, username, status).
-		Scan(&u.ID, &u.Username, &u.Nickname, &u.Bio, &u.Password, &u.Status, &u.CreatedAt, &u.UpdatedAt)
+		Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Bio, &u.Password, &u.Status, &u.Role, &u.CreatedAt, &u.UpdatedAt,
+			&u.TwoFactor.Secret, &u.TwoFactor.Enabled, &u.TwoFactor.LastStep, &u.TwoFactor.RecoveryCodes)
 
 	if AsNotFoundError(&err) {
 		return
@@ -200,9 +341,24 @@ func (pur psqlUserRepo) GetCredentialsByUsername(ctx context.Context, username s
 
 func (pur psqlUserRepo) GetCredentialsByEmail(ctx context.Context, email string, status models.UserStatus) (u models.User, err error) {
 	err = pur.
-		conn.
+		db.
 		QueryRowEx(ctx, userGetCredentialsByEmail, nil, email, status).
-		Scan(&p)
+		Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Bio, &u.Password, &u.Status, &u.Role, &u.CreatedAt, &u.UpdatedAt,
+			&u.TwoFactor.Secret, &u.TwoFactor.Enabled, &u.TwoFactor.LastStep, &u.TwoFactor.RecoveryCodes)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (pur psqlUserRepo) GetCredentialsByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (u models.User, err error) {
+	err = pur.
+		db.
+		QueryRowEx(ctx, userGetCredentialsByID, nil, id, status).
+		Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Bio, &u.Password, &u.Status, &u.Role, &u.CreatedAt, &u.UpdatedAt,
+			&u.TwoFactor.Secret, &u.TwoFactor.Enabled, &u.TwoFactor.LastStep, &u.TwoFactor.RecoveryCodes)
 
 	if AsNotFoundError(&err) {
 		return
@@ -213,9 +369,9 @@ func (pur psqlUserRepo) GetCredentialsByEmail(ctx context.Context, email string,
 
 func (pur psqlUserRepo) GetByUsername(ctx context.Context, username string, status models.UserStatus) (u models.User, err error) {
 	err = pur.
-		conn.
-		QueryRowEx(ctx, userGetByUsername, nil, u.Username, status).
-		Scan(&u.ID, &u.Username, &u.Nickname, &u.Bio, &u.Password, &u.Status, &u.CreatedAt, &u.UpdatedAt)
+		db.
+		QueryRowEx(ctx, userGetByUsername, nil, username, status).
+		Scan(&u.ID, &u.Username, &u.Nickname, &u.Bio, &u.Status, &u.CreatedAt, &u.UpdatedAt)
 
 	if AsNotFoundError(&err) {
 		return
@@ -226,9 +382,9 @@ func (pur psqlUserRepo) GetByUsername(ctx context.Context, username string, stat
 
 func (pur psqlUserRepo) GetByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (u models.User, err error) {
 	err = pur.
-		conn.
-		QueryRowEx(ctx, userGetByID, nil, u.ID, status).
-		Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Bio, &u.Status, &u.CreatedAt, &u.UpdatedAt)
+		db.
+		QueryRowEx(ctx, userGetByID, nil, id, status).
+		Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Bio, &u.Status, &u.Role, &u.BannedUntil, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
 
 	if AsNotFoundError(&err) {
 		return
@@ -239,22 +395,174 @@ func (pur psqlUserRepo) GetByID(ctx context.Context, id uuid.UUID, status models
 
 func (pur psqlUserRepo) UpdateByID(ctx context.Context, id uuid.UUID, status models.UserStatus, u models.User) (models.User, error) {
 	err := pur.
-		conn.
-		QueryRowEx(ctx, userUpdateByID, nil, u.Username, u.Nickname, u.Email, u.Bio, u.Status, u.ID, status).
+		db.
+		QueryRowEx(ctx, userUpdateByID, nil, u.Username, u.Nickname, u.Email, u.Bio, u.Status, id, status).
 		Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Bio, &u.Status, &u.CreatedAt, &u.UpdatedAt)
 
-	if AsNotFoundError(&err) {
+	if AsNotFoundError(&err) || AsConflictError(&err) {
+		return models.User{}, err
+	}
+
+	if err != nil {
 		return models.User{}, err
 	}
 
 	return u, nil
 }
 
+func (pur psqlUserRepo) UpdatePasswordByID(ctx context.Context, id uuid.UUID, status models.UserStatus, pwd valobjs.Password) error {
+	tag, err := pur.db.ExecEx(ctx, userUpdatePasswordByID, nil, pwd, id, status)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) UpdateRoleByID(ctx context.Context, id uuid.UUID, status models.UserStatus, role models.Role) error {
+	tag, err := pur.db.ExecEx(ctx, userUpdateRoleByID, nil, role, id, status)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) BanByID(ctx context.Context, id uuid.UUID, until *time.Time) error {
+	tag, err := pur.db.ExecEx(ctx, userBanByID, nil, models.UserStatusBanned, until, id, models.UserStatusActive)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) UpdateStatusByID(ctx context.Context, id uuid.UUID, status, next models.UserStatus) error {
+	tag, err := pur.db.ExecEx(ctx, userUpdateStatusByID, nil, next, id, status)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) LiftBanByID(ctx context.Context, id uuid.UUID) error {
+	tag, err := pur.db.ExecEx(ctx, userLiftBanByID, nil, models.UserStatusActive, id, models.UserStatusBanned)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) FilterBanExpiredBefore(ctx context.Context, before time.Time) ([]uuid.UUID, error) {
+	rows, err := pur.db.QueryEx(ctx, userFilterBanExpiredBefore, nil, models.UserStatusBanned, before)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+
+	for rows.Next() {
+		var id uuid.UUID
+
+		err = rows.Scan(&id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (pur psqlUserRepo) UpdateTwoFactorByID(ctx context.Context, id uuid.UUID, status models.UserStatus, tf models.TwoFactor) error {
+	codes := tf.RecoveryCodes
+
+	if codes == nil {
+		codes = []string{}
+	}
+
+	tag, err := pur.db.ExecEx(ctx, userUpdateTwoFactorByID, nil, tf.Secret, tf.Enabled, tf.LastStep, codes, id, status)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) error {
+	tag, err := pur.db.ExecEx(ctx, userUseTOTPStep, nil, step, id)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) UseRecoveryCode(ctx context.Context, id uuid.UUID, codeHash string) error {
+	tag, err := pur.db.ExecEx(ctx, userUseRecoveryCode, nil, codeHash, id)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
 func (pur psqlUserRepo) DeleteByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (u models.User, err error) {
 	err = pur.
-		conn.
-		QueryRowEx(ctx, userDeleteByID, nil, u.ID, status).
-		Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Bio, &u.Status, &u.CreatedAt, &u.UpdatedAt)
+		db.
+		QueryRowEx(ctx, userDeleteByID, nil, models.UserStatusDeleted, id, status).
+		Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Bio, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
 
 	if AsNotFoundError(&err) {
 		return
@@ -262,3 +570,130 @@ func (pur psqlUserRepo) DeleteByID(ctx context.Context, id uuid.UUID, status mod
 
 	return
 }
+
+func (pur psqlUserRepo) RestoreByID(ctx context.Context, id uuid.UUID, status models.UserStatus, bannedUntil *time.Time, deletedAfter time.Time) error {
+	tag, err := pur.db.ExecEx(ctx, userRestoreByID, nil, status, bannedUntil, id, models.UserStatusDeleted, deletedAfter)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) FilterDeletedBefore(ctx context.Context, before time.Time) ([]uuid.UUID, error) {
+	rows, err := pur.db.QueryEx(ctx, userFilterDeletedBefore, nil, models.UserStatusDeleted, before)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+
+	for rows.Next() {
+		var id uuid.UUID
+
+		err = rows.Scan(&id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (pur psqlUserRepo) PurgeByID(ctx context.Context, id uuid.UUID, deletedBefore time.Time) error {
+	tag, err := pur.db.ExecEx(ctx, userPurgeByID, nil, id, models.UserStatusDeleted, deletedBefore)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return NotFoundError{}
+	}
+
+	return nil
+}
+
+func (pur psqlUserRepo) DeleteUnverifiedBefore(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := pur.db.ExecEx(ctx, userDeleteUnverifiedBefore, nil, models.UserStatusUnverified, before)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// returns the html escaped text with the terms of the search between <mark>
+// tags, ignoring the case. The trigram search also matches the typos, those
+// are not highlighted
+func highlightTerms(text, search string) string {
+	marked := make([]bool, len(text))
+
+	for _, term := range strings.Fields(search) {
+		for i := range text {
+			n, ok := prefixFold(text[i:], term)
+
+			if !ok {
+				continue
+			}
+
+			for j := i; j < i+n; j++ {
+				marked[j] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+
+	for start := 0; start < len(text); {
+		end := start
+
+		for end < len(text) && marked[end] == marked[start] {
+			end++
+		}
+
+		if marked[start] {
+			sb.WriteString(`<mark>` + html.EscapeString(text[start:end]) + `</mark>`)
+		} else {
+			sb.WriteString(html.EscapeString(text[start:end]))
+		}
+
+		start = end
+	}
+
+	return sb.String()
+}
+
+// returns the length of the prefix of s that equals the prefix ignoring the
+// case
+func prefixFold(s, prefix string) (int, bool) {
+	n := 0
+
+	for _, p := range prefix {
+		r, size := utf8.DecodeRuneInString(s[n:])
+
+		if size == 0 || !strings.EqualFold(string(r), string(p)) {
+			return 0, false
+		}
+
+		n += size
+	}
+
+	return n, true
+}
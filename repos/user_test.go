@@ -0,0 +1,32 @@
+package repos
+
+import "testing"
+
+func TestHighlightTerms(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		search string
+		want   string
+	}{
+		{"no match", "jane", "bob", "jane"},
+		{"whole text", "jane", "jane", "<mark>jane</mark>"},
+		{"ignores the case", "JaneDoe", "doe", "Jane<mark>Doe</mark>"},
+		{"many terms", "jane the reader", "jane reader", "<mark>jane</mark> the <mark>reader</mark>"},
+		{"repeated term", "anna", "a", "<mark>a</mark>nn<mark>a</mark>"},
+		{"overlapping terms", "janedoe", "jane edo", "<mark>janedo</mark>e"},
+		{"unicode", "José Núñez", "núñez", "José <mark>Núñez</mark>"},
+		{"escapes the text", `<b>"jane"</b> & co`, "jane", `&lt;b&gt;&#34;<mark>jane</mark>&#34;&lt;/b&gt; &amp; co`},
+		{"escapes the match", "a<script>", "<script>", "a<mark>&lt;script&gt;</mark>"},
+		{"empty search", "jane", " ", "jane"},
+		{"empty text", "", "jane", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightTerms(tt.text, tt.search); got != tt.want {
+				t.Errorf("highlightTerms(%q, %q) = %q, want %q", tt.text, tt.search, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+)
+
+type VerificationRepo interface {
+	// Creates one verification and returns the created verification
+	CreateOne(ctx context.Context, v models.UserVerification) (models.UserVerification, error)
+
+	// Returns the not expired verification with the given token hash, if find
+	// nothing, returns a [NotFoundError]
+	GetByTokenHash(ctx context.Context, tokenHash string) (models.UserVerification, error)
+
+	// Deletes all the verifications of the given user
+	DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type psqlVerificationRepo struct {
+	db DB
+}
+
+func PSQLVerificationRepo(db DB) VerificationRepo {
+	return psqlVerificationRepo{db}
+}
+
+func (pvr psqlVerificationRepo) CreateOne(ctx context.Context, v models.UserVerification) (models.UserVerification, error) {
+	err := pvr.
+		db.
+		QueryRowEx(ctx, verificationCreateOne, nil, v.UserID, v.TokenHash, v.ExpiresAt).
+		Scan(&v.ID, &v.CreatedAt)
+
+	if err != nil {
+		return models.UserVerification{}, err
+	}
+
+	return v, nil
+}
+
+func (pvr psqlVerificationRepo) GetByTokenHash(ctx context.Context, tokenHash string) (v models.UserVerification, err error) {
+	err = pvr.
+		db.
+		QueryRowEx(ctx, verificationGetByTokenHash, nil, tokenHash).
+		Scan(&v.ID, &v.UserID, &v.TokenHash, &v.CreatedAt, &v.ExpiresAt)
+
+	if AsNotFoundError(&err) {
+		return
+	}
+
+	return
+}
+
+func (pvr psqlVerificationRepo) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	tag, err := pvr.db.ExecEx(ctx, verificationDeleteByUser, nil, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
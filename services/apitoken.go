@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+// APITokenPrefix starts all the api tokens, so they can be told apart from the
+// session tokens and found by secret scanners
+const APITokenPrefix = "bat_"
+
+// IsAPIToken reports whether the bearer token is an api token
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+type APITokenService interface {
+	// Creates an api token for the given user and returns it, the token is
+	// only available here because just its hash is stored
+	CreateToken(ctx context.Context, userID uuid.UUID, payload payloads.APITokenCreate) (payloads.APITokenCreated, error)
+
+	// Returns the api tokens of the user, including the expired ones
+	ListTokens(ctx context.Context, userID uuid.UUID) ([]payloads.APITokenList, error)
+
+	// Revokes one api token of the user
+	RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error
+
+	// Returns the identity of the not expired api token, with its scopes, and
+	// tracks its use, else returns an [repos.InvalidCredentialsError]
+	Authenticate(ctx context.Context, token string) (payloads.Identity, error)
+}
+
+type apiTokenService struct {
+	tokens repos.APITokenRepo
+	users  repos.UserRepo
+}
+
+func NewAPITokenService(tokens repos.APITokenRepo, users repos.UserRepo) APITokenService {
+	return apiTokenService{tokens, users}
+}
+
+func (ats apiTokenService) CreateToken(ctx context.Context, userID uuid.UUID, payload payloads.APITokenCreate) (payloads.APITokenCreated, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.APITokenCreated{}, err
+	}
+
+	token, err := valobjs.NewToken()
+
+	if err != nil {
+		return payloads.APITokenCreated{}, err
+	}
+
+	plain := APITokenPrefix + token.Plain()
+
+	apiToken := payload.ToModel(userID, valobjs.TokenFromString(plain).Hash())
+
+	apiToken, err = ats.tokens.CreateOne(ctx, apiToken)
+
+	if err != nil {
+		return payloads.APITokenCreated{}, err
+	}
+
+	created := payloads.APITokenCreated{
+		APITokenList: payloads.APITokenListFromModel(apiToken),
+		Token:        plain,
+	}
+
+	return created, nil
+}
+
+func (ats apiTokenService) ListTokens(ctx context.Context, userID uuid.UUID) ([]payloads.APITokenList, error) {
+	tokens, err := ats.tokens.FilterByUser(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return payloads.APITokenListFromModels(tokens), nil
+}
+
+func (ats apiTokenService) RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	_, err := ats.tokens.DeleteByID(ctx, tokenID, userID)
+
+	return err
+}
+
+func (ats apiTokenService) Authenticate(ctx context.Context, token string) (payloads.Identity, error) {
+	if !IsAPIToken(token) {
+		return payloads.Identity{}, repos.InvalidCredentialsError{}
+	}
+
+	apiToken, err := ats.tokens.UseByTokenHash(ctx, valobjs.TokenFromString(token).Hash(), models.UserStatusActive)
+
+	if repos.IsNotFoundError(err) {
+		return payloads.Identity{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return payloads.Identity{}, err
+	}
+
+	role, err := activeUserRole(ctx, ats.users, apiToken.UserID)
+
+	if err != nil {
+		return payloads.Identity{}, err
+	}
+
+	identity := payloads.Identity{
+		UserID:  apiToken.UserID,
+		Role:    role,
+		TokenID: apiToken.ID,
+		Scopes:  apiToken.Scopes,
+	}
+
+	return identity, nil
+}
@@ -1 +1,440 @@
 package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/policy"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+// BookService manages the book lifecycle: a book is created as a draft, then
+// its author can publish it, make it private or delete it. See
+// [models.BookStatus.CanTransitionTo] for the allowed status changes and the
+// [policy] package for who can do every action.
+type BookService interface {
+	// Returns one page of the books with the given filters, see
+	// [repos.BookRepo.FilterPage]
+	ListBooks(ctx context.Context, bf *repos.BookFilters, pf repos.PageFilters) (payloads.Page[payloads.BookList], error)
+
+	// Returns the books of the given author with any of the given statuses, or
+	// the not deleted ones if there are none. The deleted books are only
+	// returned during the grace period, while they can be restored
+	ListAuthorBooks(ctx context.Context, authorID uuid.UUID, statuses []models.BookStatus) ([]payloads.BookList, error)
+
+	// Returns one book with the given id if the viewer can read it, see
+	// [policy.ReadBook]. The viewer can be anonymous
+	GetBook(ctx context.Context, viewer policy.Actor, id uuid.UUID) (payloads.BookDetail, error)
+
+	// Creates a draft book owned by the actor, see [policy.CreateBook]
+	CreateDraft(ctx context.Context, actor policy.Actor, payload payloads.BookCreate) (payloads.BookDetail, error)
+
+	// Updates the title and description of a book, see [policy.UpdateBook]
+	UpdateBook(ctx context.Context, actor policy.Actor, id uuid.UUID, payload payloads.BookUpdate) (payloads.BookDetail, error)
+
+	// Makes a draft or private book public, see [policy.UpdateBook]
+	PublishBook(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error)
+
+	// Makes a draft or public book private, see [policy.UpdateBook]
+	MakeBookPrivate(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error)
+
+	// Marks a book as deleted, it can be restored during the grace period,
+	// see [policy.DeleteBook]
+	DeleteBook(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error)
+
+	// Restores a book deleted during the grace period as private, so its
+	// author decides when it's public again, see [policy.DeleteBook]
+	RestoreBook(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error)
+
+	// Stores the book file (pdf or epub) and replaces the previous one, see
+	// [policy.UpdateBook]
+	UploadBookFile(ctx context.Context, actor policy.Actor, id uuid.UUID, upload payloads.FileUpload) (payloads.BookDetail, error)
+
+	// Stores the book cover (jpeg, png or webp) and replaces the previous one,
+	// see [policy.UpdateBook]
+	UploadCoverFile(ctx context.Context, actor policy.Actor, id uuid.UUID, upload payloads.FileUpload) (payloads.BookDetail, error)
+
+	// Opens the book file, it follows the same visibility rules of GetBook
+	OpenBookFile(ctx context.Context, viewer policy.Actor, id uuid.UUID) (payloads.FileDownload, error)
+
+	// Opens the book cover, it follows the same visibility rules of GetBook
+	OpenCoverFile(ctx context.Context, viewer policy.Actor, id uuid.UUID) (payloads.FileDownload, error)
+
+	// Deletes forever the books whose grace period ended and their files,
+	// returns the number of purged books
+	PurgeDeleted(ctx context.Context) (int64, error)
+}
+
+// the statuses that an author sees in its books
+var notDeletedBookStatuses = []models.BookStatus{
+	models.BookStatusDraft,
+	models.BookStatusPublic,
+	models.BookStatusPrivate,
+	models.BookStatusHidden,
+}
+
+// BookOptions are the settings of the book service
+type BookOptions struct {
+	// time that the deleted books can be restored before being purged
+	DeleteGracePeriod time.Duration
+}
+
+type bookService struct {
+	books repos.BookRepo
+	opts  BookOptions
+}
+
+func NewBookService(books repos.BookRepo, opts BookOptions) BookService {
+	return bookService{books, opts}
+}
+
+func (bs bookService) ListBooks(ctx context.Context, bf *repos.BookFilters, pf repos.PageFilters) (payloads.Page[payloads.BookList], error) {
+	page, err := bs.books.FilterPage(ctx, bf, pf)
+
+	if err != nil {
+		return payloads.Page[payloads.BookList]{}, err
+	}
+
+	booksPayload := payloads.PageFromRepo(page, payloads.BookListFromModels)
+
+	return booksPayload, nil
+}
+
+func (bs bookService) ListAuthorBooks(ctx context.Context, authorID uuid.UUID, statuses []models.BookStatus) ([]payloads.BookList, error) {
+	if len(statuses) == 0 {
+		statuses = notDeletedBookStatuses
+	}
+
+	books, err := bs.books.FilterMany(ctx, &repos.BookFilters{
+		AuthorID:     authorID,
+		Statuses:     statuses,
+		DeletedAfter: time.Now().Add(-bs.opts.DeleteGracePeriod),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	booksPayload := payloads.BookListFromModels(books)
+
+	return booksPayload, nil
+}
+
+func (bs bookService) GetBook(ctx context.Context, viewer policy.Actor, id uuid.UUID) (payloads.BookDetail, error) {
+	book, err := bs.books.GetByID(ctx, id)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	err = policy.ReadBook(viewer, book)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	bookPayload := payloads.BookDetailFromModel(book)
+
+	return bookPayload, nil
+}
+
+func (bs bookService) CreateDraft(ctx context.Context, actor policy.Actor, payload payloads.BookCreate) (payloads.BookDetail, error) {
+	err := policy.CreateBook(actor)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	err = payload.Validate()
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	book, err := bs.books.CreateOne(ctx, payload.ToModel(actor.UserID))
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	bookPayload := payloads.BookDetailFromModel(book)
+
+	return bookPayload, nil
+}
+
+func (bs bookService) UpdateBook(ctx context.Context, actor policy.Actor, id uuid.UUID, payload payloads.BookUpdate) (payloads.BookDetail, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	_, err = bs.getBook(ctx, id, actor, policy.UpdateBook)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	book, err := bs.books.UpdateByID(ctx, id, payload.ToModel())
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	bookPayload := payloads.BookDetailFromModel(book)
+
+	return bookPayload, nil
+}
+
+func (bs bookService) PublishBook(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error) {
+	return bs.changeStatus(ctx, actor, id, models.BookStatusPublic, policy.UpdateBook)
+}
+
+func (bs bookService) MakeBookPrivate(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error) {
+	return bs.changeStatus(ctx, actor, id, models.BookStatusPrivate, policy.UpdateBook)
+}
+
+func (bs bookService) DeleteBook(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error) {
+	_, err := bs.getBook(ctx, id, actor, policy.DeleteBook)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	book, err := bs.books.DeleteByID(ctx, id)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	bookPayload := payloads.BookDetailFromModel(book)
+
+	return bookPayload, nil
+}
+
+func (bs bookService) RestoreBook(ctx context.Context, actor policy.Actor, id uuid.UUID) (payloads.BookDetail, error) {
+	book, err := bs.books.GetByID(ctx, id)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	if book.Status != models.BookStatusDeleted {
+		return payloads.BookDetail{}, repos.NotFoundError{}
+	}
+
+	err = policy.DeleteBook(actor, book)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	deletedAfter := time.Now().Add(-bs.opts.DeleteGracePeriod)
+
+	book, err = bs.books.RestoreByID(ctx, id, models.BookStatusPrivate, deletedAfter)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	bookPayload := payloads.BookDetailFromModel(book)
+
+	return bookPayload, nil
+}
+
+func (bs bookService) changeStatus(ctx context.Context, actor policy.Actor, id uuid.UUID, status models.BookStatus, allow bookPolicy) (payloads.BookDetail, error) {
+	book, err := bs.getBook(ctx, id, actor, allow)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	if !book.Status.CanTransitionTo(status) {
+		return payloads.BookDetail{}, repos.InvalidStatusTransitionError{}
+	}
+
+	book, err = bs.books.UpdateByID(ctx, id, models.Book{Status: status})
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	bookPayload := payloads.BookDetailFromModel(book)
+
+	return bookPayload, nil
+}
+
+func (bs bookService) UploadBookFile(ctx context.Context, actor policy.Actor, id uuid.UUID, upload payloads.FileUpload) (payloads.BookDetail, error) {
+	return bs.uploadFile(ctx, actor, id, upload, false)
+}
+
+func (bs bookService) UploadCoverFile(ctx context.Context, actor policy.Actor, id uuid.UUID, upload payloads.FileUpload) (payloads.BookDetail, error) {
+	return bs.uploadFile(ctx, actor, id, upload, true)
+}
+
+// saves the uploaded file and stores its path in the book, if the book can't be
+// updated the saved file is removed. The replaced file is removed after the
+// update
+func (bs bookService) uploadFile(ctx context.Context, actor policy.Actor, id uuid.UUID, upload payloads.FileUpload, isCover bool) (payloads.BookDetail, error) {
+	book, err := bs.getBook(ctx, id, actor, policy.UpdateBook)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	kind, extensions, oldPath := "book", bookExtensions, book.BookPath
+
+	if isCover {
+		kind, extensions, oldPath = "cover", coverExtensions, book.CoverPath
+	}
+
+	content := bufio.NewReaderSize(upload.Content, sniffLen)
+
+	// the error is ignored because a short file is still sniffed, and the
+	// read errors are returned when the file is saved
+	head, _ := content.Peek(sniffLen)
+
+	ext, ok := extensions[detectContentType(head)]
+
+	if !ok {
+		return payloads.BookDetail{}, repos.UnsupportedFileError{}
+	}
+
+	filename := bookFilename(id, kind, ext)
+
+	file, err := valobjs.SaveFile(content, filename)
+
+	if err != nil {
+		return payloads.BookDetail{}, err
+	}
+
+	update := models.Book{BookPath: filename, BookFile: file}
+
+	if isCover {
+		update = models.Book{CoverPath: filename, CoverFile: file}
+	}
+
+	book, err = bs.books.UpdateByID(ctx, id, update)
+
+	if err != nil {
+		// the file is not referenced by any book, so it must be removed
+		return payloads.BookDetail{}, errors.Join(err, file.Remove())
+	}
+
+	if oldPath != "" {
+		// the book already references the new file, if the old one can't be
+		// removed it only wastes space
+		_ = valobjs.FileFromName(oldPath).Remove()
+	}
+
+	bookPayload := payloads.BookDetailFromModel(book)
+
+	return bookPayload, nil
+}
+
+func (bs bookService) OpenBookFile(ctx context.Context, viewer policy.Actor, id uuid.UUID) (payloads.FileDownload, error) {
+	return bs.openFile(ctx, viewer, id, false)
+}
+
+func (bs bookService) OpenCoverFile(ctx context.Context, viewer policy.Actor, id uuid.UUID) (payloads.FileDownload, error) {
+	return bs.openFile(ctx, viewer, id, true)
+}
+
+func (bs bookService) openFile(ctx context.Context, viewer policy.Actor, id uuid.UUID, isCover bool) (payloads.FileDownload, error) {
+	book, err := bs.books.GetByID(ctx, id)
+
+	if err != nil {
+		return payloads.FileDownload{}, err
+	}
+
+	filename := book.BookPath
+
+	if isCover {
+		filename = book.CoverPath
+	}
+
+	err = policy.ReadBook(viewer, book)
+
+	if err != nil {
+		return payloads.FileDownload{}, err
+	}
+
+	if filename == "" {
+		return payloads.FileDownload{}, repos.NotFoundError{}
+	}
+
+	content, err := valobjs.FileFromName(filename).Open()
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return payloads.FileDownload{}, repos.DoesNotExistError{}
+	}
+
+	if err != nil {
+		return payloads.FileDownload{}, err
+	}
+
+	download := payloads.FileDownload{
+		Filename: path.Base(filename),
+		ModTime:  book.UpdatedAt,
+		Content:  content,
+	}
+
+	return download, nil
+}
+
+func (bs bookService) PurgeDeleted(ctx context.Context) (int64, error) {
+	books, err := bs.books.PurgeDeletedBefore(ctx, time.Now().Add(-bs.opts.DeleteGracePeriod))
+
+	if err != nil {
+		return 0, err
+	}
+
+	removeBookFiles(books)
+
+	return int64(len(books)), nil
+}
+
+// removes the files of the purged books, the books don't reference them
+// anymore, so if they can't be removed they only waste space
+func removeBookFiles(books []models.Book) {
+	for _, book := range books {
+		for _, filename := range []string{book.BookPath, book.CoverPath} {
+			if filename != "" {
+				_ = valobjs.FileFromName(filename).Remove()
+			}
+		}
+	}
+}
+
+// bookPolicy decides if an actor can act on a book, see the [policy] package
+type bookPolicy func(a policy.Actor, book models.Book) error
+
+// returns the book with the given id if the policy allows the actor to act on
+// it. The deleted books are never found
+func (bs bookService) getBook(ctx context.Context, id uuid.UUID, actor policy.Actor, allow bookPolicy) (models.Book, error) {
+	book, err := bs.books.GetByID(ctx, id)
+
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	if book.Status == models.BookStatusDeleted {
+		return models.Book{}, repos.NotFoundError{}
+	}
+
+	err = allow(actor, book)
+
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	return book, nil
+}
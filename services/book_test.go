@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/repos"
+)
+
+// filterBooks records the filters of the book lists and returns the books
+type filterBooks struct {
+	repos.BookRepo
+
+	books   []models.Book
+	filters *repos.BookFilters
+}
+
+func (fb *filterBooks) FilterMany(ctx context.Context, bf *repos.BookFilters) ([]models.Book, error) {
+	fb.filters = bf
+
+	return fb.books, nil
+}
+
+func TestListAuthorBooks(t *testing.T) {
+	const grace = 24 * time.Hour
+
+	tests := []struct {
+		name         string
+		statuses     []models.BookStatus
+		wantStatuses []models.BookStatus
+	}{
+		{
+			name:         "not deleted by default",
+			wantStatuses: notDeletedBookStatuses,
+		},
+		{
+			name:         "deleted",
+			statuses:     []models.BookStatus{models.BookStatusDeleted},
+			wantStatuses: []models.BookStatus{models.BookStatusDeleted},
+		},
+		{
+			name:         "private and deleted",
+			statuses:     []models.BookStatus{models.BookStatusPrivate, models.BookStatusDeleted},
+			wantStatuses: []models.BookStatus{models.BookStatusPrivate, models.BookStatusDeleted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorID := uuid.New()
+			deletedAt := time.Now().Add(-time.Hour)
+
+			books := &filterBooks{books: []models.Book{
+				{ID: uuid.New(), AuthorID: authorID, Status: models.BookStatusDeleted, DeletedAt: &deletedAt},
+			}}
+
+			bs := NewBookService(books, BookOptions{DeleteGracePeriod: grace})
+
+			before := time.Now()
+
+			list, err := bs.ListAuthorBooks(context.Background(), authorID, tt.statuses)
+
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+
+			bf := books.filters
+
+			if bf.AuthorID != authorID {
+				t.Errorf("author = %v, want %v", bf.AuthorID, authorID)
+			}
+
+			if !reflect.DeepEqual(bf.Statuses, tt.wantStatuses) {
+				t.Errorf("statuses = %v, want %v", bf.Statuses, tt.wantStatuses)
+			}
+
+			// only the books that can still be restored
+			if bf.DeletedAfter.Before(before.Add(-grace)) || bf.DeletedAfter.After(time.Now().Add(-grace)) {
+				t.Errorf("deleted after = %v, want %v ago", bf.DeletedAfter, grace)
+			}
+
+			if len(list) != 1 || list[0].DeletedAt == nil || !list[0].DeletedAt.Equal(deletedAt) {
+				t.Errorf("list = %+v, want the deleted book with its deleted_at", list)
+			}
+		})
+	}
+}
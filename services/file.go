@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+
+	"github.com/google/uuid"
+)
+
+// number of bytes used to detect the content type of a file
+const sniffLen = 512
+
+// the epub files are zip files with this entry at the beginning
+var epubSignature = []byte("mimetypeapplication/epub+zip")
+
+var (
+	bookExtensions = map[string]string{
+		"application/pdf":      ".pdf",
+		"application/epub+zip": ".epub",
+	}
+
+	coverExtensions = map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/webp": ".webp",
+	}
+)
+
+// detects the content type of the file by its content, the content type sent
+// by the client can't be trusted
+func detectContentType(b []byte) string {
+	contentType := http.DetectContentType(b)
+
+	if contentType == "application/zip" && len(b) >= 30+len(epubSignature) && bytes.Equal(b[30:30+len(epubSignature)], epubSignature) {
+		return "application/epub+zip"
+	}
+
+	return contentType
+}
+
+// returns a new filename for a book file, every upload gets a different name
+// so an upload never overwrites a file that is still referenced
+func bookFilename(bookID uuid.UUID, kind, ext string) string {
+	return path.Join("books", bookID.String(), kind+"-"+uuid.NewString()+ext)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/policy"
+	"github.com/marlonmp/books-app/repos"
+)
+
+// LockoutService tracks the failed sign ins of the users and ips, after too
+// many failures the subject is locked, and every extra failure doubles the
+// lock
+type LockoutService interface {
+	// Returns a [repos.TooManyAttemptsError] if the subject is locked
+	CheckLocked(ctx context.Context, scope models.AttemptScope, subject string) error
+
+	// Counts one failure of the subject, locking it if it has too many
+	RegisterFailure(ctx context.Context, scope models.AttemptScope, subject string) error
+
+	// Forgets the failures of the subject
+	RegisterSuccess(ctx context.Context, scope models.AttemptScope, subject string) error
+
+	// Returns the lockout events, the newest first, see [policy.ListLockouts]
+	ListLockouts(ctx context.Context, actor policy.Actor, limit, offset int) ([]payloads.LockoutList, error)
+
+	// Deletes the attempts whose failures are out of the failure window and
+	// that are not locked, returns the number of deleted attempts
+	PurgeStale(ctx context.Context) (int64, error)
+}
+
+// LockoutOptions are the settings of the lockout service
+type LockoutOptions struct {
+	// failures allowed before locking the subject
+	MaxFailures int
+
+	// duration of the first lock and the max duration of a lock
+	BaseLockout,
+	MaxLockout time.Duration
+
+	// time after the failures are forgotten
+	FailureWindow time.Duration
+}
+
+type lockoutService struct {
+	attempts repos.SignInAttemptRepo
+	lockouts repos.LockoutRepo
+	opts     LockoutOptions
+}
+
+func NewLockoutService(attempts repos.SignInAttemptRepo, lockouts repos.LockoutRepo, opts LockoutOptions) LockoutService {
+	return lockoutService{attempts, lockouts, opts}
+}
+
+func (ls lockoutService) CheckLocked(ctx context.Context, scope models.AttemptScope, subject string) error {
+	attempt, err := ls.attempts.Get(ctx, scope, subject)
+
+	if repos.IsNotFoundError(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if attempt.IsLocked() {
+		return repos.TooManyAttemptsError{RetryAfter: time.Until(attempt.LockedUntil)}
+	}
+
+	return nil
+}
+
+func (ls lockoutService) RegisterFailure(ctx context.Context, scope models.AttemptScope, subject string) error {
+	attempt, err := ls.attempts.RegisterFailure(ctx, scope, subject, ls.opts.FailureWindow)
+
+	if err != nil {
+		return err
+	}
+
+	if attempt.Failures < ls.opts.MaxFailures {
+		return nil
+	}
+
+	lockedUntil := time.Now().Add(ls.lockoutDuration(attempt.Failures))
+
+	err = ls.attempts.Lock(ctx, scope, subject, lockedUntil)
+
+	if err != nil {
+		return err
+	}
+
+	event := models.LockoutEvent{
+		Scope:       scope,
+		Subject:     subject,
+		Failures:    attempt.Failures,
+		LockedUntil: lockedUntil,
+	}
+
+	_, err = ls.lockouts.CreateOne(ctx, event)
+
+	return err
+}
+
+func (ls lockoutService) RegisterSuccess(ctx context.Context, scope models.AttemptScope, subject string) error {
+	return ls.attempts.Reset(ctx, scope, subject)
+}
+
+func (ls lockoutService) ListLockouts(ctx context.Context, actor policy.Actor, limit, offset int) ([]payloads.LockoutList, error) {
+	err := policy.ListLockouts(actor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := ls.lockouts.FilterMany(ctx, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	eventsPayload := payloads.LockoutListFromModels(events)
+
+	return eventsPayload, nil
+}
+
+func (ls lockoutService) PurgeStale(ctx context.Context) (int64, error) {
+	return ls.attempts.DeleteStale(ctx, ls.opts.FailureWindow)
+}
+
+// the first lock lasts the base lockout, and every extra failure doubles it
+// until the max lockout
+func (ls lockoutService) lockoutDuration(failures int) time.Duration {
+	d := ls.opts.BaseLockout
+
+	for i := ls.opts.MaxFailures; i < failures && d < ls.opts.MaxLockout; i++ {
+		d *= 2
+	}
+
+	return min(d, ls.opts.MaxLockout)
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/mails"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+type attemptKey struct {
+	scope   models.AttemptScope
+	subject string
+}
+
+// fakeAttempts keeps the attempts in memory as the sign_in_attempts table
+type fakeAttempts struct {
+	repos.SignInAttemptRepo
+
+	attempts map[attemptKey]models.SignInAttempt
+}
+
+func (fa *fakeAttempts) Get(ctx context.Context, scope models.AttemptScope, subject string) (models.SignInAttempt, error) {
+	a, ok := fa.attempts[attemptKey{scope, subject}]
+
+	if !ok {
+		return models.SignInAttempt{}, repos.NotFoundError{}
+	}
+
+	return a, nil
+}
+
+func (fa *fakeAttempts) RegisterFailure(ctx context.Context, scope models.AttemptScope, subject string, window time.Duration) (models.SignInAttempt, error) {
+	key := attemptKey{scope, subject}
+	a, ok := fa.attempts[key]
+
+	if !ok || a.UpdatedAt.Before(time.Now().Add(-window)) {
+		a = models.SignInAttempt{Scope: scope, Subject: subject, LockedUntil: a.LockedUntil}
+	}
+
+	a.Failures++
+	a.UpdatedAt = time.Now()
+	fa.attempts[key] = a
+
+	return a, nil
+}
+
+func (fa *fakeAttempts) Lock(ctx context.Context, scope models.AttemptScope, subject string, until time.Time) error {
+	key := attemptKey{scope, subject}
+	a := fa.attempts[key]
+	a.LockedUntil = until
+	fa.attempts[key] = a
+
+	return nil
+}
+
+func (fa *fakeAttempts) Reset(ctx context.Context, scope models.AttemptScope, subject string) error {
+	delete(fa.attempts, attemptKey{scope, subject})
+
+	return nil
+}
+
+type fakeLockouts struct {
+	repos.LockoutRepo
+
+	events []models.LockoutEvent
+}
+
+func (fl *fakeLockouts) CreateOne(ctx context.Context, e models.LockoutEvent) (models.LockoutEvent, error) {
+	fl.events = append(fl.events, e)
+
+	return e, nil
+}
+
+func newLockoutTest(opts LockoutOptions) (lockoutService, *fakeAttempts, *fakeLockouts) {
+	attempts := &fakeAttempts{attempts: map[attemptKey]models.SignInAttempt{}}
+	lockouts := &fakeLockouts{}
+
+	return lockoutService{attempts, lockouts, opts}, attempts, lockouts
+}
+
+var testLockoutOptions = LockoutOptions{
+	MaxFailures:   3,
+	BaseLockout:   time.Minute,
+	MaxLockout:    10 * time.Minute,
+	FailureWindow: time.Hour,
+}
+
+func TestLockoutDuration(t *testing.T) {
+	ls, _, _ := newLockoutTest(testLockoutOptions)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := ls.lockoutDuration(tt.failures); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockoutRegisterFailure(t *testing.T) {
+	ctx := context.Background()
+	ls, attempts, lockouts := newLockoutTest(testLockoutOptions)
+
+	for i := 1; i <= 5; i++ {
+		err := ls.RegisterFailure(ctx, models.AttemptScopeUser, "jane")
+
+		if err != nil {
+			t.Fatalf("failure %d: %v", i, err)
+		}
+
+		err = ls.CheckLocked(ctx, models.AttemptScopeUser, "jane")
+
+		if locked := err != nil; locked != (i >= testLockoutOptions.MaxFailures) {
+			t.Errorf("after %d failures, check locked = %v", i, err)
+		}
+	}
+
+	// every failure after the max doubles the lock
+	if len(lockouts.events) != 3 {
+		t.Fatalf("%d lockout events, want 3", len(lockouts.events))
+	}
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		e := lockouts.events[i]
+
+		if got := time.Until(e.LockedUntil); got > want || got < want-time.Second {
+			t.Errorf("lock %d lasts %v, want %v", i, got, want)
+		}
+
+		if e.Failures != testLockoutOptions.MaxFailures+i {
+			t.Errorf("lock %d has %d failures", i, e.Failures)
+		}
+	}
+
+	var tmae repos.TooManyAttemptsError
+
+	if err := ls.CheckLocked(ctx, models.AttemptScopeUser, "jane"); !errors.As(err, &tmae) || tmae.RetryAfter <= 3*time.Minute {
+		t.Errorf("check locked = %v, want a retry after about 4m", err)
+	}
+
+	// the same subject in other scope and the other subjects are not locked
+	if err := ls.CheckLocked(ctx, models.AttemptScopeIP, "jane"); err != nil {
+		t.Errorf("check locked ip = %v", err)
+	}
+
+	if err := ls.CheckLocked(ctx, models.AttemptScopeUser, "john"); err != nil {
+		t.Errorf("check locked other user = %v", err)
+	}
+
+	err := ls.RegisterSuccess(ctx, models.AttemptScopeUser, "jane")
+
+	if err != nil {
+		t.Fatalf("register success: %v", err)
+	}
+
+	if err := ls.CheckLocked(ctx, models.AttemptScopeUser, "jane"); err != nil {
+		t.Errorf("check locked after a success = %v", err)
+	}
+
+	if len(attempts.attempts) != 0 {
+		t.Errorf("attempts after a success = %v", attempts.attempts)
+	}
+}
+
+func TestLockoutFailureWindow(t *testing.T) {
+	ctx := context.Background()
+	ls, attempts, _ := newLockoutTest(testLockoutOptions)
+
+	for i := 0; i < testLockoutOptions.MaxFailures-1; i++ {
+		ls.RegisterFailure(ctx, models.AttemptScopeIP, "10.0.0.1")
+	}
+
+	// the failures become older than the window
+	key := attemptKey{models.AttemptScopeIP, "10.0.0.1"}
+	a := attempts.attempts[key]
+	a.UpdatedAt = time.Now().Add(-2 * testLockoutOptions.FailureWindow)
+	attempts.attempts[key] = a
+
+	ls.RegisterFailure(ctx, models.AttemptScopeIP, "10.0.0.1")
+
+	if err := ls.CheckLocked(ctx, models.AttemptScopeIP, "10.0.0.1"); err != nil {
+		t.Errorf("check locked = %v, the old failures must be forgotten", err)
+	}
+
+	if got := attempts.attempts[key].Failures; got != 1 {
+		t.Errorf("failures = %d, want 1", got)
+	}
+}
+
+type fakeSessionService struct {
+	SessionService
+}
+
+func (fss fakeSessionService) CreateSession(ctx context.Context, userID uuid.UUID, client payloads.ClientInfo) (payloads.SessionToken, error) {
+	return payloads.SessionToken{}, nil
+}
+
+// newSignInTest returns an user service with the given users and a lockout
+// service that keeps the attempts in memory
+func newSignInTest(t *testing.T, users ...models.User) (UserService, *fakeUsers) {
+	t.Helper()
+
+	ls, _, _ := newLockoutTest(testLockoutOptions)
+	fu := &fakeUsers{}
+
+	for _, u := range users {
+		fu.CreateOne(context.Background(), u)
+	}
+
+	return NewUserService(fu, nil, fakeSessionService{}, nil, nil, nil, ls, mails.Memory(), UserOptions{}), fu
+}
+
+func newTestUser(t *testing.T, email, pwd string) models.User {
+	t.Helper()
+
+	password, err := valobjs.HashPassword(pwd)
+
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+
+	return models.User{Username: "jane", Email: email, Password: password, Status: models.UserStatusActive}
+}
+
+func TestSignInLocksUserAndIP(t *testing.T) {
+	ctx := context.Background()
+
+	svc, _ := newSignInTest(t,
+		newTestUser(t, "jane@example.com", "correct horse"),
+		newTestUser(t, "john@example.com", "battery staple"),
+	)
+
+	signIn := func(email, pwd, ip string) error {
+		_, err := svc.SignIn(ctx, payloads.UserCredentials{Login: email, Password: pwd}, payloads.ClientInfo{IP: ip})
+
+		return err
+	}
+
+	// the failures come from many ips, so only the user gets locked
+	for i := 0; i < testLockoutOptions.MaxFailures; i++ {
+		err := signIn("jane@example.com", "wrong", fmt.Sprintf("10.0.0.%d", i+1))
+
+		if !errors.Is(err, repos.InvalidCredentialsError{}) {
+			t.Fatalf("failure %d = %v", i, err)
+		}
+	}
+
+	var tmae repos.TooManyAttemptsError
+
+	if err := signIn("jane@example.com", "correct horse", "10.0.1.1"); !errors.As(err, &tmae) {
+		t.Errorf("locked user sign in = %v, want a TooManyAttemptsError", err)
+	}
+
+	if err := signIn("john@example.com", "battery staple", "10.0.0.1"); err != nil {
+		t.Errorf("other user sign in = %v", err)
+	}
+
+	// the failures of unknown users from one ip lock the ip for every user
+	for i := 0; i < testLockoutOptions.MaxFailures; i++ {
+		signIn(fmt.Sprintf("nobody%d@example.com", i), "wrong", "10.0.2.1")
+	}
+
+	if err := signIn("john@example.com", "battery staple", "10.0.2.1"); !errors.As(err, &tmae) {
+		t.Errorf("locked ip sign in = %v, want a TooManyAttemptsError", err)
+	}
+
+	if err := signIn("john@example.com", "battery staple", "10.0.2.2"); err != nil {
+		t.Errorf("other ip sign in = %v", err)
+	}
+}
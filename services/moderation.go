@@ -0,0 +1,418 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/policy"
+	"github.com/marlonmp/books-app/repos"
+)
+
+// reason of the unban events created when a ban ends
+const banExpiredReason = "the ban expired"
+
+// ModerationService lets the moderators ban and unban the users and the admins
+// delete them. Every action needs a reason and is recorded in the moderation
+// history of the user, in the same transaction that does it
+type ModerationService interface {
+	// Bans an active user until the given time or forever, its sessions are
+	// revoked and its public books are hidden until the ban is lifted, see
+	// [policy.BanUser]
+	BanUser(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.UserBan) (payloads.UserList, error)
+
+	// Lifts the ban of a banned user and makes its hidden books public again,
+	// see [policy.BanUser]
+	UnbanUser(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.ModerationReason) (payloads.UserList, error)
+
+	// Marks an active or banned user as deleted, its sessions are revoked and
+	// its public books are hidden. The user can be restored during the grace
+	// period, see [policy.DeleteUser]
+	DeleteUser(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.ModerationReason) error
+
+	// Restores a user deleted during the grace period with the status it had,
+	// a banned user keeps its ban and its hidden books, the rest are activated
+	// and their hidden books made public again, see [policy.DeleteUser]
+	RestoreUser(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.ModerationReason) (payloads.UserList, error)
+
+	// Returns the moderation history of the user, the newest first, see
+	// [policy.ListModerationEvents]
+	ListModerationEvents(ctx context.Context, actor policy.Actor, userID uuid.UUID) ([]payloads.ModerationEventList, error)
+
+	// Lifts the bans that ended, returns the number of unbanned users
+	LiftExpiredBans(ctx context.Context) (int64, error)
+
+	// Deletes forever the users whose grace period ended, with their books
+	// and files, returns the number of purged users
+	PurgeDeleted(ctx context.Context) (int64, error)
+}
+
+// ModerationOptions are the settings of the moderation service
+type ModerationOptions struct {
+	// time that the deleted users can be restored before being purged
+	DeleteGracePeriod time.Duration
+}
+
+type moderationService struct {
+	users  repos.UserRepo
+	books  repos.BookRepo
+	events repos.ModerationRepo
+	tx     repos.Transactor
+	opts   ModerationOptions
+}
+
+func NewModerationService(users repos.UserRepo, books repos.BookRepo, events repos.ModerationRepo, tx repos.Transactor, opts ModerationOptions) ModerationService {
+	return moderationService{users, books, events, tx, opts}
+}
+
+func (ms moderationService) BanUser(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.UserBan) (payloads.UserList, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	user, err := ms.users.GetByID(ctx, userID, models.UserStatusActive)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	err = policy.BanUser(actor, user)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	err = ms.tx.InTx(ctx, func(tx repos.Tx) error {
+		err := tx.Users.BanByID(ctx, user.ID, payload.ExpiresAt)
+
+		if err != nil {
+			return err
+		}
+
+		err = shutOut(ctx, tx, user.ID)
+
+		if err != nil {
+			return err
+		}
+
+		event := models.NewModerationEvent(user.ID, actor.UserID, models.ModerationActionBan, payload.Reason, payload.ExpiresAt)
+
+		_, err = tx.Moderation.CreateOne(ctx, event)
+
+		return err
+	})
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	user.Status = models.UserStatusBanned
+	user.BannedUntil = payload.ExpiresAt
+
+	return payloads.UserListFromModel(user), nil
+}
+
+func (ms moderationService) UnbanUser(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.ModerationReason) (payloads.UserList, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	user, err := ms.users.GetByID(ctx, userID, models.UserStatusBanned)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	err = policy.BanUser(actor, user)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	err = ms.unban(ctx, actor.UserID, user.ID, payload.Reason)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	user.Status = models.UserStatusActive
+	user.BannedUntil = nil
+
+	return payloads.UserListFromModel(user), nil
+}
+
+func (ms moderationService) DeleteUser(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.ModerationReason) error {
+	err := payload.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	user, err := ms.getUser(ctx, userID, models.UserStatusActive, models.UserStatusBanned)
+
+	if err != nil {
+		return err
+	}
+
+	err = policy.DeleteUser(actor, user)
+
+	if err != nil {
+		return err
+	}
+
+	return ms.tx.InTx(ctx, func(tx repos.Tx) error {
+		_, err := tx.Users.DeleteByID(ctx, user.ID, user.Status)
+
+		if err != nil {
+			return err
+		}
+
+		err = shutOut(ctx, tx, user.ID)
+
+		if err != nil {
+			return err
+		}
+
+		event := models.NewModerationEvent(user.ID, actor.UserID, models.ModerationActionDelete, payload.Reason, nil)
+
+		_, err = tx.Moderation.CreateOne(ctx, event)
+
+		return err
+	})
+}
+
+func (ms moderationService) RestoreUser(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.ModerationReason) (payloads.UserList, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	user, err := ms.users.GetByID(ctx, userID, models.UserStatusDeleted)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	err = policy.DeleteUser(actor, user)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	status := models.UserStatusActive
+	var bannedUntil *time.Time
+
+	err = ms.tx.InTx(ctx, func(tx repos.Tx) error {
+		// the delete clears the ban of the user, so it's taken from the history
+		events, err := tx.Moderation.FilterByUser(ctx, user.ID)
+
+		if err != nil {
+			return err
+		}
+
+		status, bannedUntil = statusBeforeDelete(events)
+
+		err = tx.Users.RestoreByID(ctx, user.ID, status, bannedUntil, time.Now().Add(-ms.opts.DeleteGracePeriod))
+
+		if err != nil {
+			return err
+		}
+
+		// the bans that ended while the user was deleted are lifted by
+		// LiftExpiredBans, which shows the books
+		if status == models.UserStatusActive {
+			_, err = tx.Books.UpdateStatusByAuthor(ctx, user.ID, models.BookStatusHidden, models.BookStatusPublic)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		event := models.NewModerationEvent(user.ID, actor.UserID, models.ModerationActionRestore, payload.Reason, nil)
+
+		_, err = tx.Moderation.CreateOne(ctx, event)
+
+		return err
+	})
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	user.Status = status
+	user.BannedUntil = bannedUntil
+	user.DeletedAt = nil
+
+	return payloads.UserListFromModel(user), nil
+}
+
+func (ms moderationService) ListModerationEvents(ctx context.Context, actor policy.Actor, userID uuid.UUID) ([]payloads.ModerationEventList, error) {
+	err := policy.ListModerationEvents(actor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := ms.events.FilterByUser(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	eventsPayload := payloads.ModerationEventListFromModels(events)
+
+	return eventsPayload, nil
+}
+
+func (ms moderationService) LiftExpiredBans(ctx context.Context) (int64, error) {
+	ids, err := ms.users.FilterBanExpiredBefore(ctx, time.Now())
+
+	if err != nil {
+		return 0, err
+	}
+
+	var lifted int64
+
+	for _, id := range ids {
+		err = ms.tx.InTx(ctx, func(tx repos.Tx) error {
+			err := tx.Users.LiftBanByID(ctx, id)
+
+			if err != nil {
+				return err
+			}
+
+			return reinstate(ctx, tx, uuid.Nil, id, banExpiredReason)
+		})
+
+		// the user was unbanned or banned again by a moderator in the meantime
+		if repos.IsNotFoundError(err) {
+			continue
+		}
+
+		if err != nil {
+			return lifted, err
+		}
+
+		lifted++
+	}
+
+	return lifted, nil
+}
+
+func (ms moderationService) PurgeDeleted(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-ms.opts.DeleteGracePeriod)
+
+	ids, err := ms.users.FilterDeletedBefore(ctx, before)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+
+	for _, id := range ids {
+		// the books are purged with the user, so their files are found before
+		books, err := ms.books.FilterMany(ctx, &repos.BookFilters{AuthorID: id})
+
+		if err != nil {
+			return purged, err
+		}
+
+		err = ms.users.PurgeByID(ctx, id, before)
+
+		// the user was restored in the meantime
+		if repos.IsNotFoundError(err) {
+			continue
+		}
+
+		if err != nil {
+			return purged, err
+		}
+
+		removeBookFiles(books)
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// activates the banned user and makes its hidden books public again
+func (ms moderationService) unban(ctx context.Context, moderatorID, userID uuid.UUID, reason string) error {
+	return ms.tx.InTx(ctx, func(tx repos.Tx) error {
+		err := tx.Users.UpdateStatusByID(ctx, userID, models.UserStatusBanned, models.UserStatusActive)
+
+		if err != nil {
+			return err
+		}
+
+		return reinstate(ctx, tx, moderatorID, userID, reason)
+	})
+}
+
+// shows again the books of the unbanned user and records the unban, the
+// moderator is nil when the ban expired
+func reinstate(ctx context.Context, tx repos.Tx, moderatorID, userID uuid.UUID, reason string) error {
+	_, err := tx.Books.UpdateStatusByAuthor(ctx, userID, models.BookStatusHidden, models.BookStatusPublic)
+
+	if err != nil {
+		return err
+	}
+
+	event := models.NewModerationEvent(userID, moderatorID, models.ModerationActionUnban, reason, nil)
+
+	_, err = tx.Moderation.CreateOne(ctx, event)
+
+	return err
+}
+
+// revokes the sessions of the user and hides its public books, its api tokens
+// stop working because they are only accepted for the active users
+func shutOut(ctx context.Context, tx repos.Tx, userID uuid.UUID) error {
+	_, err := tx.Sessions.DeleteByUser(ctx, userID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Books.UpdateStatusByAuthor(ctx, userID, models.BookStatusPublic, models.BookStatusHidden)
+
+	return err
+}
+
+// returns the user with the given id and any of the given statuses
+// returns the status and the end of the ban that the user had before being
+// deleted, from its moderation history with the newest events first
+func statusBeforeDelete(events []models.ModerationEvent) (models.UserStatus, *time.Time) {
+	for _, e := range events {
+		switch e.Action {
+		case models.ModerationActionBan:
+			return models.UserStatusBanned, e.ExpiresAt
+		case models.ModerationActionUnban:
+			return models.UserStatusActive, nil
+		}
+	}
+
+	return models.UserStatusActive, nil
+}
+
+func (ms moderationService) getUser(ctx context.Context, id uuid.UUID, statuses ...models.UserStatus) (models.User, error) {
+	for _, status := range statuses {
+		user, err := ms.users.GetByID(ctx, id, status)
+
+		if repos.IsNotFoundError(err) {
+			continue
+		}
+
+		return user, err
+	}
+
+	return models.User{}, repos.NotFoundError{}
+}
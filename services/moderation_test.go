@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/policy"
+	"github.com/marlonmp/books-app/repos"
+)
+
+var errWriteFailed = errors.New("write failed")
+
+// writeLog records the writes of the moderation repos, the write named as
+// fail returns an error
+type writeLog struct {
+	writes []string
+	fail   string
+}
+
+func (wl *writeLog) write(name string) error {
+	if name == wl.fail {
+		return errWriteFailed
+	}
+
+	wl.writes = append(wl.writes, name)
+
+	return nil
+}
+
+type moderationUsers struct {
+	repos.UserRepo
+
+	user models.User
+	log  *writeLog
+}
+
+func (mu moderationUsers) GetByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (models.User, error) {
+	if mu.user.ID != id || mu.user.Status != status {
+		return models.User{}, repos.NotFoundError{}
+	}
+
+	return mu.user, nil
+}
+
+func (mu moderationUsers) BanByID(ctx context.Context, id uuid.UUID, until *time.Time) error {
+	return mu.log.write("ban user")
+}
+
+func (mu moderationUsers) UpdateStatusByID(ctx context.Context, id uuid.UUID, status, next models.UserStatus) error {
+	return mu.log.write("activate user")
+}
+
+func (mu moderationUsers) DeleteByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (models.User, error) {
+	return mu.user, mu.log.write("delete user")
+}
+
+// the ids are found outside of the transaction, so the user could have been
+// banned again when its ban is lifted
+func (mu moderationUsers) FilterBanExpiredBefore(ctx context.Context, before time.Time) ([]uuid.UUID, error) {
+	return []uuid.UUID{mu.user.ID}, nil
+}
+
+func (mu moderationUsers) LiftBanByID(ctx context.Context, id uuid.UUID) error {
+	if mu.user.BannedUntil == nil || mu.user.BannedUntil.After(time.Now()) {
+		return repos.NotFoundError{}
+	}
+
+	return mu.log.write("lift ban")
+}
+
+func (mu moderationUsers) RestoreByID(ctx context.Context, id uuid.UUID, status models.UserStatus, bannedUntil *time.Time, deletedAfter time.Time) error {
+	if status == models.UserStatusBanned {
+		return mu.log.write("restore banned user")
+	}
+
+	return mu.log.write("restore user")
+}
+
+type moderationBooks struct {
+	repos.BookRepo
+
+	log *writeLog
+}
+
+func (mb moderationBooks) UpdateStatusByAuthor(ctx context.Context, authorID uuid.UUID, status, next models.BookStatus) (int64, error) {
+	if next == models.BookStatusHidden {
+		return 0, mb.log.write("hide books")
+	}
+
+	return 0, mb.log.write("show books")
+}
+
+type moderationSessions struct {
+	repos.SessionRepo
+
+	log *writeLog
+}
+
+func (ms moderationSessions) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return 0, ms.log.write("sign out")
+}
+
+type moderationEvents struct {
+	repos.ModerationRepo
+
+	events []models.ModerationEvent
+	log    *writeLog
+}
+
+func (me moderationEvents) FilterByUser(ctx context.Context, userID uuid.UUID) ([]models.ModerationEvent, error) {
+	return me.events, nil
+}
+
+func (me moderationEvents) CreateOne(ctx context.Context, e models.ModerationEvent) (models.ModerationEvent, error) {
+	return e, me.log.write("event " + e.Action.String())
+}
+
+// fakeTransactor keeps the writes of a transaction apart, and only adds them
+// to the committed ones if the transaction succeeds
+type fakeTransactor struct {
+	user      models.User
+	events    []models.ModerationEvent
+	fail      string
+	committed *[]string
+}
+
+func (ft fakeTransactor) InTx(ctx context.Context, fn func(tx repos.Tx) error) error {
+	log := &writeLog{fail: ft.fail}
+
+	err := fn(repos.Tx{
+		Users:      moderationUsers{user: ft.user, log: log},
+		Books:      moderationBooks{log: log},
+		Sessions:   moderationSessions{log: log},
+		Moderation: moderationEvents{events: ft.events, log: log},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	*ft.committed = append(*ft.committed, log.writes...)
+
+	return nil
+}
+
+func TestModerationActionsAreAtomic(t *testing.T) {
+	admin := policy.Actor{UserID: uuid.New(), Role: models.RoleAdmin}
+	reason := payloads.ModerationReason{Reason: "spam"}
+	expired := time.Now().Add(-time.Minute)
+	until := time.Now().Add(time.Hour)
+
+	// the histories of the deleted users, the newest events first
+	event := func(action models.ModerationAction, expiresAt *time.Time) models.ModerationEvent {
+		return models.NewModerationEvent(uuid.Nil, admin.UserID, action, reason.Reason, expiresAt)
+	}
+
+	bannedHistory := []models.ModerationEvent{
+		event(models.ModerationActionDelete, nil),
+		event(models.ModerationActionBan, &until),
+	}
+
+	unbannedHistory := []models.ModerationEvent{
+		event(models.ModerationActionDelete, nil),
+		event(models.ModerationActionUnban, nil),
+		event(models.ModerationActionBan, nil),
+	}
+
+	tests := []struct {
+		name        string
+		status      models.UserStatus
+		bannedUntil *time.Time
+		events      []models.ModerationEvent
+		action      func(ms ModerationService, userID uuid.UUID) error
+		writes      []string
+	}{
+		{
+			name:   "ban",
+			status: models.UserStatusActive,
+			action: func(ms ModerationService, userID uuid.UUID) error {
+				_, err := ms.BanUser(context.Background(), admin, userID, payloads.UserBan{Reason: "spam"})
+				return err
+			},
+			writes: []string{"ban user", "sign out", "hide books", "event " + models.ModerationActionBan.String()},
+		},
+		{
+			name:   "unban",
+			status: models.UserStatusBanned,
+			action: func(ms ModerationService, userID uuid.UUID) error {
+				_, err := ms.UnbanUser(context.Background(), admin, userID, reason)
+				return err
+			},
+			writes: []string{"activate user", "show books", "event " + models.ModerationActionUnban.String()},
+		},
+		{
+			name:        "lift expired ban",
+			status:      models.UserStatusBanned,
+			bannedUntil: &expired,
+			action: func(ms ModerationService, userID uuid.UUID) error {
+				_, err := ms.LiftExpiredBans(context.Background())
+				return err
+			},
+			writes: []string{"lift ban", "show books", "event " + models.ModerationActionUnban.String()},
+		},
+		{
+			name:   "delete",
+			status: models.UserStatusActive,
+			action: func(ms ModerationService, userID uuid.UUID) error {
+				return ms.DeleteUser(context.Background(), admin, userID, reason)
+			},
+			writes: []string{"delete user", "sign out", "hide books", "event " + models.ModerationActionDelete.String()},
+		},
+		{
+			name:   "restore",
+			status: models.UserStatusDeleted,
+			action: func(ms ModerationService, userID uuid.UUID) error {
+				_, err := ms.RestoreUser(context.Background(), admin, userID, reason)
+				return err
+			},
+			writes: []string{"restore user", "show books", "event " + models.ModerationActionRestore.String()},
+		},
+		{
+			name:   "restore unbanned",
+			status: models.UserStatusDeleted,
+			events: unbannedHistory,
+			action: func(ms ModerationService, userID uuid.UUID) error {
+				_, err := ms.RestoreUser(context.Background(), admin, userID, reason)
+				return err
+			},
+			writes: []string{"restore user", "show books", "event " + models.ModerationActionRestore.String()},
+		},
+		{
+			// the books are kept hidden until the ban is lifted
+			name:   "restore banned",
+			status: models.UserStatusDeleted,
+			events: bannedHistory,
+			action: func(ms ModerationService, userID uuid.UUID) error {
+				_, err := ms.RestoreUser(context.Background(), admin, userID, reason)
+				return err
+			},
+			writes: []string{"restore banned user", "event " + models.ModerationActionRestore.String()},
+		},
+	}
+
+	for _, tt := range tests {
+		user := models.User{ID: uuid.New(), Status: tt.status, Role: models.RoleAuthor, BannedUntil: tt.bannedUntil}
+
+		// the action with no failure, and then failing on each of its writes
+		fails := append([]string{""}, tt.writes...)
+
+		for _, fail := range fails {
+			name := tt.name
+
+			if fail != "" {
+				name += " failing on " + fail
+			}
+
+			t.Run(name, func(t *testing.T) {
+				// the writes outside of the transaction are recorded here
+				outside := &writeLog{}
+
+				var committed []string
+
+				ms := NewModerationService(
+					moderationUsers{user: user, log: outside},
+					moderationBooks{log: outside},
+					moderationEvents{log: outside},
+					fakeTransactor{user: user, events: tt.events, fail: fail, committed: &committed},
+					ModerationOptions{DeleteGracePeriod: time.Hour},
+				)
+
+				err := tt.action(ms, user.ID)
+
+				if len(outside.writes) > 0 {
+					t.Errorf("writes outside of the transaction: %v", outside.writes)
+				}
+
+				if fail != "" {
+					if !errors.Is(err, errWriteFailed) {
+						t.Errorf("err = %v, want %v", err, errWriteFailed)
+					}
+
+					if len(committed) > 0 {
+						t.Errorf("committed = %v, want nothing", committed)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+
+				if !reflect.DeepEqual(committed, tt.writes) {
+					t.Errorf("committed = %v, want %v", committed, tt.writes)
+				}
+			})
+		}
+	}
+}
+
+func TestLiftExpiredBansSkipsBannedAgain(t *testing.T) {
+	// the expired ban was found, and then a moderator banned the user again
+	until := time.Now().Add(time.Hour)
+	user := models.User{ID: uuid.New(), Status: models.UserStatusBanned, Role: models.RoleAuthor, BannedUntil: &until}
+
+	var committed []string
+
+	ms := NewModerationService(
+		moderationUsers{user: user, log: &writeLog{}},
+		moderationBooks{log: &writeLog{}},
+		moderationEvents{log: &writeLog{}},
+		fakeTransactor{user: user, committed: &committed},
+		ModerationOptions{DeleteGracePeriod: time.Hour},
+	)
+
+	lifted, err := ms.LiftExpiredBans(context.Background())
+
+	if err != nil {
+		t.Fatalf("lift expired bans: %v", err)
+	}
+
+	if lifted != 0 || len(committed) > 0 {
+		t.Errorf("lifted %d bans, committed %v, want the new ban kept", lifted, committed)
+	}
+}
@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/oidc"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+type OIDCService interface {
+	// Returns the names of the configured providers
+	Providers() []string
+
+	// Starts a sign in with the provider, returns the url where the user must
+	// be sent. If the provider is unknown, returns a [repos.NotFoundError]
+	StartSignIn(ctx context.Context, provider string) (payloads.OIDCRedirect, error)
+
+	// Finishes a sign in with the provider and creates a session. The user is
+	// found by its linked identity, else by its verified email, else a new
+	// active user is created. If the state or the code are not valid, returns
+	// an [repos.InvalidCredentialsError]. If the user has two factor
+	// authentication, returns a [repos.TwoFactorRequiredError] with a ticket
+	// for [OIDCService.SignInTwoFactor], because the state and the code can't
+	// be used again
+	SignIn(ctx context.Context, provider string, payload payloads.OIDCCallback, client payloads.ClientInfo) (payloads.UserSession, error)
+
+	// Finishes a sign in with a provider of an user with two factor
+	// authentication. If the ticket is not valid or expired, returns an
+	// [repos.InvalidCredentialsError], the code is checked as in
+	// [UserService.SignIn]
+	SignInTwoFactor(ctx context.Context, payload payloads.OIDCTwoFactor, client payloads.ClientInfo) (payloads.UserSession, error)
+
+	// Starts the link of the provider to the user, returns the url where the
+	// user must be sent
+	StartLink(ctx context.Context, userID uuid.UUID, provider string) (payloads.OIDCRedirect, error)
+
+	// Finishes the link of the provider to the user, if the identity is
+	// linked to other user or the user already linked the provider, returns
+	// a [repos.ConflictError]
+	Link(ctx context.Context, userID uuid.UUID, provider string, payload payloads.OIDCCallback) (payloads.ExternalIdentityList, error)
+
+	// Unlinks the provider from the user, if the user has no password and it's
+	// its last provider, returns a [repos.PermissionDeniedError] because it
+	// couldn't sign in anymore
+	Unlink(ctx context.Context, userID uuid.UUID, provider string) error
+
+	// Returns the identities linked to the user
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]payloads.ExternalIdentityList, error)
+
+	// Deletes the expired sign in states and two factor tickets, returns the
+	// number of deleted rows
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// OIDCOptions are the settings of the openid connect service
+type OIDCOptions struct {
+	// time given to the users to sign in with the provider
+	StateTTL time.Duration
+
+	// time given to the users with two factor authentication to send their
+	// code after signing in with the provider
+	TwoFactorTTL time.Duration
+}
+
+// number of tries to find a free username for the new users
+const usernameTries = 5
+
+type oidcService struct {
+	providers  map[string]*oidc.Provider
+	users      repos.UserRepo
+	identities repos.ExternalIdentityRepo
+	states     repos.OIDCStateRepo
+	tickets    repos.TwoFactorTicketRepo
+	userSvc    UserService
+	opts       OIDCOptions
+}
+
+func NewOIDCService(providers []*oidc.Provider, users repos.UserRepo, identities repos.ExternalIdentityRepo, states repos.OIDCStateRepo, tickets repos.TwoFactorTicketRepo, userSvc UserService, opts OIDCOptions) OIDCService {
+	byName := make(map[string]*oidc.Provider, len(providers))
+
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+
+	return oidcService{byName, users, identities, states, tickets, userSvc, opts}
+}
+
+func (ois oidcService) Providers() []string {
+	names := make([]string, 0, len(ois.providers))
+
+	for name := range ois.providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func (ois oidcService) StartSignIn(ctx context.Context, provider string) (payloads.OIDCRedirect, error) {
+	return ois.start(ctx, provider, uuid.Nil)
+}
+
+func (ois oidcService) SignIn(ctx context.Context, provider string, payload payloads.OIDCCallback, client payloads.ClientInfo) (payloads.UserSession, error) {
+	claims, err := ois.finish(ctx, provider, payload, uuid.Nil)
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	userID, err := ois.resolveUser(ctx, provider, claims)
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	user, err := ois.users.GetCredentialsByID(ctx, userID, models.UserStatusActive)
+
+	if repos.IsNotFoundError(err) {
+		return payloads.UserSession{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	if user.TwoFactor.Enabled {
+		return payloads.UserSession{}, ois.createTicket(ctx, userID)
+	}
+
+	return ois.userSvc.SignInExternal(ctx, userID, "", client)
+}
+
+func (ois oidcService) SignInTwoFactor(ctx context.Context, payload payloads.OIDCTwoFactor, client payloads.ClientInfo) (payloads.UserSession, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	ticket, err := ois.tickets.GetByHash(ctx, valobjs.TokenFromString(payload.Ticket).Hash())
+
+	if repos.IsNotFoundError(err) {
+		return payloads.UserSession{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	// a wrong code keeps the ticket, the tries are limited by the lockouts
+	session, err := ois.userSvc.SignInExternal(ctx, ticket.UserID, payload.Code, client)
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	err = ois.tickets.DeleteByID(ctx, ticket.ID)
+
+	if err != nil && !repos.IsNotFoundError(err) {
+		log.Printf("services: delete two factor ticket %s: %v", ticket.ID, err)
+	}
+
+	return session, nil
+}
+
+func (ois oidcService) StartLink(ctx context.Context, userID uuid.UUID, provider string) (payloads.OIDCRedirect, error) {
+	return ois.start(ctx, provider, userID)
+}
+
+func (ois oidcService) Link(ctx context.Context, userID uuid.UUID, provider string, payload payloads.OIDCCallback) (payloads.ExternalIdentityList, error) {
+	claims, err := ois.finish(ctx, provider, payload, userID)
+
+	if err != nil {
+		return payloads.ExternalIdentityList{}, err
+	}
+
+	identity := models.NewExternalIdentity(userID, provider, claims.Subject, claims.Email)
+
+	identity, err = ois.identities.CreateOne(ctx, identity)
+
+	if err != nil {
+		return payloads.ExternalIdentityList{}, err
+	}
+
+	return payloads.ExternalIdentityListFromModel(identity), nil
+}
+
+func (ois oidcService) Unlink(ctx context.Context, userID uuid.UUID, provider string) error {
+	user, err := ois.users.GetCredentialsByID(ctx, userID, models.UserStatusActive)
+
+	if err != nil {
+		return err
+	}
+
+	if user.Password.IsNil() {
+		identities, err := ois.identities.FilterByUser(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		if len(identities) <= 1 {
+			return repos.PermissionDeniedError{}
+		}
+	}
+
+	_, err = ois.identities.DeleteByProvider(ctx, userID, provider)
+
+	return err
+}
+
+func (ois oidcService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]payloads.ExternalIdentityList, error) {
+	identities, err := ois.identities.FilterByUser(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return payloads.ExternalIdentityListFromModels(identities), nil
+}
+
+func (ois oidcService) PurgeExpired(ctx context.Context) (int64, error) {
+	states, err := ois.states.DeleteExpired(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	tickets, err := ois.tickets.DeleteExpired(ctx)
+
+	if err != nil {
+		return states, err
+	}
+
+	return states + tickets, nil
+}
+
+// stores a new two factor ticket of the user, returns a
+// [repos.TwoFactorRequiredError] with the ticket
+func (ois oidcService) createTicket(ctx context.Context, userID uuid.UUID) error {
+	token, err := valobjs.NewToken()
+
+	if err != nil {
+		return err
+	}
+
+	ticket := models.NewTwoFactorTicket(token.Hash(), userID, ois.opts.TwoFactorTTL)
+
+	_, err = ois.tickets.CreateOne(ctx, ticket)
+
+	if err != nil {
+		return err
+	}
+
+	return repos.TwoFactorRequiredError{Ticket: token.Plain()}
+}
+
+// stores a new state and returns the url of the provider, the state is
+// bound to the user that links the provider
+func (ois oidcService) start(ctx context.Context, provider string, linkUserID uuid.UUID) (payloads.OIDCRedirect, error) {
+	p, ok := ois.providers[provider]
+
+	if !ok {
+		return payloads.OIDCRedirect{}, repos.NotFoundError{}
+	}
+
+	var secrets [3]valobjs.Token
+
+	for i := range secrets {
+		token, err := valobjs.NewToken()
+
+		if err != nil {
+			return payloads.OIDCRedirect{}, err
+		}
+
+		secrets[i] = token
+	}
+
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	url, err := p.AuthURL(ctx, state.Plain(), nonce.Plain(), verifier.Plain())
+
+	if err != nil {
+		return payloads.OIDCRedirect{}, err
+	}
+
+	st := models.NewOIDCState(state.Hash(), provider, nonce.Plain(), verifier.Plain(), linkUserID, ois.opts.StateTTL)
+
+	_, err = ois.states.CreateOne(ctx, st)
+
+	if err != nil {
+		return payloads.OIDCRedirect{}, err
+	}
+
+	return payloads.OIDCRedirect{URL: url}, nil
+}
+
+// consumes the state and exchanges the code, returns the verified claims of
+// the user in the provider
+func (ois oidcService) finish(ctx context.Context, provider string, payload payloads.OIDCCallback, linkUserID uuid.UUID) (oidc.Claims, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return oidc.Claims{}, err
+	}
+
+	p, ok := ois.providers[provider]
+
+	if !ok {
+		return oidc.Claims{}, repos.NotFoundError{}
+	}
+
+	st, err := ois.states.Consume(ctx, valobjs.TokenFromString(payload.State).Hash(), provider)
+
+	if repos.IsNotFoundError(err) {
+		return oidc.Claims{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return oidc.Claims{}, err
+	}
+
+	// a sign in state can't be used to link, and a link state only works for
+	// the user that started it
+	if st.LinkUserID != linkUserID {
+		return oidc.Claims{}, repos.InvalidCredentialsError{}
+	}
+
+	claims, err := p.Exchange(ctx, payload.Code, st.CodeVerifier, st.Nonce)
+
+	if errors.Is(err, oidc.ErrInvalidGrant) || errors.Is(err, oidc.ErrInvalidToken) {
+		log.Printf("services: oidc %s: %v", provider, err)
+		return oidc.Claims{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return oidc.Claims{}, err
+	}
+
+	return claims, nil
+}
+
+// returns the id of the user of the claims, linking the identity to the user
+// with the same verified email or to a new user
+func (ois oidcService) resolveUser(ctx context.Context, provider string, claims oidc.Claims) (uuid.UUID, error) {
+	identity, err := ois.identities.GetBySubject(ctx, provider, claims.Subject)
+
+	if err == nil {
+		return identity.UserID, nil
+	}
+
+	if !repos.IsNotFoundError(err) {
+		return uuid.Nil, err
+	}
+
+	// an unverified email could belong to other person, so it can't be used
+	// to find or create the account
+	if claims.Email == "" || !bool(claims.EmailVerified) {
+		log.Printf("services: oidc %s: the email of %s is not verified", provider, claims.Subject)
+		return uuid.Nil, repos.InvalidCredentialsError{}
+	}
+
+	user, err := ois.users.GetCredentialsByEmail(ctx, claims.Email, models.UserStatusActive)
+
+	if repos.IsNotFoundError(err) {
+		user, err = ois.createUser(ctx, claims)
+	}
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	identity = models.NewExternalIdentity(user.ID, provider, claims.Subject, claims.Email)
+
+	_, err = ois.identities.CreateOne(ctx, identity)
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return user.ID, nil
+}
+
+// creates an active user without password, the username is taken from the
+// claims with a random suffix if it's already used
+func (ois oidcService) createUser(ctx context.Context, claims oidc.Claims) (models.User, error) {
+	base := usernameFromClaims(claims)
+	nickname := truncate(claims.Name, nicknameMaxLen)
+
+	var err error
+
+	for i := 0; i < usernameTries; i++ {
+		username := base
+
+		if i > 0 {
+			username, err = withRandomSuffix(base)
+
+			if err != nil {
+				return models.User{}, err
+			}
+		}
+
+		user := models.NewUser(username, nickname, claims.Email, "", valobjs.NoPassword())
+
+		// the provider already verified the email
+		user.Status = models.UserStatusActive
+
+		user, err = ois.users.CreateOne(ctx, user)
+
+		if !repos.IsConflictError(err) {
+			return user, err
+		}
+	}
+
+	return models.User{}, err
+}
+
+const (
+	usernameMaxLen = 24
+	nicknameMaxLen = 50
+)
+
+// the usernames can only have letters, digits, underscores, dots and dashes
+func usernameFromClaims(claims oidc.Claims) string {
+	candidate := claims.PreferredUsername
+
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	var b strings.Builder
+
+	for _, r := range candidate {
+		if r < utf8.RuneSelf && (r == '_' || r == '.' || r == '-' ||
+			('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9')) {
+			b.WriteRune(r)
+		}
+	}
+
+	username := truncate(b.String(), usernameMaxLen)
+
+	if len(username) < 3 {
+		return "user"
+	}
+
+	return username
+}
+
+func withRandomSuffix(username string) (string, error) {
+	b := make([]byte, 3)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "", err
+	}
+
+	return username + "-" + hex.EncodeToString(b), nil
+}
+
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	return string([]rune(s)[:max])
+}
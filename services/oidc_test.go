@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/oidc"
+	"github.com/marlonmp/books-app/oidc/oidctest"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+)
+
+// the fakes embed the repos, so the methods not used by the service panic
+
+type fakeUsers struct {
+	repos.UserRepo
+
+	users []models.User
+
+	// number of updated passwords
+	rehashes int
+}
+
+func (fu *fakeUsers) CreateOne(ctx context.Context, u models.User) (models.User, error) {
+	u.ID = uuid.New()
+	fu.users = append(fu.users, u)
+
+	return u, nil
+}
+
+func (fu *fakeUsers) GetCredentialsByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (models.User, error) {
+	for _, u := range fu.users {
+		if u.ID == id && u.Status == status {
+			return u, nil
+		}
+	}
+
+	return models.User{}, repos.NotFoundError{}
+}
+
+func (fu *fakeUsers) GetCredentialsByEmail(ctx context.Context, email string, status models.UserStatus) (models.User, error) {
+	for _, u := range fu.users {
+		if u.Email == email && u.Status == status {
+			return u, nil
+		}
+	}
+
+	return models.User{}, repos.NotFoundError{}
+}
+
+type fakeIdentities struct {
+	repos.ExternalIdentityRepo
+
+	identities []models.ExternalIdentity
+}
+
+func (fi *fakeIdentities) CreateOne(ctx context.Context, ei models.ExternalIdentity) (models.ExternalIdentity, error) {
+	ei.ID = uuid.New()
+	fi.identities = append(fi.identities, ei)
+
+	return ei, nil
+}
+
+func (fi *fakeIdentities) GetBySubject(ctx context.Context, provider, subject string) (models.ExternalIdentity, error) {
+	for _, ei := range fi.identities {
+		if ei.Provider == provider && ei.Subject == subject {
+			return ei, nil
+		}
+	}
+
+	return models.ExternalIdentity{}, repos.NotFoundError{}
+}
+
+type fakeStates struct {
+	repos.OIDCStateRepo
+
+	states map[string]models.OIDCState
+}
+
+func (fs *fakeStates) CreateOne(ctx context.Context, s models.OIDCState) (models.OIDCState, error) {
+	fs.states[s.StateHash] = s
+
+	return s, nil
+}
+
+func (fs *fakeStates) Consume(ctx context.Context, stateHash, provider string) (models.OIDCState, error) {
+	s, ok := fs.states[stateHash]
+
+	if !ok || s.Provider != provider {
+		return models.OIDCState{}, repos.NotFoundError{}
+	}
+
+	delete(fs.states, stateHash)
+
+	return s, nil
+}
+
+type fakeTickets struct {
+	repos.TwoFactorTicketRepo
+
+	tickets map[string]models.TwoFactorTicket
+}
+
+func (ft *fakeTickets) CreateOne(ctx context.Context, t models.TwoFactorTicket) (models.TwoFactorTicket, error) {
+	t.ID = uuid.New()
+	ft.tickets[t.TicketHash] = t
+
+	return t, nil
+}
+
+func (ft *fakeTickets) GetByHash(ctx context.Context, ticketHash string) (models.TwoFactorTicket, error) {
+	t, ok := ft.tickets[ticketHash]
+
+	if !ok || time.Now().After(t.ExpiresAt) {
+		return models.TwoFactorTicket{}, repos.NotFoundError{}
+	}
+
+	return t, nil
+}
+
+func (ft *fakeTickets) DeleteByID(ctx context.Context, id uuid.UUID) error {
+	for hash, t := range ft.tickets {
+		if t.ID == id {
+			delete(ft.tickets, hash)
+			return nil
+		}
+	}
+
+	return repos.NotFoundError{}
+}
+
+// fakeUserService signs in the users with the given totp code
+type fakeUserService struct {
+	UserService
+
+	users *fakeUsers
+	code  string
+}
+
+func (fus fakeUserService) SignInExternal(ctx context.Context, userID uuid.UUID, code string, client payloads.ClientInfo) (payloads.UserSession, error) {
+	user, err := fus.users.GetCredentialsByID(ctx, userID, models.UserStatusActive)
+
+	if err != nil {
+		return payloads.UserSession{}, repos.InvalidCredentialsError{}
+	}
+
+	if user.TwoFactor.Enabled && code == "" {
+		return payloads.UserSession{}, repos.TwoFactorRequiredError{}
+	}
+
+	if user.TwoFactor.Enabled && code != fus.code {
+		return payloads.UserSession{}, repos.InvalidCredentialsError{}
+	}
+
+	return payloads.UserSession{User: payloads.UserListFromModel(user)}, nil
+}
+
+type oidcTest struct {
+	server     *oidctest.Server
+	users      *fakeUsers
+	identities *fakeIdentities
+	tickets    *fakeTickets
+	svc        OIDCService
+}
+
+func newOIDCTest(t *testing.T) oidcTest {
+	server := oidctest.NewServer(t)
+
+	provider := oidc.New("mock", oidc.Config{
+		Issuer:       server.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  oidctest.RedirectURL,
+	})
+
+	users := &fakeUsers{}
+	identities := &fakeIdentities{}
+	states := &fakeStates{states: map[string]models.OIDCState{}}
+	tickets := &fakeTickets{tickets: map[string]models.TwoFactorTicket{}}
+	userSvc := fakeUserService{users: users, code: "123456"}
+
+	svc := NewOIDCService([]*oidc.Provider{provider}, users, identities, states, tickets, userSvc, OIDCOptions{
+		StateTTL:     time.Minute,
+		TwoFactorTTL: time.Minute,
+	})
+
+	return oidcTest{server, users, identities, tickets, svc}
+}
+
+// goes through the provider and returns the callback sent back by it
+func (ot oidcTest) callback(t *testing.T) payloads.OIDCCallback {
+	t.Helper()
+
+	redirect, err := ot.svc.StartSignIn(context.Background(), "mock")
+
+	if err != nil {
+		t.Fatalf("start sign in: %v", err)
+	}
+
+	u, err := url.Parse(redirect.URL)
+
+	if err != nil {
+		t.Fatalf("parsing redirect: %v", err)
+	}
+
+	return payloads.OIDCCallback{
+		Code:  ot.server.Authorize(redirect.URL),
+		State: u.Query().Get("state"),
+	}
+}
+
+func (ot oidcTest) addUser(email string, twoFactor bool) models.User {
+	user, _ := ot.users.CreateOne(context.Background(), models.User{
+		Username:  "jane",
+		Email:     email,
+		Status:    models.UserStatusActive,
+		TwoFactor: models.TwoFactor{Enabled: twoFactor},
+	})
+
+	return user
+}
+
+func TestOIDCSignInResolvesUser(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// claims of the provider
+		email    string
+		verified any
+
+		// the user with the email exists, and if linked, is linked to the
+		// subject
+		existing,
+		linked bool
+
+		wantErr     error
+		wantNewUser bool
+	}{
+		{
+			name:     "linked identity",
+			email:    "other@example.com",
+			verified: false,
+			existing: true,
+			linked:   true,
+		},
+		{
+			name:     "verified email of an user",
+			email:    "jane@example.com",
+			verified: true,
+			existing: true,
+		},
+		{
+			name:     "verified email as a string",
+			email:    "jane@example.com",
+			verified: "true",
+			existing: true,
+		},
+		{
+			name:     "unverified email of an user",
+			email:    "jane@example.com",
+			verified: false,
+			existing: true,
+			wantErr:  repos.InvalidCredentialsError{},
+		},
+		{
+			name:        "verified email of no user",
+			email:       "new@example.com",
+			verified:    true,
+			wantNewUser: true,
+		},
+		{
+			name:     "unverified email of no user",
+			email:    "new@example.com",
+			verified: false,
+			wantErr:  repos.InvalidCredentialsError{},
+		},
+		{
+			name:     "no email",
+			verified: true,
+			wantErr:  repos.InvalidCredentialsError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ot := newOIDCTest(t)
+
+			ot.server.SetClaim("email", tt.email)
+			ot.server.SetClaim("email_verified", tt.verified)
+
+			var user models.User
+
+			if tt.existing {
+				user = ot.addUser("jane@example.com", false)
+			}
+
+			if tt.linked {
+				ot.identities.CreateOne(context.Background(), models.NewExternalIdentity(user.ID, "mock", ot.server.Subject, user.Email))
+			}
+
+			identities := len(ot.identities.identities)
+
+			session, err := ot.svc.SignIn(context.Background(), "mock", ot.callback(t), payloads.ClientInfo{})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+
+				if got := len(ot.identities.identities); got != identities {
+					t.Errorf("identities = %d, want %d", got, identities)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("sign in: %v", err)
+			}
+
+			if tt.wantNewUser {
+				if len(ot.users.users) != 1 || session.User.ID != ot.users.users[0].ID {
+					t.Fatalf("session of %v, want a new user, users = %+v", session.User.ID, ot.users.users)
+				}
+
+				user = ot.users.users[0]
+			} else if session.User.ID != user.ID {
+				t.Errorf("session of %v, want %v", session.User.ID, user.ID)
+			}
+
+			// the next sign ins find the user by its identity
+			identity, err := ot.identities.GetBySubject(context.Background(), "mock", ot.server.Subject)
+
+			if err != nil || identity.UserID != user.ID {
+				t.Errorf("identity = %+v, %v, want linked to %v", identity, err, user.ID)
+			}
+		})
+	}
+}
+
+func TestOIDCSignInStateUsedTwice(t *testing.T) {
+	ot := newOIDCTest(t)
+
+	ot.addUser("jane@example.com", false)
+	ot.server.SetClaim("email", "jane@example.com")
+	ot.server.SetClaim("email_verified", true)
+
+	callback := ot.callback(t)
+
+	_, err := ot.svc.SignIn(context.Background(), "mock", callback, payloads.ClientInfo{})
+
+	if err != nil {
+		t.Fatalf("first sign in: %v", err)
+	}
+
+	_, err = ot.svc.SignIn(context.Background(), "mock", callback, payloads.ClientInfo{})
+
+	if !errors.Is(err, repos.InvalidCredentialsError{}) {
+		t.Errorf("err = %v, want %v", err, repos.InvalidCredentialsError{})
+	}
+}
+
+func TestOIDCSignInTwoFactor(t *testing.T) {
+	ot := newOIDCTest(t)
+	ctx := context.Background()
+
+	user := ot.addUser("jane@example.com", true)
+	ot.server.SetClaim("email", "jane@example.com")
+	ot.server.SetClaim("email_verified", true)
+
+	_, err := ot.svc.SignIn(ctx, "mock", ot.callback(t), payloads.ClientInfo{})
+
+	var tfre repos.TwoFactorRequiredError
+
+	if !errors.As(err, &tfre) || tfre.Ticket == "" {
+		t.Fatalf("err = %v, want a %T with a ticket", err, tfre)
+	}
+
+	// the wrong codes keep the ticket, so the user can send the code again
+	_, err = ot.svc.SignInTwoFactor(ctx, payloads.OIDCTwoFactor{Ticket: tfre.Ticket, Code: "000000"}, payloads.ClientInfo{})
+
+	if !errors.Is(err, repos.InvalidCredentialsError{}) {
+		t.Fatalf("wrong code: err = %v, want %v", err, repos.InvalidCredentialsError{})
+	}
+
+	session, err := ot.svc.SignInTwoFactor(ctx, payloads.OIDCTwoFactor{Ticket: tfre.Ticket, Code: "123456"}, payloads.ClientInfo{})
+
+	if err != nil {
+		t.Fatalf("sign in with the ticket: %v", err)
+	}
+
+	if session.User.ID != user.ID {
+		t.Errorf("session of %v, want %v", session.User.ID, user.ID)
+	}
+
+	// the ticket is used once
+	_, err = ot.svc.SignInTwoFactor(ctx, payloads.OIDCTwoFactor{Ticket: tfre.Ticket, Code: "123456"}, payloads.ClientInfo{})
+
+	if !errors.Is(err, repos.InvalidCredentialsError{}) {
+		t.Errorf("used ticket: err = %v, want %v", err, repos.InvalidCredentialsError{})
+	}
+}
+
+func TestOIDCSignInTwoFactorValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload payloads.OIDCTwoFactor
+	}{
+		{"no ticket", payloads.OIDCTwoFactor{Code: "123456"}},
+		{"no code", payloads.OIDCTwoFactor{Ticket: "ticket"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ot := newOIDCTest(t)
+
+			_, err := ot.svc.SignInTwoFactor(context.Background(), tt.payload, payloads.ClientInfo{})
+
+			var ve repos.ValidationError
+
+			if !errors.As(err, &ve) {
+				t.Errorf("err = %v, want a %T", err, ve)
+			}
+		})
+	}
+}
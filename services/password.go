@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/marlonmp/books-app/mails"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+func (us userService) ForgotPassword(ctx context.Context, payload payloads.EmailRequest) error {
+	err := payload.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	user, err := us.users.GetCredentialsByEmail(ctx, payload.Email, models.UserStatusActive)
+
+	if repos.IsNotFoundError(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	token, err := valobjs.NewToken()
+
+	if err != nil {
+		return err
+	}
+
+	reset := models.NewPasswordReset(user.ID, token.Hash(), us.opts.PasswordResetTTL)
+
+	_, err = us.resets.CreateOne(ctx, reset)
+
+	if err != nil {
+		return err
+	}
+
+	link := us.opts.AppURL + "/reset-password?token=" + url.QueryEscape(token.Plain())
+
+	us.sendMail(ctx, mails.PasswordResetTemplate, user.Email, mails.PasswordResetData{
+		Username:  user.Username,
+		Link:      link,
+		ExpiresIn: formatDuration(us.opts.PasswordResetTTL),
+	})
+
+	return nil
+}
+
+func (us userService) ResetPassword(ctx context.Context, payload payloads.PasswordReset) error {
+	err := payload.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	tokenHash := valobjs.TokenFromString(payload.Token).Hash()
+
+	reset, err := us.resets.GetByTokenHash(ctx, tokenHash)
+
+	if repos.IsNotFoundError(err) {
+		return repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	user, err := us.users.GetByID(ctx, reset.UserID, models.UserStatusActive)
+
+	if repos.IsNotFoundError(err) {
+		return repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// the password is checked before using the token, so an invalid password
+	// doesn't waste it
+	err = payload.ValidateFor(user.Username, user.Email)
+
+	if err != nil {
+		return err
+	}
+
+	password, err := valobjs.HashPassword(payload.Password)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = us.resets.Consume(ctx, tokenHash)
+
+	if repos.IsNotFoundError(err) {
+		return repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = us.users.UpdatePasswordByID(ctx, user.ID, models.UserStatusActive, password)
+
+	if err != nil {
+		return err
+	}
+
+	// the other reset links, the sessions and the api tokens could have been
+	// created by whoever knew the old password
+	_, err = us.resets.DeleteByUser(ctx, user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = us.sessions.SignOutAll(ctx, user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = us.apiTokens.DeleteByUser(ctx, user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return us.lockouts.RegisterSuccess(ctx, models.AttemptScopeUser, user.ID.String())
+}
+
+// formats the duration for the users, e.g. "2 hours" or "30 minutes"
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int(d/time.Hour), "hour")
+	case d >= time.Minute:
+		return plural(int(d/time.Minute), "minute")
+	default:
+		return plural(int(d/time.Second), "second")
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+func (fu *fakeUsers) GetByID(ctx context.Context, id uuid.UUID, status models.UserStatus) (models.User, error) {
+	return fu.GetCredentialsByID(ctx, id, status)
+}
+
+type fakeResets struct {
+	repos.PasswordResetRepo
+
+	resets []models.PasswordReset
+}
+
+func (fr *fakeResets) GetByTokenHash(ctx context.Context, tokenHash string) (models.PasswordReset, error) {
+	for _, r := range fr.resets {
+		if r.TokenHash == tokenHash {
+			return r, nil
+		}
+	}
+
+	return models.PasswordReset{}, repos.NotFoundError{}
+}
+
+func (fr *fakeResets) Consume(ctx context.Context, tokenHash string) (models.PasswordReset, error) {
+	return fr.GetByTokenHash(ctx, tokenHash)
+}
+
+func (fr *fakeResets) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var n int64
+	resets := fr.resets[:0]
+
+	for _, r := range fr.resets {
+		if r.UserID == userID {
+			n++
+			continue
+		}
+
+		resets = append(resets, r)
+	}
+
+	fr.resets = resets
+
+	return n, nil
+}
+
+type fakeAPITokens struct {
+	repos.APITokenRepo
+
+	tokens []models.APIToken
+}
+
+func (fat *fakeAPITokens) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var n int64
+	tokens := fat.tokens[:0]
+
+	for _, t := range fat.tokens {
+		if t.UserID == userID {
+			n++
+			continue
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	fat.tokens = tokens
+
+	return n, nil
+}
+
+// signOutSessions records the users signed out of all their sessions
+type signOutSessions struct {
+	SessionService
+
+	signedOut []uuid.UUID
+}
+
+func (sos *signOutSessions) SignOutAll(ctx context.Context, userID uuid.UUID) error {
+	sos.signedOut = append(sos.signedOut, userID)
+
+	return nil
+}
+
+func TestResetPasswordRevokesAccess(t *testing.T) {
+	ctx := context.Background()
+
+	users := &fakeUsers{}
+	jane, _ := users.CreateOne(ctx, newTestUser(t, "jane@example.com", "s3cret-pass"))
+	john, _ := users.CreateOne(ctx, newTestUser(t, "john@example.com", "s3cret-pass"))
+
+	token, err := valobjs.NewToken()
+
+	if err != nil {
+		t.Fatalf("new token: %v", err)
+	}
+
+	resets := &fakeResets{resets: []models.PasswordReset{
+		models.NewPasswordReset(jane.ID, token.Hash(), 0),
+		models.NewPasswordReset(jane.ID, "other link", 0),
+	}}
+
+	// an attacker who knew the old password could have created a token
+	apiTokens := &fakeAPITokens{tokens: []models.APIToken{
+		models.NewAPIToken(jane.ID, "attacker", "hash 1", []string{models.ScopeBooksWrite}, nil),
+		models.NewAPIToken(john.ID, "ci", "hash 2", []string{models.ScopeBooksRead}, nil),
+	}}
+
+	sessions := &signOutSessions{}
+	lockouts, _, _ := newLockoutTest(testLockoutOptions)
+
+	svc := userService{
+		users:     users,
+		sessions:  sessions,
+		resets:    resets,
+		apiTokens: apiTokens,
+		lockouts:  lockouts,
+	}
+
+	err = svc.ResetPassword(ctx, payloads.PasswordReset{Token: token.Plain(), Password: "n3w-passw0rd"})
+
+	if err != nil {
+		t.Fatalf("reset password: %v", err)
+	}
+
+	if !users.users[0].Password.IsEqual("n3w-passw0rd") {
+		t.Error("the password was not changed")
+	}
+
+	if len(resets.resets) != 0 {
+		t.Errorf("resets after the reset = %v, want none", resets.resets)
+	}
+
+	if len(sessions.signedOut) != 1 || sessions.signedOut[0] != jane.ID {
+		t.Errorf("signed out users = %v, want %v", sessions.signedOut, jane.ID)
+	}
+
+	if len(apiTokens.tokens) != 1 || apiTokens.tokens[0].UserID != john.ID {
+		t.Errorf("api tokens after the reset = %v, want only the one of the other user", apiTokens.tokens)
+	}
+
+	// the token was used
+	err = svc.ResetPassword(ctx, payloads.PasswordReset{Token: token.Plain(), Password: "an0ther-pass"})
+
+	if !errors.Is(err, repos.InvalidCredentialsError{}) {
+		t.Errorf("second reset = %v, want an InvalidCredentialsError", err)
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+type SessionService interface {
+	// Creates a session for the given user and returns its token, the token
+	// is only available here because just its hash is stored
+	CreateSession(ctx context.Context, userID uuid.UUID, client payloads.ClientInfo) (payloads.SessionToken, error)
+
+	// Returns the identity of the not expired session with the given token of
+	// an active user, else returns an [repos.InvalidCredentialsError]
+	Authenticate(ctx context.Context, token string) (payloads.Identity, error)
+
+	// Returns the active sessions of the user, marking the current one
+	ListSessions(ctx context.Context, identity payloads.Identity) ([]payloads.SessionList, error)
+
+	// Revokes one session of the user
+	SignOut(ctx context.Context, userID, sessionID uuid.UUID) error
+
+	// Revokes all the sessions of the user
+	SignOutAll(ctx context.Context, userID uuid.UUID) error
+
+	// Deletes the expired sessions, returns the number of deleted sessions
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+type sessionService struct {
+	sessions repos.SessionRepo
+	users    repos.UserRepo
+	ttl      time.Duration
+}
+
+func NewSessionService(sessions repos.SessionRepo, users repos.UserRepo, ttl time.Duration) SessionService {
+	return sessionService{sessions, users, ttl}
+}
+
+func (ss sessionService) CreateSession(ctx context.Context, userID uuid.UUID, client payloads.ClientInfo) (payloads.SessionToken, error) {
+	token, err := valobjs.NewToken()
+
+	if err != nil {
+		return payloads.SessionToken{}, err
+	}
+
+	session := models.NewSession(userID, token.Hash(), client.UserAgent, client.IP, ss.ttl)
+
+	session, err = ss.sessions.CreateOne(ctx, session)
+
+	if err != nil {
+		return payloads.SessionToken{}, err
+	}
+
+	return payloads.SessionToken{Token: token.Plain(), ExpiresAt: session.ExpiresAt}, nil
+}
+
+func (ss sessionService) Authenticate(ctx context.Context, token string) (payloads.Identity, error) {
+	if token == "" {
+		return payloads.Identity{}, repos.MissingCredentialsError{}
+	}
+
+	session, err := ss.sessions.GetByTokenHash(ctx, valobjs.TokenFromString(token).Hash())
+
+	if repos.IsNotFoundError(err) {
+		return payloads.Identity{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return payloads.Identity{}, err
+	}
+
+	role, err := activeUserRole(ctx, ss.users, session.UserID)
+
+	if err != nil {
+		return payloads.Identity{}, err
+	}
+
+	return payloads.Identity{UserID: session.UserID, SessionID: session.ID, Role: role}, nil
+}
+
+// returns the role of the active user with the given id, if the user is not
+// active anymore, returns an [repos.InvalidCredentialsError]
+func activeUserRole(ctx context.Context, users repos.UserRepo, userID uuid.UUID) (models.Role, error) {
+	user, err := users.GetByID(ctx, userID, models.UserStatusActive)
+
+	if repos.IsNotFoundError(err) {
+		return models.RoleUnknown, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return models.RoleUnknown, err
+	}
+
+	return user.Role, nil
+}
+
+func (ss sessionService) ListSessions(ctx context.Context, identity payloads.Identity) ([]payloads.SessionList, error) {
+	sessions, err := ss.sessions.FilterByUser(ctx, identity.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sessionsPayload := payloads.SessionListFromModels(sessions, identity.SessionID)
+
+	return sessionsPayload, nil
+}
+
+func (ss sessionService) SignOut(ctx context.Context, userID, sessionID uuid.UUID) error {
+	_, err := ss.sessions.DeleteByID(ctx, sessionID, userID)
+
+	return err
+}
+
+func (ss sessionService) SignOutAll(ctx context.Context, userID uuid.UUID) error {
+	_, err := ss.sessions.DeleteByUser(ctx, userID)
+
+	return err
+}
+
+func (ss sessionService) PurgeExpired(ctx context.Context) (int64, error) {
+	return ss.sessions.DeleteExpired(ctx)
+}
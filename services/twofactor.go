@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+func (us userService) EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (payloads.TwoFactorEnrollment, error) {
+	user, err := us.users.GetCredentialsByID(ctx, userID, models.UserStatusActive)
+
+	if err != nil {
+		return payloads.TwoFactorEnrollment{}, err
+	}
+
+	// a new secret would lock out the authenticator apps already in use
+	if user.TwoFactor.Enabled {
+		return payloads.TwoFactorEnrollment{}, repos.ConflictError{}
+	}
+
+	secret, err := valobjs.NewTOTPSecret()
+
+	if err != nil {
+		return payloads.TwoFactorEnrollment{}, err
+	}
+
+	err = us.users.UpdateTwoFactorByID(ctx, user.ID, models.UserStatusActive, models.TwoFactor{Secret: secret})
+
+	if err != nil {
+		return payloads.TwoFactorEnrollment{}, err
+	}
+
+	enrollment := payloads.TwoFactorEnrollment{
+		Secret: string(secret),
+		URI:    secret.URI(us.opts.TOTPIssuer, user.Email),
+	}
+
+	return enrollment, nil
+}
+
+func (us userService) EnableTwoFactor(ctx context.Context, userID uuid.UUID, payload payloads.TwoFactorCode) (payloads.RecoveryCodes, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.RecoveryCodes{}, err
+	}
+
+	user, err := us.users.GetCredentialsByID(ctx, userID, models.UserStatusActive)
+
+	if err != nil {
+		return payloads.RecoveryCodes{}, err
+	}
+
+	if user.TwoFactor.Enabled {
+		return payloads.RecoveryCodes{}, repos.ConflictError{}
+	}
+
+	if user.TwoFactor.Secret.IsZero() {
+		return payloads.RecoveryCodes{}, repos.InvalidStatusTransitionError{}
+	}
+
+	step, ok := user.TwoFactor.Secret.Verify(payload.Code, time.Now())
+
+	if !ok {
+		return payloads.RecoveryCodes{}, repos.InvalidCredentialsError{}
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+
+	if err != nil {
+		return payloads.RecoveryCodes{}, err
+	}
+
+	tf := models.TwoFactor{
+		Secret:        user.TwoFactor.Secret,
+		Enabled:       true,
+		LastStep:      step,
+		RecoveryCodes: hashes,
+	}
+
+	err = us.users.UpdateTwoFactorByID(ctx, user.ID, models.UserStatusActive, tf)
+
+	if err != nil {
+		return payloads.RecoveryCodes{}, err
+	}
+
+	return payloads.RecoveryCodes{Codes: codes}, nil
+}
+
+func (us userService) DisableTwoFactor(ctx context.Context, userID uuid.UUID, payload payloads.TwoFactorReauth, client payloads.ClientInfo) error {
+	user, err := us.reauthenticate(ctx, userID, payload, client)
+
+	if err != nil {
+		return err
+	}
+
+	return us.users.UpdateTwoFactorByID(ctx, user.ID, models.UserStatusActive, models.TwoFactor{})
+}
+
+func (us userService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, payload payloads.TwoFactorReauth, client payloads.ClientInfo) (payloads.RecoveryCodes, error) {
+	user, err := us.reauthenticate(ctx, userID, payload, client)
+
+	if err != nil {
+		return payloads.RecoveryCodes{}, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+
+	if err != nil {
+		return payloads.RecoveryCodes{}, err
+	}
+
+	// the last step is read again, the reauthentication could have used it
+	user, err = us.users.GetCredentialsByID(ctx, user.ID, models.UserStatusActive)
+
+	if err != nil {
+		return payloads.RecoveryCodes{}, err
+	}
+
+	tf := user.TwoFactor
+	tf.RecoveryCodes = hashes
+
+	err = us.users.UpdateTwoFactorByID(ctx, user.ID, models.UserStatusActive, tf)
+
+	if err != nil {
+		return payloads.RecoveryCodes{}, err
+	}
+
+	return payloads.RecoveryCodes{Codes: codes}, nil
+}
+
+// checks again the password and the second factor of an user with two factor
+// authentication, the failures count as failed sign ins
+func (us userService) reauthenticate(ctx context.Context, userID uuid.UUID, payload payloads.TwoFactorReauth, client payloads.ClientInfo) (models.User, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := us.users.GetCredentialsByID(ctx, userID, models.UserStatusActive)
+
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if !user.TwoFactor.Enabled {
+		return models.User{}, repos.InvalidStatusTransitionError{}
+	}
+
+	err = us.lockouts.CheckLocked(ctx, models.AttemptScopeUser, user.ID.String())
+
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if !user.Password.IsEqual(payload.Password) {
+		return models.User{}, us.signInFailed(ctx, client, &user)
+	}
+
+	err = us.checkSecondFactor(ctx, client, user, payload.Code)
+
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// checks the totp or recovery code of an user with two factor authentication,
+// the used code can't be used again
+func (us userService) checkSecondFactor(ctx context.Context, client payloads.ClientInfo, user models.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if code == "" {
+		return repos.TwoFactorRequiredError{}
+	}
+
+	var err error
+
+	if step, ok := user.TwoFactor.Secret.Verify(code, time.Now()); ok {
+		err = us.users.UseTOTPStep(ctx, user.ID, step)
+	} else {
+		err = us.users.UseRecoveryCode(ctx, user.ID, valobjs.HashRecoveryCode(code))
+	}
+
+	// the replayed totp codes and the unknown recovery codes are not found
+	if repos.IsNotFoundError(err) {
+		return us.signInFailed(ctx, client, &user)
+	}
+
+	return err
+}
+
+// returns the plain recovery codes that are shown to the user and their
+// hashes that are stored
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, models.RecoveryCodesCount)
+	hashes := make([]string, models.RecoveryCodesCount)
+
+	for i := range codes {
+		code, err := valobjs.NewRecoveryCode()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes[i] = code
+		hashes[i] = valobjs.HashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
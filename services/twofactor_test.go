@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+// the steps are only accepted if they are newer than the last one, as the
+// sql of the repo
+func (fu *fakeUsers) UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) error {
+	for i, u := range fu.users {
+		if u.ID == id && u.TwoFactor.Enabled && u.TwoFactor.LastStep < step {
+			fu.users[i].TwoFactor.LastStep = step
+
+			return nil
+		}
+	}
+
+	return repos.NotFoundError{}
+}
+
+func (fu *fakeUsers) UseRecoveryCode(ctx context.Context, id uuid.UUID, codeHash string) error {
+	for i, u := range fu.users {
+		if u.ID != id || !u.TwoFactor.Enabled {
+			continue
+		}
+
+		j := slices.Index(u.TwoFactor.RecoveryCodes, codeHash)
+
+		if j < 0 {
+			break
+		}
+
+		fu.users[i].TwoFactor.RecoveryCodes = slices.Delete(slices.Clone(u.TwoFactor.RecoveryCodes), j, j+1)
+
+		return nil
+	}
+
+	return repos.NotFoundError{}
+}
+
+// returns the current totp code of the secret, as an authenticator app
+func currentTOTPCode(t *testing.T, secret valobjs.TOTPSecret) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(string(secret))
+
+	if err != nil {
+		t.Fatalf("decoding secret: %v", err)
+	}
+
+	var msg [8]byte
+
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1_000_000)
+}
+
+func TestSignInTwoFactorCodesAreSingleUse(t *testing.T) {
+	secret, err := valobjs.NewTOTPSecret()
+
+	if err != nil {
+		t.Fatalf("new secret: %v", err)
+	}
+
+	const recoveryCode = "abcdefgh-ijklmnop"
+
+	user := newTestUser(t, "jane@example.com", "s3cret-pass")
+	user.TwoFactor.Secret = secret
+	user.TwoFactor.Enabled = true
+	user.TwoFactor.RecoveryCodes = []string{valobjs.HashRecoveryCode(recoveryCode)}
+
+	ctx := context.Background()
+	svc, _ := newSignInTest(t, user)
+
+	signIn := func(code string) error {
+		_, err := svc.SignIn(ctx, payloads.UserCredentials{
+			Login:    "jane@example.com",
+			Password: "s3cret-pass",
+			Code:     code,
+		}, payloads.ClientInfo{IP: "10.0.0.1"})
+
+		return err
+	}
+
+	if err := signIn(""); !errors.Is(err, repos.TwoFactorRequiredError{}) {
+		t.Fatalf("sign in without code = %v, want a TwoFactorRequiredError", err)
+	}
+
+	code := currentTOTPCode(t, secret)
+
+	if err := signIn(code); err != nil {
+		t.Fatalf("sign in with the totp code: %v", err)
+	}
+
+	// the step of the code was used, so the code is replayed
+	if err := signIn(code); !errors.Is(err, repos.InvalidCredentialsError{}) {
+		t.Errorf("sign in with a used totp code = %v, want an InvalidCredentialsError", err)
+	}
+
+	// the recovery codes are accepted in any case and without the dash
+	if err := signIn("ABCDEFGHIJKLMNOP"); err != nil {
+		t.Fatalf("sign in with the recovery code: %v", err)
+	}
+
+	if err := signIn(recoveryCode); !errors.Is(err, repos.InvalidCredentialsError{}) {
+		t.Errorf("sign in with a used recovery code = %v, want an InvalidCredentialsError", err)
+	}
+}
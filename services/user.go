@@ -2,35 +2,141 @@ package services
 
 import (
 	"context"
+	"log"
+	"net/url"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/mails"
 	"github.com/marlonmp/books-app/models"
 	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/policy"
 	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
 )
 
+type UserService interface {
+	// Returns one page of the users with the given filters, see
+	// [repos.UserRepo.FilterPage]
+	ListUsers(ctx context.Context, uf *repos.UserFilters, pf repos.PageFilters) (payloads.Page[payloads.UserList], error)
+
+	// Creates an unverified user with the given payload
+	SignUp(ctx context.Context, payload payloads.UserCreate) (payloads.UserList, error)
+
+	// Creates a session for the active user that matches with the given
+	// credentials, if the credentials don't match, returns an
+	// [repos.InvalidCredentialsError]. After too many failures the user or the
+	// client ip get locked and a [repos.TooManyAttemptsError] is returned. If
+	// the user has two factor authentication and the payload has no code,
+	// returns a [repos.TwoFactorRequiredError]
+	SignIn(ctx context.Context, payload payloads.UserCredentials, client payloads.ClientInfo) (payloads.UserSession, error)
+
+	// Updates the profile of the active user, the empty fields are not updated
+	UpdateProfile(ctx context.Context, userID uuid.UUID, payload payloads.UserUpdate) (payloads.UserList, error)
+
+	// Creates a session for the active user with the given id, it must be
+	// already authenticated by other method, like an openid connect provider.
+	// The second factor is checked as in [UserService.SignIn]
+	SignInExternal(ctx context.Context, userID uuid.UUID, code string, client payloads.ClientInfo) (payloads.UserSession, error)
+
+	// Changes the role of an active user, see [policy.AssignRole]
+	AssignRole(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.RoleAssignment) (payloads.UserList, error)
+
+	// Returns the public profile of an active user, including its public books
+	UserProfile(ctx context.Context, username string) (payloads.UserProfile, error)
+
+	// Activates the unverified user of the given verification token, if the
+	// token is not valid, returns an [repos.InvalidCredentialsError]
+	VerifyEmail(ctx context.Context, payload payloads.EmailVerification) (payloads.UserList, error)
+
+	// Sends a new verification email if there is an unverified user with the
+	// given email, the previous tokens stop working. It never reveals if the
+	// email exists
+	ResendVerification(ctx context.Context, payload payloads.EmailRequest) error
+
+	// Sends a password reset link if there is an active user with the given
+	// email. It never reveals if the email exists
+	ForgotPassword(ctx context.Context, payload payloads.EmailRequest) error
+
+	// Sets the new password of the user of the given reset token and revokes
+	// all its sessions and api tokens, if the token is not valid, returns an
+	// [repos.InvalidCredentialsError]
+	ResetPassword(ctx context.Context, payload payloads.PasswordReset) error
+
+	// Creates a new totp secret for the user, it's not used to sign in until
+	// the user verifies it with [UserService.EnableTwoFactor]. If the two
+	// factor authentication is already enabled, returns a [repos.ConflictError]
+	EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (payloads.TwoFactorEnrollment, error)
+
+	// Enables the two factor authentication if the code matches the enrolled
+	// secret, returns the recovery codes that are only shown this time
+	EnableTwoFactor(ctx context.Context, userID uuid.UUID, payload payloads.TwoFactorCode) (payloads.RecoveryCodes, error)
+
+	// Disables the two factor authentication after checking again the password
+	// and a totp or recovery code
+	DisableTwoFactor(ctx context.Context, userID uuid.UUID, payload payloads.TwoFactorReauth, client payloads.ClientInfo) error
+
+	// Replaces the recovery codes after checking again the password and a totp
+	// or recovery code, returns the new codes that are only shown this time
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, payload payloads.TwoFactorReauth, client payloads.ClientInfo) (payloads.RecoveryCodes, error)
+
+	// Deletes the users that were not verified in time, returns the number of
+	// deleted users
+	PurgeUnverified(ctx context.Context) (int64, error)
+}
+
+// UserOptions are the settings of the user service
+type UserOptions struct {
+	// base url of the frontend, used to build the links sent by email
+	AppURL string
+
+	// time that a verification link lasts
+	VerificationTTL time.Duration
+
+	// time given to the users to verify their email before being deleted
+	UnverifiedTTL time.Duration
+
+	// time that a password reset link lasts
+	PasswordResetTTL time.Duration
+
+	// name shown by the authenticator apps next to the totp codes
+	TOTPIssuer string
+}
+
 type userService struct {
-	users repos.UserRepo
-	books repos.BookRepo
+	users         repos.UserRepo
+	books         repos.BookRepo
+	sessions      SessionService
+	verifications repos.VerificationRepo
+	resets        repos.PasswordResetRepo
+	apiTokens     repos.APITokenRepo
+	lockouts      LockoutService
+	mailer        mails.Mailer
+	opts          UserOptions
+}
+
+func NewUserService(users repos.UserRepo, books repos.BookRepo, sessions SessionService, verifications repos.VerificationRepo, resets repos.PasswordResetRepo, apiTokens repos.APITokenRepo, lockouts LockoutService, mailer mails.Mailer, opts UserOptions) UserService {
+	return userService{users, books, sessions, verifications, resets, apiTokens, lockouts, mailer, opts}
 }
 
-func (us userService) ListUsers(ctx context.Context, uf *repos.UserFilters) ([]payloads.UserList, error) {
-	users, err := us.users.FilterMany(ctx, uf)
+func (us userService) ListUsers(ctx context.Context, uf *repos.UserFilters, pf repos.PageFilters) (payloads.Page[payloads.UserList], error) {
+	page, err := us.users.FilterPage(ctx, uf, pf)
 
 	if err != nil {
-		return nil, err
+		return payloads.Page[payloads.UserList]{}, err
 	}
 
-	usersPayload := payloads.UserListFromModels(users)
+	usersPayload := payloads.PageFromRepo(page, payloads.UserListFromModels)
 
 	return usersPayload, nil
 }
 
 func (us userService) SignUp(ctx context.Context, payload payloads.UserCreate) (payloads.UserList, error) {
-	// make the user validation
-	// err := payload.Validate()
-	// if err != nil {
-	// 	return payloads.UserList{}, err
-	// }
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
 
 	user, err := payload.ToModel()
 
@@ -47,52 +153,199 @@ func (us userService) SignUp(ctx context.Context, payload payloads.UserCreate) (
 		return payloads.UserList{}, err
 	}
 
-	// send the verification email
-	// verification, err := auth.CreateUserVerification(ctx, user)
-	// if err != nil {
-	// 	return payloads.UserList{}, err
-	// }
-	// go mails.SendHTMLMail(ctx, user.Email, "subject", templates.VerificationEmail, verification, retry=true)
+	err = us.sendVerification(ctx, user)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
 
 	usersPayload := payloads.UserListFromModel(user)
 
 	return usersPayload, nil
 }
 
-func (us userService) SignIn(ctx context.Context, payload payloads.UserCredentials) (payloads.UserList, error) {
-	// validate
-	// err := payload.Validate()
-	// if err != nil {
-	// 	return payloads.UserList{}, err
-	// }
+func (us userService) SignIn(ctx context.Context, payload payloads.UserCredentials, client payloads.ClientInfo) (payloads.UserSession, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
 
-	user, err := us.users.GetCredentialsByUsername(ctx, payload.Username, models.UserStatusActive)
+	err = us.lockouts.CheckLocked(ctx, models.AttemptScopeIP, client.IP)
 
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	user, err := us.getCredentials(ctx, payload)
+
+	// the same error is returned for unknown usernames and emails, and a hash
+	// is compared anyway so the response time is the same
 	if repos.IsNotFoundError(err) {
-		// must return an permissions denied
+		valobjs.CompareDummyPassword(payload.Password)
+
+		return payloads.UserSession{}, us.signInFailed(ctx, client, nil)
+	}
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	err = us.lockouts.CheckLocked(ctx, models.AttemptScopeUser, user.ID.String())
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	if !user.Password.IsEqual(payload.Password) {
+		return payloads.UserSession{}, us.signInFailed(ctx, client, &user)
+	}
+
+	userSession, err := us.startSession(ctx, client, user, payload.Code)
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	us.rehashPassword(ctx, user, payload.Password)
+
+	return userSession, nil
+}
+
+func (us userService) SignInExternal(ctx context.Context, userID uuid.UUID, code string, client payloads.ClientInfo) (payloads.UserSession, error) {
+	user, err := us.users.GetCredentialsByID(ctx, userID, models.UserStatusActive)
+
+	if repos.IsNotFoundError(err) {
+		return payloads.UserSession{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	err = us.lockouts.CheckLocked(ctx, models.AttemptScopeUser, user.ID.String())
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	return us.startSession(ctx, client, user, code)
+}
+
+// creates the session of an user whose first factor was already checked, the
+// second factor is checked if the user has it
+func (us userService) startSession(ctx context.Context, client payloads.ClientInfo, user models.User, code string) (payloads.UserSession, error) {
+	if user.TwoFactor.Enabled {
+		err := us.checkSecondFactor(ctx, client, user, code)
+
+		if err != nil {
+			return payloads.UserSession{}, err
+		}
+	}
+
+	// the ip failures are not reset, so an attacker can't reset them by
+	// signing in with its own account
+	err := us.lockouts.RegisterSuccess(ctx, models.AttemptScopeUser, user.ID.String())
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	session, err := us.sessions.CreateSession(ctx, user.ID, client)
+
+	if err != nil {
+		return payloads.UserSession{}, err
+	}
+
+	us.sendMail(ctx, mails.NewSessionTemplate, user.Email, mails.NewSessionData{
+		Username:  user.Username,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		Time:      time.Now(),
+	})
+
+	userSession := payloads.UserSession{
+		SessionToken: session,
+		User:         payloads.UserListFromModel(user),
+	}
+
+	return userSession, nil
+}
+
+// returns the active user with the login of the credentials, the login can be
+// an username or an email
+func (us userService) getCredentials(ctx context.Context, payload payloads.UserCredentials) (models.User, error) {
+	if email, ok := payload.Email(); ok {
+		return us.users.GetCredentialsByEmail(ctx, email, models.UserStatusActive)
+	}
+
+	return us.users.GetCredentialsByUsername(ctx, payload.Username(), models.UserStatusActive)
+}
+
+// counts the failure for the client ip and for the user if it exists, and
+// returns the error for the failed sign in
+func (us userService) signInFailed(ctx context.Context, client payloads.ClientInfo, user *models.User) error {
+	err := us.lockouts.RegisterFailure(ctx, models.AttemptScopeIP, client.IP)
+
+	if err != nil {
+		return err
+	}
+
+	if user != nil {
+		err = us.lockouts.RegisterFailure(ctx, models.AttemptScopeUser, user.ID.String())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return repos.InvalidCredentialsError{}
+}
+
+func (us userService) UpdateProfile(ctx context.Context, userID uuid.UUID, payload payloads.UserUpdate) (payloads.UserList, error) {
+	err := payload.Validate()
+
+	if err != nil {
 		return payloads.UserList{}, err
 	}
 
+	user, err := us.users.UpdateByID(ctx, userID, models.UserStatusActive, payload.ToModel())
+
 	if err != nil {
 		return payloads.UserList{}, err
 	}
 
-	if !user.Password.IsEqual(payload.Password) {
-		// err = us.IncrementSignInTryes(ctx, user)
-		// if err != nil {
-		// 	return payloads.UserList{}, err
-		// }
+	return payloads.UserListFromModel(user), nil
+}
+
+func (us userService) AssignRole(ctx context.Context, actor policy.Actor, userID uuid.UUID, payload payloads.RoleAssignment) (payloads.UserList, error) {
+	err := payload.Validate()
+
+	if err != nil {
 		return payloads.UserList{}, err
 	}
 
-	// session, err := us.sessions.CreateUserSession(ctx, user)
-	// if err != nil {
-	// 	return payloads.UserList{}, err
-	// }
+	user, err := us.users.GetByID(ctx, userID, models.UserStatusActive)
 
-	usersPayload := payloads.UserListFromModel(user)
+	if err != nil {
+		return payloads.UserList{}, err
+	}
 
-	return usersPayload, nil
+	err = policy.AssignRole(actor, user, payload.Role)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	err = us.users.UpdateRoleByID(ctx, user.ID, models.UserStatusActive, payload.Role)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	user.Role = payload.Role
+
+	return payloads.UserListFromModel(user), nil
 }
 
 func (us userService) UserProfile(ctx context.Context, username string) (payloads.UserProfile, error) {
@@ -104,7 +357,146 @@ func (us userService) UserProfile(ctx context.Context, username string) (payload
 		return payloads.UserProfile{}, err
 	}
 
+	bf := &repos.BookFilters{AuthorID: user.ID, Status: models.BookStatusPublic}
+
+	user.Books, err = us.books.FilterMany(ctx, bf)
+
+	if err != nil {
+		return payloads.UserProfile{}, err
+	}
+
 	payload := payloads.UserProfileFromModel(user)
 
 	return payload, nil
 }
+
+func (us userService) VerifyEmail(ctx context.Context, payload payloads.EmailVerification) (payloads.UserList, error) {
+	err := payload.Validate()
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	token := valobjs.TokenFromString(payload.Token)
+
+	verification, err := us.verifications.GetByTokenHash(ctx, token.Hash())
+
+	if repos.IsNotFoundError(err) {
+		return payloads.UserList{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	update := models.User{Status: models.UserStatusActive}
+
+	user, err := us.users.UpdateByID(ctx, verification.UserID, models.UserStatusUnverified, update)
+
+	if repos.IsNotFoundError(err) {
+		return payloads.UserList{}, repos.InvalidCredentialsError{}
+	}
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	_, err = us.verifications.DeleteByUser(ctx, user.ID)
+
+	if err != nil {
+		return payloads.UserList{}, err
+	}
+
+	usersPayload := payloads.UserListFromModel(user)
+
+	return usersPayload, nil
+}
+
+func (us userService) ResendVerification(ctx context.Context, payload payloads.EmailRequest) error {
+	err := payload.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	user, err := us.users.GetCredentialsByEmail(ctx, payload.Email, models.UserStatusUnverified)
+
+	if repos.IsNotFoundError(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = us.verifications.DeleteByUser(ctx, user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return us.sendVerification(ctx, user)
+}
+
+func (us userService) PurgeUnverified(ctx context.Context) (int64, error) {
+	return us.users.DeleteUnverifiedBefore(ctx, time.Now().Add(-us.opts.UnverifiedTTL))
+}
+
+// creates a verification token for the user and sends it by email
+func (us userService) sendVerification(ctx context.Context, user models.User) error {
+	token, err := valobjs.NewToken()
+
+	if err != nil {
+		return err
+	}
+
+	verification := models.NewUserVerification(user.ID, token.Hash(), us.opts.VerificationTTL)
+
+	_, err = us.verifications.CreateOne(ctx, verification)
+
+	if err != nil {
+		return err
+	}
+
+	link := us.opts.AppURL + "/verify-email?token=" + url.QueryEscape(token.Plain())
+
+	us.sendMail(ctx, mails.VerificationTemplate, user.Email, mails.VerificationData{
+		Username: user.Username,
+		Link:     link,
+	})
+
+	return nil
+}
+
+// rehashes the password if it was hashed with an old algorithm or cost, the
+// failures are only logged so they don't block the sign in
+func (us userService) rehashPassword(ctx context.Context, user models.User, pwd string) {
+	if !user.Password.NeedsRehash() {
+		return
+	}
+
+	password, err := user.Password.Rehash(pwd)
+
+	if err == nil {
+		err = us.users.UpdatePasswordByID(ctx, user.ID, models.UserStatusActive, password)
+	}
+
+	if err != nil {
+		log.Printf("services: rehashing password of user %s: %v", user.ID, err)
+	}
+}
+
+// renders and sends the mail, the mailer is expected to queue it so the
+// request is not blocked. A mail that can't be sent never fails the request,
+// the error is only logged
+func (us userService) sendMail(ctx context.Context, t mails.Template, to string, data any) {
+	mail, err := mails.Render(t, to, data)
+
+	if err == nil {
+		err = us.mailer.Send(ctx, mail)
+	}
+
+	if err != nil {
+		log.Printf("services: sending %s mail: %v", t, err)
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/marlonmp/books-app/models"
+	"github.com/marlonmp/books-app/payloads"
+	"github.com/marlonmp/books-app/repos"
+	"github.com/marlonmp/books-app/valobjs"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (fu *fakeUsers) UpdatePasswordByID(ctx context.Context, id uuid.UUID, status models.UserStatus, pwd valobjs.Password) error {
+	for i, u := range fu.users {
+		if u.ID == id && u.Status == status {
+			fu.users[i].Password = pwd
+			fu.rehashes++
+
+			return nil
+		}
+	}
+
+	return repos.NotFoundError{}
+}
+
+func TestSignInRehashesPassword(t *testing.T) {
+	defer valobjs.UsePasswordHashing(valobjs.CurrentPasswordHashing())
+
+	current := valobjs.DefaultPasswordHashing
+	current.Algorithm = valobjs.HashBcrypt
+	current.BcryptCost = bcrypt.MinCost
+
+	// the hash of the user was made with a cost that is not used anymore
+	old := current
+	old.BcryptCost = bcrypt.MinCost + 1
+
+	valobjs.UsePasswordHashing(old)
+
+	user := newTestUser(t, "jane@example.com", "s3cret-pass")
+
+	valobjs.UsePasswordHashing(current)
+
+	ctx := context.Background()
+	svc, users := newSignInTest(t, user)
+
+	signIn := func(pwd string) error {
+		_, err := svc.SignIn(ctx, payloads.UserCredentials{Login: "jane@example.com", Password: pwd}, payloads.ClientInfo{IP: "10.0.0.1"})
+
+		return err
+	}
+
+	// a failed sign in does not rehash the password
+	if err := signIn("wrong"); err == nil {
+		t.Fatal("sign in with a wrong password succeeded")
+	}
+
+	if users.rehashes != 0 {
+		t.Fatalf("%d rehashes after a failed sign in", users.rehashes)
+	}
+
+	if err := signIn("s3cret-pass"); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	password := users.users[0].Password
+
+	if users.rehashes != 1 || password.NeedsRehash() || !password.IsEqual("s3cret-pass") {
+		t.Fatalf("%d rehashes, the password is not rehashed with the current cost", users.rehashes)
+	}
+
+	// the new hash is kept
+	if err := signIn("s3cret-pass"); err != nil {
+		t.Fatalf("sign in with the new hash: %v", err)
+	}
+
+	if users.rehashes != 1 {
+		t.Errorf("%d rehashes, the current hash must not be rehashed", users.rehashes)
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+type localStorage struct {
+	root string
+}
+
+// Local returns a storage that keeps the files under the given directory
+func Local(root string) valobjs.Storage {
+	return localStorage{root}
+}
+
+// returns the os path of the file, the name is cleaned as an absolute path so
+// it can't point outside of the root
+func (ls localStorage) path(name string) string {
+	return filepath.Join(ls.root, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+func (ls localStorage) Open(name string) (io.ReadSeekCloser, error) {
+	return os.Open(ls.path(name))
+}
+
+// writes into a temporal file in the same directory and then renames it, the
+// rename is atomic because both files are in the same file system
+func (ls localStorage) Put(name string, r io.Reader) (err error) {
+	p := ls.path(name)
+	dir, base := filepath.Split(p)
+
+	err = os.MkdirAll(dir, 0o755)
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		return err
+	}
+
+	if err = tmp.Chmod(0o644); err != nil {
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (ls localStorage) Remove(name string) error {
+	err := os.Remove(ls.path(name))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+type memoryStorage struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// Memory returns a storage that keeps the files in memory, the files are lost
+// when the process ends. It's meant for tests and development
+func Memory() valobjs.Storage {
+	return &memoryStorage{files: make(map[string][]byte)}
+}
+
+// the stored slices are never modified, a put replaces the whole slice, so the
+// readers can share them
+func (ms *memoryStorage) Open(name string) (io.ReadSeekCloser, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	b, ok := ms.files[name]
+
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return nopCloser{bytes.NewReader(b)}, nil
+}
+
+func (ms *memoryStorage) Put(name string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.files[name] = b
+
+	return nil
+}
+
+func (ms *memoryStorage) Remove(name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.files, name)
+
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
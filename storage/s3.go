@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+const (
+	s3Algorithm = "AWS4-HMAC-SHA256"
+	s3Service   = "s3"
+
+	// sha256 of an empty payload
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	s3DateFormat      = "20060102"
+	s3TimestampFormat = "20060102T150405Z"
+)
+
+type S3Config struct {
+	// base url of the service, e.g. "https://s3.us-east-1.amazonaws.com" or
+	// "http://localhost:9000" for a local MinIO
+	Endpoint string
+
+	Region,
+	Bucket,
+	AccessKey,
+	SecretKey string
+}
+
+type s3Storage struct {
+	endpoint *url.URL
+	config   S3Config
+	client   *http.Client
+}
+
+// S3 returns a storage that keeps the files in a bucket of an S3 compatible
+// service, the objects are addressed with path style urls so it works with
+// MinIO and the other S3 compatible services
+func S3(config S3Config) (valobjs.Storage, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.Scheme == "" || endpoint.Host == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage: invalid endpoint %q or bucket %q", config.Endpoint, config.Bucket)
+	}
+
+	// there is no client timeout because the downloads are streamed, the
+	// connection and headers timeouts are set in the transport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = time.Minute
+
+	client := &http.Client{Transport: transport}
+
+	return s3Storage{endpoint, config, client}, nil
+}
+
+// Open checks that the object exists and returns a reader that downloads it
+// with range requests, so seeking does not download the skipped bytes
+func (ss s3Storage) Open(name string) (io.ReadSeekCloser, error) {
+	res, err := ss.do(http.MethodHead, name, nil, emptyPayloadHash, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, s3ResponseError("open", name, res)
+	}
+
+	return &s3Reader{ss: ss, name: name, size: res.ContentLength}, nil
+}
+
+// the content is spooled into a temporal file to know its size and hash before
+// sending it, the objects are replaced atomically by the service
+func (ss s3Storage) Put(name string, r io.Reader) error {
+	tmp, err := os.CreateTemp("", "s3-put-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Seek(0, io.SeekStart)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := ss.do(http.MethodPut, name, io.NopCloser(tmp), hex.EncodeToString(h.Sum(nil)), func(req *http.Request) {
+		req.ContentLength = size
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return s3ResponseError("put", name, res)
+	}
+
+	return nil
+}
+
+func (ss s3Storage) Remove(name string) error {
+	res, err := ss.do(http.MethodDelete, name, nil, emptyPayloadHash, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	// deleting an object that does not exist also returns no content
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return s3ResponseError("remove", name, res)
+	}
+
+	return nil
+}
+
+func (ss s3Storage) do(method, name string, body io.Reader, payloadHash string, prepare func(*http.Request)) (*http.Response, error) {
+	u := *ss.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + ss.config.Bucket + "/" + strings.TrimPrefix(name, "/")
+	u.RawPath = s3EscapePath(u.Path)
+
+	req, err := http.NewRequest(method, u.String(), body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if prepare != nil {
+		prepare(req)
+	}
+
+	ss.sign(req, payloadHash, time.Now().UTC())
+
+	return ss.client.Do(req)
+}
+
+// signs the request with the AWS signature version 4
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (ss s3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	timestamp := now.Format(s3TimestampFormat)
+	scope := strings.Join([]string{now.Format(s3DateFormat), ss.config.Region, s3Service, "aws4_request"}, "/")
+
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", timestamp)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + timestamp,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		timestamp,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+ss.config.SecretKey), now.Format(s3DateFormat))
+	key = hmacSHA256(key, ss.config.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, ss.config.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// escapes every byte except the unreserved characters and the slashes, as
+// the signature requires
+func s3EscapePath(p string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+func s3ResponseError(op, name string, res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<10))
+
+	return fmt.Errorf("s3 storage: %s %s: %s: %s", op, name, res.Status, body)
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Reader reads an object with range requests, the response body is kept
+// open while the reads are sequential
+type s3Reader struct {
+	ss   s3Storage
+	name string
+	size int64
+
+	offset int64
+	body   io.ReadCloser
+}
+
+func (sr *s3Reader) Read(p []byte) (int, error) {
+	if sr.offset >= sr.size {
+		return 0, io.EOF
+	}
+
+	if sr.body == nil {
+		rangeHeader := fmt.Sprintf("bytes=%d-", sr.offset)
+
+		res, err := sr.ss.do(http.MethodGet, sr.name, nil, emptyPayloadHash, func(req *http.Request) {
+			req.Header.Set("Range", rangeHeader)
+		})
+
+		if err != nil {
+			return 0, err
+		}
+
+		if res.StatusCode != http.StatusPartialContent && res.StatusCode != http.StatusOK {
+			defer res.Body.Close()
+			return 0, s3ResponseError("read", sr.name, res)
+		}
+
+		// a service that ignores the range sends the whole object, which is
+		// only right when reading from the start
+		if res.StatusCode == http.StatusOK && sr.offset > 0 {
+			res.Body.Close()
+			return 0, fmt.Errorf("s3 storage: read %s: the range from %d was ignored", sr.name, sr.offset)
+		}
+
+		sr.body = res.Body
+	}
+
+	n, err := sr.body.Read(p)
+	sr.offset += int64(n)
+
+	return n, err
+}
+
+func (sr *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += sr.offset
+	case io.SeekEnd:
+		offset += sr.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("s3 storage: seek: negative position")
+	}
+
+	if offset != sr.offset {
+		// the next read requests the object from the new offset
+		sr.Close()
+		sr.offset = offset
+	}
+
+	return offset, nil
+}
+
+func (sr *s3Reader) Close() error {
+	if sr.body == nil {
+		return nil
+	}
+
+	err := sr.body.Close()
+	sr.body = nil
+
+	return err
+}
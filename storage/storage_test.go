@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marlonmp/books-app/valobjs"
+)
+
+// testStorage checks the contract of valobjs.Storage, every driver runs it
+func testStorage(t *testing.T, s valobjs.Storage) {
+	t.Helper()
+
+	t.Run("put and open", func(t *testing.T) {
+		err := s.Put("books/a.pdf", strings.NewReader("first"))
+
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+
+		if got := readFile(t, s, "books/a.pdf"); got != "first" {
+			t.Errorf("content = %q, want %q", got, "first")
+		}
+	})
+
+	t.Run("put replaces", func(t *testing.T) {
+		for _, content := range []string{"old", "new"} {
+			err := s.Put("books/b.pdf", strings.NewReader(content))
+
+			if err != nil {
+				t.Fatalf("put %q: %v", content, err)
+			}
+		}
+
+		if got := readFile(t, s, "books/b.pdf"); got != "new" {
+			t.Errorf("content = %q, want %q", got, "new")
+		}
+	})
+
+	t.Run("failed put keeps the content", func(t *testing.T) {
+		err := s.Put("books/c.pdf", strings.NewReader("kept"))
+
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+
+		err = s.Put("books/c.pdf", failingReader{})
+
+		if err == nil {
+			t.Fatal("put of a failing reader returned no error")
+		}
+
+		if got := readFile(t, s, "books/c.pdf"); got != "kept" {
+			t.Errorf("content = %q, want %q", got, "kept")
+		}
+	})
+
+	t.Run("seek", func(t *testing.T) {
+		err := s.Put("books/d.pdf", strings.NewReader("0123456789"))
+
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+
+		f, err := s.Open("books/d.pdf")
+
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+
+		defer f.Close()
+
+		size, err := f.Seek(0, io.SeekEnd)
+
+		if err != nil || size != 10 {
+			t.Fatalf("seek end = %d, %v, want 10", size, err)
+		}
+
+		_, err = f.Seek(4, io.SeekStart)
+
+		if err != nil {
+			t.Fatalf("seek: %v", err)
+		}
+
+		b, err := io.ReadAll(f)
+
+		if err != nil || string(b) != "456789" {
+			t.Errorf("read after seek = %q, %v, want %q", b, err, "456789")
+		}
+	})
+
+	t.Run("open missing", func(t *testing.T) {
+		_, err := s.Open("books/missing.pdf")
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("open missing = %v, want fs.ErrNotExist", err)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		err := s.Put("books/e.pdf", strings.NewReader("gone"))
+
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err = s.Remove("books/e.pdf"); err != nil {
+				t.Fatalf("remove %d: %v", i, err)
+			}
+		}
+
+		_, err = s.Open("books/e.pdf")
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("open removed = %v, want fs.ErrNotExist", err)
+		}
+	})
+}
+
+func readFile(t *testing.T, s valobjs.Storage, name string) string {
+	t.Helper()
+
+	f, err := s.Open(name)
+
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+
+	return string(b)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestLocal(t *testing.T) {
+	testStorage(t, Local(t.TempDir()))
+}
+
+func TestLocalStaysInRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+
+	err := Local(root).Put("../../outside.txt", strings.NewReader("x"))
+
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "outside.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the file was written outside of the root")
+	}
+
+	if _, err = os.Stat(filepath.Join(root, "outside.txt")); err != nil {
+		t.Errorf("the file is not in the root: %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, Memory())
+}
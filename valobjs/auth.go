@@ -3,8 +3,6 @@ package valobjs
 import (
 	"database/sql/driver"
 	"errors"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -20,26 +18,57 @@ func PasswordFromHash(hash string) Password {
 	return Password{hash, false}
 }
 
-func NewPassword(pwd string) (Password, error) {
-	pwdB := []byte(pwd)
-
-	hashB, err := bcrypt.GenerateFromPassword(pwdB, bcrypt.DefaultCost)
+// HashPassword hashes a password that was already checked against the policy
+// by the validation of its payload, see [CurrentPasswordPolicy]
+func HashPassword(pwd string) (Password, error) {
+	hash, err := CurrentPasswordHashing().hash(pwd)
 
 	if err != nil {
 		return Password{}, err
 	}
 
-	hash := string(hashB)
-
 	return Password{hash, false}, nil
 }
 
+// NoPassword returns the password of the users that only sign in with other
+// methods, it never matches
+func NoPassword() Password {
+	return Password{"", true}
+}
+
+// IsNil reports whether the user has no password
+func (p Password) IsNil() bool {
+	return p.isNil
+}
+
 func (p Password) IsEqual(pwd string) bool {
-	hashB, pwdB := []byte(p.hash), []byte(pwd)
+	// takes the time of a real comparison, so the users without password are
+	// not revealed
+	if p.isNil {
+		CompareDummyPassword(pwd)
+		return false
+	}
 
-	err := bcrypt.CompareHashAndPassword(hashB, pwdB)
+	return compareHash(p.hash, pwd)
+}
+
+// NeedsRehash reports whether the password was hashed with other algorithm or
+// cost than the current ones, see [UsePasswordHashing]
+func (p Password) NeedsRehash() bool {
+	return !p.isNil && CurrentPasswordHashing().needsRehash(p.hash)
+}
 
-	return err == nil
+// Rehash hashes again the password with the current algorithm and cost, the
+// password must be already verified with [Password.IsEqual]. The policy is
+// not checked, so the old passwords can be rehashed
+func (p Password) Rehash(pwd string) (Password, error) {
+	hash, err := CurrentPasswordHashing().hash(pwd)
+
+	if err != nil {
+		return Password{}, err
+	}
+
+	return Password{hash, false}, nil
 }
 
 func (p Password) String() string {
@@ -64,7 +93,7 @@ func (p *Password) Scan(src any) error {
 		return ErrPasswordInvalidType
 	}
 
-	_, err := bcrypt.Cost(hashedPassword)
+	err := validateHash(string(hashedPassword))
 
 	if err != nil {
 		return err
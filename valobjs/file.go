@@ -1,9 +1,10 @@
 package valobjs
 
 import (
-	"errors"
-	"os"
+	"bytes"
+	"io"
 	"path"
+	"strings"
 )
 
 type File struct {
@@ -12,7 +13,33 @@ type File struct {
 }
 
 func FileFromPath(path string) *File {
-	return &File{path: path}
+	f := &File{}
+
+	f.setPath(path)
+
+	return f
+}
+
+// FileFromName returns the file stored with the given filename, the inverse of
+// [SaveFileFromBytes] and [SaveFile]
+func FileFromName(filename string) *File {
+	return FileFromPath(filename)
+}
+
+// SaveFile streams the content of r into a new file, the content is never
+// fully loaded in memory
+func SaveFile(r io.Reader, filename string) (*File, error) {
+	f := &File{}
+
+	f.setPath(filename)
+
+	err := f.SaveFrom(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
 }
 
 func SaveFileFromBytes(bytes []byte, filename string) (*File, error) {
@@ -29,13 +56,48 @@ func SaveFileFromBytes(bytes []byte, filename string) (*File, error) {
 	return f, nil
 }
 
+// the path is relative to the storage root, so the leading slashes are removed
 func (f *File) setPath(filename string) {
-	basePath := os.Getenv("FILE_SOTRAGE_PATH")
-	f.path = path.Join(basePath, filename)
+	f.path = strings.TrimPrefix(path.Clean("/"+filename), "/")
+}
+
+// Open returns a seekable reader of the stored file, it must be closed by the
+// caller
+func (f *File) Open() (io.ReadSeekCloser, error) {
+	s, err := currentStorage()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Open(f.path)
 }
 
+// WriteTo streams the stored file into w
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	r, err := f.Open()
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.Close()
+
+	return io.Copy(w, r)
+}
+
+// Load reads the whole stored file into memory, prefer [File.Open] for big
+// files
 func (f *File) Load() error {
-	bytes, err := os.ReadFile(f.path)
+	r, err := f.Open()
+
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	bytes, err := io.ReadAll(r)
 
 	if err != nil {
 		return err
@@ -46,26 +108,40 @@ func (f *File) Load() error {
 	return nil
 }
 
+func (f *File) Bytes() []byte {
+	return f.bytes
+}
+
 func (f *File) Clean() {
 	f.bytes = nil
 }
 
-func (f *File) Save() error {
-	dir, _ := path.Split(f.path)
+// SaveFrom atomically replaces the stored file with the content of r
+func (f *File) SaveFrom(r io.Reader) error {
+	s, err := currentStorage()
 
-	_, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
 
-	if errors.Is(err, os.ErrNotExist) {
-		err = os.MkdirAll(dir, os.ModePerm)
+	return s.Put(f.path, r)
+}
 
-		if err != nil {
-			return err
-		}
-	}
+// Save atomically replaces the stored file with the loaded bytes
+func (f *File) Save() error {
+	return f.SaveFrom(bytes.NewReader(f.bytes))
+}
+
+// Remove deletes the file from the storage, removing a file that does not
+// exist is not an error
+func (f *File) Remove() error {
+	s, err := currentStorage()
 
-	err = os.WriteFile(f.path, f.bytes, os.ModePerm)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return s.Remove(f.path)
 }
 
 func (f *File) String() string {
@@ -0,0 +1,244 @@
+package valobjs
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrPasswordUnknownHash = errors.New("invalid hash: unknown password hash format")
+)
+
+type HashAlgorithm string
+
+const (
+	HashBcrypt   HashAlgorithm = "bcrypt"
+	HashArgon2id HashAlgorithm = "argon2id"
+)
+
+// Argon2Params are the argon2id cost parameters, the memory is in KiB
+type Argon2Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+
+	SaltLen,
+	KeyLen uint32
+}
+
+// PasswordHashing is the algorithm and cost used to hash the new passwords,
+// the hashes made with other settings are still verified but need a rehash
+type PasswordHashing struct {
+	Algorithm HashAlgorithm
+
+	BcryptCost int
+
+	Argon2 Argon2Params
+}
+
+var DefaultPasswordHashing = PasswordHashing{
+	Algorithm:  HashArgon2id,
+	BcryptCost: bcrypt.DefaultCost,
+	Argon2: Argon2Params{
+		Memory:  64 * 1024,
+		Time:    3,
+		Threads: 2,
+		SaltLen: 16,
+		KeyLen:  32,
+	},
+}
+
+var (
+	hashingMu sync.RWMutex
+	hashing   = DefaultPasswordHashing
+)
+
+// UsePasswordHashing sets the algorithm and cost of the new hashes
+func UsePasswordHashing(ph PasswordHashing) {
+	hashingMu.Lock()
+	defer hashingMu.Unlock()
+
+	hashing = ph
+}
+
+func CurrentPasswordHashing() PasswordHashing {
+	hashingMu.RLock()
+	defer hashingMu.RUnlock()
+
+	return hashing
+}
+
+func (ph PasswordHashing) hash(pwd string) (string, error) {
+	if ph.Algorithm == HashBcrypt {
+		hashB, err := bcrypt.GenerateFromPassword([]byte(pwd), ph.BcryptCost)
+
+		return string(hashB), err
+	}
+
+	salt := make([]byte, ph.Argon2.SaltLen)
+
+	_, err := rand.Read(salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	params := ph.Argon2
+	key := argon2.IDKey([]byte(pwd), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+
+	return argon2Hash{params, salt, key}.String(), nil
+}
+
+// reports whether the hash was made with other algorithm or cost
+func (ph PasswordHashing) needsRehash(hash string) bool {
+	if ph.Algorithm == HashBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+
+		return err != nil || cost != ph.BcryptCost
+	}
+
+	ah, err := parseArgon2Hash(hash)
+
+	if err != nil {
+		return true
+	}
+
+	return ah.params.Memory != ph.Argon2.Memory ||
+		ah.params.Time != ph.Argon2.Time ||
+		ah.params.Threads != ph.Argon2.Threads ||
+		ah.params.KeyLen != ph.Argon2.KeyLen
+}
+
+var (
+	dummyHashMu sync.Mutex
+	dummyHashes = make(map[PasswordHashing]string)
+)
+
+// CompareDummyPassword spends the same time as comparing the password with a
+// hash made with the current hashing, so the sign ins of unknown users take as
+// long as the ones of known users and don't reveal which users exist
+func CompareDummyPassword(pwd string) {
+	ph := CurrentPasswordHashing()
+
+	dummyHashMu.Lock()
+	hash, ok := dummyHashes[ph]
+	dummyHashMu.Unlock()
+
+	if !ok {
+		var err error
+
+		hash, err = ph.hash("dummy password")
+
+		if err != nil {
+			return
+		}
+
+		dummyHashMu.Lock()
+		dummyHashes[ph] = hash
+		dummyHashMu.Unlock()
+	}
+
+	compareHash(hash, pwd)
+}
+
+// compares the password with a hash of any of the supported algorithms
+func compareHash(hash, pwd string) bool {
+	if isBcryptHash(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pwd)) == nil
+	}
+
+	ah, err := parseArgon2Hash(hash)
+
+	if err != nil {
+		return false
+	}
+
+	p := ah.params
+	key := argon2.IDKey([]byte(pwd), ah.salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+
+	return subtle.ConstantTimeCompare(key, ah.key) == 1
+}
+
+// returns an error if the hash is not of a supported algorithm
+func validateHash(hash string) error {
+	if isBcryptHash(hash) {
+		_, err := bcrypt.Cost([]byte(hash))
+		return err
+	}
+
+	_, err := parseArgon2Hash(hash)
+
+	return err
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// argon2Hash is an argon2id hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type argon2Hash struct {
+	params Argon2Params
+	salt   []byte
+	key    []byte
+}
+
+var phcEncoding = base64.RawStdEncoding
+
+func (ah argon2Hash) String() string {
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, ah.params.Memory, ah.params.Time, ah.params.Threads,
+		phcEncoding.EncodeToString(ah.salt), phcEncoding.EncodeToString(ah.key),
+	)
+}
+
+func parseArgon2Hash(hash string) (argon2Hash, error) {
+	parts := strings.Split(hash, "$")
+
+	// the hash starts with $, so the first part is empty
+	if len(parts) != 6 || parts[0] != "" || parts[1] != string(HashArgon2id) {
+		return argon2Hash{}, ErrPasswordUnknownHash
+	}
+
+	var version int
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+
+	if err != nil || version != argon2.Version {
+		return argon2Hash{}, ErrPasswordUnknownHash
+	}
+
+	var ah argon2Hash
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &ah.params.Memory, &ah.params.Time, &ah.params.Threads)
+
+	if err != nil {
+		return argon2Hash{}, ErrPasswordUnknownHash
+	}
+
+	ah.salt, err = phcEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return argon2Hash{}, ErrPasswordUnknownHash
+	}
+
+	ah.key, err = phcEncoding.DecodeString(parts[5])
+
+	if err != nil || len(ah.key) == 0 {
+		return argon2Hash{}, ErrPasswordUnknownHash
+	}
+
+	ah.params.SaltLen = uint32(len(ah.salt))
+	ah.params.KeyLen = uint32(len(ah.key))
+
+	return ah, nil
+}
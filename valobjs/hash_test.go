@@ -0,0 +1,221 @@
+package valobjs
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap settings, so the tests don't spend the time of real hashes
+var testHashing = PasswordHashing{
+	Algorithm:  HashArgon2id,
+	BcryptCost: bcrypt.MinCost,
+	Argon2: Argon2Params{
+		Memory:  1024,
+		Time:    1,
+		Threads: 1,
+		SaltLen: 16,
+		KeyLen:  32,
+	},
+}
+
+func TestArgon2HashRoundTrip(t *testing.T) {
+	hash, err := testHashing.hash("s3cret-pass")
+
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("hash = %s, want the phc format", hash)
+	}
+
+	ah, err := parseArgon2Hash(hash)
+
+	if err != nil {
+		t.Fatalf("parse %s: %v", hash, err)
+	}
+
+	if ah.params != testHashing.Argon2 {
+		t.Errorf("params = %+v, want %+v", ah.params, testHashing.Argon2)
+	}
+
+	if ah.String() != hash {
+		t.Errorf("string = %s, want %s", ah.String(), hash)
+	}
+
+	if !compareHash(hash, "s3cret-pass") {
+		t.Error("the password does not match its hash")
+	}
+
+	if compareHash(hash, "s3cret-pasS") {
+		t.Error("other password matches the hash")
+	}
+
+	// every hash has its own salt
+	other, _ := testHashing.hash("s3cret-pass")
+
+	if other == hash {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestParseArgon2HashMalformed(t *testing.T) {
+	valid, err := testHashing.hash("s3cret-pass")
+
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	parts := strings.Split(valid, "$")
+
+	// replaces one part of the valid hash
+	with := func(i int, part string) string {
+		p := append([]string(nil), parts...)
+		p[i] = part
+
+		return strings.Join(p, "$")
+	}
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"empty", ""},
+		{"bcrypt", "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		{"argon2i", with(1, "argon2i")},
+		{"without leading dollar", strings.TrimPrefix(valid, "$")},
+		{"missing part", strings.Join(parts[:5], "$")},
+		{"extra part", valid + "$extra"},
+		{"other version", with(2, "v=16")},
+		{"no version", with(2, "19")},
+		{"missing param", with(3, "m=1024,t=1")},
+		{"negative param", with(3, "m=-1,t=1,p=1")},
+		{"salt not base64", with(4, "not base64!")},
+		{"key not base64", with(5, "not base64!")},
+		{"empty key", with(5, "")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseArgon2Hash(tt.hash); err != ErrPasswordUnknownHash {
+				t.Errorf("parse %q = %v, want ErrPasswordUnknownHash", tt.hash, err)
+			}
+
+			if compareHash(tt.hash, "s3cret-pass") {
+				t.Errorf("the malformed hash %q matches", tt.hash)
+			}
+		})
+	}
+}
+
+func TestCompareHashBcrypt(t *testing.T) {
+	// the hashes made before argon2id, with any of the bcrypt prefixes
+	hashB, err := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+
+	hash := string(hashB)
+
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		h := prefix + strings.TrimPrefix(hash, hash[:4])
+
+		if err := validateHash(h); err != nil {
+			t.Errorf("validate %s: %v", h, err)
+		}
+
+		if !compareHash(h, "s3cret-pass") {
+			t.Errorf("the password does not match %s", h)
+		}
+
+		if compareHash(h, "wrong") {
+			t.Errorf("other password matches %s", h)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hashWith := func(change func(ph *PasswordHashing)) string {
+		ph := testHashing
+		change(&ph)
+
+		hash, err := ph.hash("s3cret-pass")
+
+		if err != nil {
+			t.Fatalf("hash: %v", err)
+		}
+
+		return hash
+	}
+
+	bcryptHashing := testHashing
+	bcryptHashing.Algorithm = HashBcrypt
+
+	tests := []struct {
+		name    string
+		hashing PasswordHashing
+		hash    string
+		want    bool
+	}{
+		{"same argon2 params", testHashing, hashWith(func(ph *PasswordHashing) {}), false},
+		{"other salt length", testHashing, hashWith(func(ph *PasswordHashing) { ph.Argon2.SaltLen = 32 }), false},
+		{"other memory", testHashing, hashWith(func(ph *PasswordHashing) { ph.Argon2.Memory = 2048 }), true},
+		{"other time", testHashing, hashWith(func(ph *PasswordHashing) { ph.Argon2.Time = 2 }), true},
+		{"other threads", testHashing, hashWith(func(ph *PasswordHashing) { ph.Argon2.Threads = 2 }), true},
+		{"other key length", testHashing, hashWith(func(ph *PasswordHashing) { ph.Argon2.KeyLen = 16 }), true},
+		{"bcrypt to argon2", testHashing, hashWith(func(ph *PasswordHashing) { ph.Algorithm = HashBcrypt }), true},
+		{"argon2 to bcrypt", bcryptHashing, hashWith(func(ph *PasswordHashing) {}), true},
+		{"same bcrypt cost", bcryptHashing, hashWith(func(ph *PasswordHashing) { ph.Algorithm = HashBcrypt }), false},
+		{"other bcrypt cost", bcryptHashing, hashWith(func(ph *PasswordHashing) {
+			ph.Algorithm = HashBcrypt
+			ph.BcryptCost = bcrypt.MinCost + 1
+		}), true},
+		{"unknown hash", testHashing, "plain", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hashing.needsRehash(tt.hash); got != tt.want {
+				t.Errorf("needs rehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordRehash(t *testing.T) {
+	defer UsePasswordHashing(CurrentPasswordHashing())
+
+	old := testHashing
+	old.Algorithm = HashBcrypt
+
+	UsePasswordHashing(old)
+
+	password, err := HashPassword("s3cret-pass")
+
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+
+	UsePasswordHashing(testHashing)
+
+	if !password.NeedsRehash() {
+		t.Fatal("the bcrypt password does not need a rehash")
+	}
+
+	rehashed, err := password.Rehash("s3cret-pass")
+
+	if err != nil {
+		t.Fatalf("rehash: %v", err)
+	}
+
+	if rehashed.NeedsRehash() || !rehashed.IsEqual("s3cret-pass") {
+		t.Errorf("rehashed = %s, want an argon2id hash of the password", rehashed.hash)
+	}
+
+	if NoPassword().NeedsRehash() {
+		t.Error("no password needs a rehash")
+	}
+}
@@ -0,0 +1,221 @@
+package valobjs
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcrypt ignores the bytes after the 72th, so longer passwords are rejected
+// while the passwords are hashed with bcrypt
+const bcryptMaxBytes = 72
+
+type PasswordViolation string
+
+const (
+	PasswordTooShort         PasswordViolation = "too_short"
+	PasswordTooLong          PasswordViolation = "too_long"
+	PasswordNotMixed         PasswordViolation = "not_mixed"
+	PasswordContainsIdentity PasswordViolation = "contains_identity"
+	PasswordBreached         PasswordViolation = "breached"
+)
+
+// PasswordPolicyError is returned when a password violates the policy
+type PasswordPolicyError struct {
+	Violation PasswordViolation
+}
+
+func (ppe PasswordPolicyError) Error() string {
+	switch ppe.Violation {
+	case PasswordTooShort:
+		return "invalid password: the password is too short"
+	case PasswordTooLong:
+		return "invalid password: the password is too long"
+	case PasswordNotMixed:
+		return "invalid password: the password must contain letters and numbers or symbols"
+	case PasswordContainsIdentity:
+		return "invalid password: the password must not contain the username or email"
+	case PasswordBreached:
+		return "invalid password: the password appears in a data breach"
+	default:
+		return "invalid password: the password violates the policy"
+	}
+}
+
+// BreachedPasswords is a list of passwords that were exposed in data breaches
+type BreachedPasswords interface {
+	IsBreached(pwd string) (bool, error)
+}
+
+type PasswordPolicy struct {
+	// min number of characters
+	MinLength int
+
+	// max number of bytes, it can't be greater than 72 with bcrypt
+	MaxBytes int
+
+	// requires letters and numbers or symbols
+	RequireMixed bool
+
+	// rejects the passwords that contain the username or the email
+	RejectIdentity bool
+
+	// rejects the breached passwords, it's skipped if nil
+	Breached BreachedPasswords
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:      8,
+	MaxBytes:       bcryptMaxBytes,
+	RequireMixed:   true,
+	RejectIdentity: true,
+}
+
+var (
+	policyMu sync.RWMutex
+	policy   = DefaultPasswordPolicy
+)
+
+// UsePasswordPolicy sets the policy returned by [CurrentPasswordPolicy], it's
+// checked by the validation of the payloads with new passwords
+func UsePasswordPolicy(pp PasswordPolicy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+
+	policy = pp
+}
+
+func CurrentPasswordPolicy() PasswordPolicy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+
+	return policy
+}
+
+// Check returns a [PasswordPolicyError] with the first violation of the
+// password, the identities are the username and email of the user
+func (pp PasswordPolicy) Check(pwd string, identities ...string) error {
+	if utf8.RuneCountInString(pwd) < pp.MinLength {
+		return PasswordPolicyError{PasswordTooShort}
+	}
+
+	maxBytes := pp.MaxBytes
+
+	if CurrentPasswordHashing().Algorithm == HashBcrypt {
+		maxBytes = min(maxBytes, bcryptMaxBytes)
+	}
+
+	if len(pwd) > maxBytes {
+		return PasswordPolicyError{PasswordTooLong}
+	}
+
+	if pp.RequireMixed && !isMixed(pwd) {
+		return PasswordPolicyError{PasswordNotMixed}
+	}
+
+	if pp.RejectIdentity && containsIdentity(pwd, identities) {
+		return PasswordPolicyError{PasswordContainsIdentity}
+	}
+
+	if pp.Breached != nil {
+		breached, err := pp.Breached.IsBreached(pwd)
+
+		if err != nil {
+			return err
+		}
+
+		if breached {
+			return PasswordPolicyError{PasswordBreached}
+		}
+	}
+
+	return nil
+}
+
+func isMixed(pwd string) bool {
+	var hasLetter, hasOther bool
+
+	for _, r := range pwd {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		} else {
+			hasOther = true
+		}
+	}
+
+	return hasLetter && hasOther
+}
+
+// the emails are also checked by their local part, the short identities are
+// skipped because they match too many passwords
+func containsIdentity(pwd string, identities []string) bool {
+	pwd = strings.ToLower(pwd)
+
+	for _, identity := range identities {
+		identity = strings.ToLower(strings.TrimSpace(identity))
+
+		if local, _, ok := strings.Cut(identity, "@"); ok {
+			if len(local) >= 3 && strings.Contains(pwd, local) {
+				return true
+			}
+		}
+
+		if len(identity) >= 3 && strings.Contains(pwd, identity) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type breachedPasswordsDir struct {
+	dir string
+}
+
+// BreachedPasswordsDir returns a breached passwords list stored with the
+// k-anonymity format of the "Have I Been Pwned" ranges: the directory has one
+// file per sha1 prefix of 5 hex characters, and every line of a file is the
+// rest of a sha1 followed by ":" and a count. Only the file of the password
+// prefix is read
+func BreachedPasswordsDir(dir string) BreachedPasswords {
+	return breachedPasswordsDir{dir}
+}
+
+func (bpd breachedPasswordsDir) IsBreached(pwd string) (bool, error) {
+	sum := sha1.Sum([]byte(pwd))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(filepath.Join(bpd.dir, prefix))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), ":")
+
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,181 @@
+package valobjs
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// breachedList is a list of breached passwords in memory
+type breachedList []string
+
+func (bl breachedList) IsBreached(pwd string) (bool, error) {
+	for _, b := range bl {
+		if b == pwd {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+var errBreachedFailed = errors.New("breached list failed")
+
+type failingBreached struct{}
+
+func (failingBreached) IsBreached(pwd string) (bool, error) {
+	return false, errBreachedFailed
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	strict := DefaultPasswordPolicy
+	strict.Breached = breachedList{"password1"}
+
+	lax := PasswordPolicy{MinLength: 4, MaxBytes: 16}
+
+	tests := []struct {
+		name       string
+		policy     PasswordPolicy
+		pwd        string
+		identities []string
+		want       PasswordViolation
+	}{
+		{"valid", strict, "s3cret-pass", []string{"jane", "jane@example.com"}, ""},
+		{"short", strict, "s3cret", nil, PasswordTooShort},
+		{"counts the characters", strict, "ñandú123", nil, ""},
+		{"shortest", strict, "s3cret-p", nil, ""},
+		{"long", strict, strings.Repeat("a1", 37), nil, PasswordTooLong},
+		{"longest", strict, strings.Repeat("a1", 36), nil, ""},
+		{"only letters", strict, "secretpass", nil, PasswordNotMixed},
+		{"only numbers", strict, "1234567890", nil, PasswordNotMixed},
+		{"letters and symbols", strict, "secret-pass", nil, ""},
+		{"username", strict, "Jane-1234", []string{"jane", "jane@example.com"}, PasswordContainsIdentity},
+		{"email", strict, "jane@example.com1", []string{"john", "jane@example.com"}, PasswordContainsIdentity},
+		{"local part of the email", strict, "doe-1234", []string{"john", "doe@example.com"}, PasswordContainsIdentity},
+		{"short identity", strict, "jo-123456", []string{"jo", "jo@example.com"}, ""},
+		{"breached", strict, "password1", nil, PasswordBreached},
+		{"lax", lax, "jane", []string{"jane"}, ""},
+		{"lax long", lax, strings.Repeat("a", 17), nil, PasswordTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.pwd, tt.identities...)
+
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("check = %v, want no error", err)
+				}
+
+				return
+			}
+
+			var ppe PasswordPolicyError
+
+			if !errors.As(err, &ppe) || ppe.Violation != tt.want {
+				t.Errorf("check = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyCheckBcryptMaxBytes(t *testing.T) {
+	defer UsePasswordHashing(CurrentPasswordHashing())
+
+	policy := PasswordPolicy{MinLength: 8, MaxBytes: 128}
+	pwd := strings.Repeat("a1", 40)
+
+	UsePasswordHashing(PasswordHashing{Algorithm: HashArgon2id})
+
+	if err := policy.Check(pwd); err != nil {
+		t.Errorf("check with argon2id = %v", err)
+	}
+
+	// bcrypt would ignore the bytes after the 72th
+	UsePasswordHashing(PasswordHashing{Algorithm: HashBcrypt})
+
+	var ppe PasswordPolicyError
+
+	if err := policy.Check(pwd); !errors.As(err, &ppe) || ppe.Violation != PasswordTooLong {
+		t.Errorf("check with bcrypt = %v, want %s", err, PasswordTooLong)
+	}
+}
+
+func TestPasswordPolicyCheckBreachedFails(t *testing.T) {
+	policy := DefaultPasswordPolicy
+	policy.Breached = failingBreached{}
+
+	if err := policy.Check("s3cret-pass"); !errors.Is(err, errBreachedFailed) {
+		t.Errorf("check = %v, want the error of the list", err)
+	}
+}
+
+func TestBreachedPasswordsDir(t *testing.T) {
+	dir := t.TempDir()
+
+	sum := sha1.Sum([]byte("password1"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// the lines of the ranges can be in any case and have spaces
+	ranges := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" +
+		" " + strings.ToLower(hash[5:]) + ":2427\n" +
+		"00D4F6E8FA6EECAD2A3AA415EEC418D38EC:2\n"
+
+	err := os.WriteFile(filepath.Join(dir, hash[:5]), []byte(ranges), 0o600)
+
+	if err != nil {
+		t.Fatalf("writing range: %v", err)
+	}
+
+	bpd := BreachedPasswordsDir(dir)
+
+	tests := []struct {
+		pwd  string
+		want bool
+	}{
+		{"password1", true},
+		{"Password1", false},
+		{"s3cret-pass", false},
+	}
+
+	for _, tt := range tests {
+		got, err := bpd.IsBreached(tt.pwd)
+
+		if err != nil {
+			t.Fatalf("is breached %q: %v", tt.pwd, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("is breached %q = %v, want %v", tt.pwd, got, tt.want)
+		}
+	}
+
+	// a missing directory has no breached passwords
+	got, err := BreachedPasswordsDir(filepath.Join(dir, "missing")).IsBreached("password1")
+
+	if err != nil || got {
+		t.Errorf("is breached in a missing dir = %v, %v", got, err)
+	}
+}
+
+func TestBreachedPasswordsDirReadFails(t *testing.T) {
+	dir := t.TempDir()
+
+	sum := sha1.Sum([]byte("password1"))
+	prefix := strings.ToUpper(hex.EncodeToString(sum[:]))[:5]
+
+	// the range can't be read as a file
+	err := os.Mkdir(filepath.Join(dir, prefix), 0o700)
+
+	if err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	if _, err := BreachedPasswordsDir(dir).IsBreached("password1"); err == nil {
+		t.Error("is breached = nil, want the read error")
+	}
+}
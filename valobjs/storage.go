@@ -0,0 +1,52 @@
+package valobjs
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	ErrStorageNotConfigured = errors.New("storage not configured: call UseStorage before using files")
+)
+
+// Storage is a backend where the files are stored, the names are slash
+// separated paths relative to the storage root. Opening a file that does not
+// exist must return an error that wraps [fs.ErrNotExist], and removing it must
+// not return an error
+type Storage interface {
+	// Open returns a seekable reader of the file, it must be closed by the
+	// caller
+	Open(name string) (io.ReadSeekCloser, error)
+
+	// Put stores the content of r in the file, the write is atomic: the file
+	// is never seen with a partial content, and if the write fails the
+	// previous content is kept
+	Put(name string, r io.Reader) error
+
+	Remove(name string) error
+}
+
+var (
+	storageMu sync.RWMutex
+	storage   Storage
+)
+
+// UseStorage sets the storage used by all the files
+func UseStorage(s Storage) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	storage = s
+}
+
+func currentStorage() (Storage, error) {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+
+	if storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+
+	return storage, nil
+}
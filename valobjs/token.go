@@ -0,0 +1,54 @@
+package valobjs
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// number of random bytes of a token
+const tokenSize = 32
+
+// Token is an opaque random secret sent to the clients, only its hash must be
+// stored so a leaked database can't be used to impersonate the clients
+type Token struct {
+	plain string
+	hash  string
+}
+
+// NewToken returns a new random token
+func NewToken() (Token, error) {
+	b := make([]byte, tokenSize)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return Token{}, err
+	}
+
+	return TokenFromString(base64.RawURLEncoding.EncodeToString(b)), nil
+}
+
+// TokenFromString returns the token sent by a client, so its hash can be
+// looked up
+func TokenFromString(plain string) Token {
+	sum := sha256.Sum256([]byte(plain))
+
+	return Token{plain, hex.EncodeToString(sum[:])}
+}
+
+// Plain returns the token that must be sent to the client
+func (t Token) Plain() string {
+	return t.plain
+}
+
+// Hash returns the hash of the token that must be stored
+func (t Token) Hash() string {
+	return t.hash
+}
+
+func (t Token) String() string {
+	// returns an empty strings, because the token never must be logged
+	return ""
+}
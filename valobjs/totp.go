@@ -0,0 +1,196 @@
+package valobjs
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql/driver"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTOTPSecretInvalidType = errors.New("invalid type: the totp secret must be a string")
+)
+
+const (
+	// number of random bytes of a secret, the size of a sha1 hmac key
+	totpSecretSize = 20
+
+	totpDigits = 6
+	totpPeriod = 30 * time.Second
+
+	// number of steps accepted before and after the current one, so the
+	// clocks of the clients can drift a bit
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPSecret is the base32 shared secret of the time based one time passwords
+// of RFC 6238, with sha1, 6 digits and steps of 30 seconds
+type TOTPSecret string
+
+// NewTOTPSecret returns a new random secret
+func NewTOTPSecret() (TOTPSecret, error) {
+	b := make([]byte, totpSecretSize)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "", err
+	}
+
+	return TOTPSecret(totpEncoding.EncodeToString(b)), nil
+}
+
+// URI returns the otpauth uri of the secret, it's the payload of the qr codes
+// scanned by the authenticator apps
+func (s TOTPSecret) URI(issuer, account string) string {
+	query := url.Values{}
+
+	query.Set("secret", string(s))
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+
+	// some apps don't decode the + as a space
+	rawQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: rawQuery,
+	}
+
+	return uri.String()
+}
+
+// Verify checks the code against the steps around the given time, returns the
+// matched step so it can be marked as used and the code can't be replayed
+func (s TOTPSecret) Verify(code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	key, err := totpEncoding.DecodeString(strings.ToUpper(string(s)))
+
+	if err != nil || len(key) == 0 {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(totpPeriod.Seconds())
+
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected := totpCode(key, step)
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// IsZero reports whether the secret was not set
+func (s TOTPSecret) IsZero() bool {
+	return s == ""
+}
+
+func (s TOTPSecret) String() string {
+	// returns an empty strings, because the secret never must be logged
+	return ""
+}
+
+// Scan reads a nullable secret, null is an empty secret
+func (s *TOTPSecret) Scan(src any) error {
+	switch val := src.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = TOTPSecret(val)
+	case []byte:
+		*s = TOTPSecret(val)
+	default:
+		return ErrTOTPSecretInvalidType
+	}
+
+	return nil
+}
+
+func (s TOTPSecret) Value() (driver.Value, error) {
+	if s.IsZero() {
+		return nil, nil
+	}
+
+	return string(s), nil
+}
+
+// the hotp code of RFC 4226 for the given counter
+func totpCode(key []byte, counter int64) string {
+	var msg [8]byte
+
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulus())
+}
+
+// 10 to the power of the digits, the code is the remainder of the value
+func totpModulus() uint32 {
+	modulus := uint32(1)
+
+	for i := 0; i < totpDigits; i++ {
+		modulus *= 10
+	}
+
+	return modulus
+}
+
+// number of random bytes of a recovery code
+const recoveryCodeSize = 10
+
+// NewRecoveryCode returns a new random recovery code, formatted as two groups
+// of eight characters so it's easy to write down
+func NewRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeSize)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(totpEncoding.EncodeToString(b))
+
+	return code[:8] + "-" + code[8:], nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code that must be stored,
+// the case, the spaces and the dashes of the code are ignored
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
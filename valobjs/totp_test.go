@@ -0,0 +1,135 @@
+package valobjs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// the ascii secret "12345678901234567890" of the test vectors of RFC 4226 and
+// RFC 6238
+const rfcSecret TOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func rfcKey(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := totpEncoding.DecodeString(string(rfcSecret))
+
+	if err != nil || string(key) != "12345678901234567890" {
+		t.Fatalf("decoding the rfc secret = %q, %v", key, err)
+	}
+
+	return key
+}
+
+// RFC 4226 appendix D
+func TestHOTPCode(t *testing.T) {
+	key := rfcKey(t)
+
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		if got := totpCode(key, int64(counter)); got != code {
+			t.Errorf("code of counter %d = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+// RFC 6238 appendix B with sha1, the vectors have 8 digits so the codes are
+// their last 6 digits
+func TestTOTPCode(t *testing.T) {
+	key := rfcKey(t)
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		step := tt.unix / int64(totpPeriod.Seconds())
+		want := tt.want[len(tt.want)-totpDigits:]
+
+		if got := totpCode(key, step); got != want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, want)
+		}
+
+		if got, ok := rfcSecret.Verify(want, time.Unix(tt.unix, 0)); !ok || got != step {
+			t.Errorf("verify at %d = %d, %v, want step %d", tt.unix, got, ok, step)
+		}
+	}
+}
+
+func TestTOTPVerify(t *testing.T) {
+	key := rfcKey(t)
+
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / int64(totpPeriod.Seconds())
+
+	tests := []struct {
+		name     string
+		secret   TOTPSecret
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{"current step", rfcSecret, totpCode(key, current), current, true},
+		{"previous step", rfcSecret, totpCode(key, current-1), current - 1, true},
+		{"next step", rfcSecret, totpCode(key, current+1), current + 1, true},
+		{"two steps before", rfcSecret, totpCode(key, current-2), 0, false},
+		{"two steps after", rfcSecret, totpCode(key, current+2), 0, false},
+		{"spaces around", rfcSecret, " " + totpCode(key, current) + "\n", current, true},
+		{"lowercase secret", TOTPSecret(strings.ToLower(string(rfcSecret))), totpCode(key, current), current, true},
+		{"short code", rfcSecret, totpCode(key, current)[1:], 0, false},
+		{"long code", rfcSecret, "0" + totpCode(key, current), 0, false},
+		{"rfc 6238 code", rfcSecret, "14050471", 0, false},
+		{"empty code", rfcSecret, "", 0, false},
+		{"not numeric", rfcSecret, "abcdef", 0, false},
+		{"invalid secret", "not base32!", totpCode(key, current), 0, false},
+		{"empty secret", "", totpCode(key, current), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := tt.secret.Verify(tt.code, now)
+
+			if step != tt.wantStep || ok != tt.wantOk {
+				t.Errorf("verify %q = %d, %v, want %d, %v", tt.code, step, ok, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("abcdefgh-ijklmnop")
+
+	for _, code := range []string{"abcdefghijklmnop", "ABCDEFGH-IJKLMNOP", "abcd efgh ijkl mnop", " AbCdEfGh-iJkLmNoP "} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("hash of %q = %s, want %s", code, got, want)
+		}
+	}
+
+	if HashRecoveryCode("abcdefgh-ijklmnoq") == want {
+		t.Error("other code has the same hash")
+	}
+
+	code, err := NewRecoveryCode()
+
+	if err != nil {
+		t.Fatalf("new recovery code: %v", err)
+	}
+
+	if len(code) != 17 || code[8] != '-' || code != strings.ToLower(code) {
+		t.Errorf("recovery code = %q, want two lowercase groups of eight", code)
+	}
+
+	if HashRecoveryCode(strings.ToUpper(code)) != HashRecoveryCode(code) {
+		t.Errorf("the hash of %q depends on the case", code)
+	}
+}